@@ -2,25 +2,56 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 	"url-shortener/internal/config"
 	"url-shortener/internal/http-server/handlers/greeting"
+	"url-shortener/internal/http-server/handlers/health"
+	"url-shortener/internal/http-server/handlers/preview"
 	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/url/available"
+	"url-shortener/internal/http-server/handlers/url/blocked"
+	"url-shortener/internal/http-server/handlers/url/broken"
 	hDelete "url-shortener/internal/http-server/handlers/url/delete"
+	"url-shortener/internal/http-server/handlers/url/info"
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/presets"
+	"url-shortener/internal/http-server/handlers/url/qrcode"
+	"url-shortener/internal/http-server/handlers/url/rules"
 	"url-shortener/internal/http-server/handlers/url/save"
+	"url-shortener/internal/http-server/handlers/url/update"
 	mwLogger "url-shortener/internal/http-server/middleware/logger"
+	"url-shortener/internal/lib/aliaspolicy"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/clicks"
+	"url-shortener/internal/lib/geoip"
+	"url-shortener/internal/lib/linkcheck"
 	"url-shortener/internal/lib/logger/handlers/slogpretty"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/pagemeta"
+	"url-shortener/internal/lib/signer"
+	"url-shortener/internal/lib/suggest"
+	"url-shortener/internal/lib/throttle"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+	redisStorage "url-shortener/internal/storage/redis"
 	"url-shortener/internal/storage/sqlite"
 
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	goredis "github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -34,15 +65,39 @@ func main() {
 	log.Info("starting url-shortener", slog.String("env", cfg.Env))
 	log.Debug("debug messages are enabled")
 
-	// Initialize storage (SQLite in this case)
-	dirPath := filepath.Dir(cfg.StoragePath)
-	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
-			log.Error("failed to make storage dirs", sl.Err(err))
+	// Create a context canceled on shutdown to stop background workers
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	workers := health.NewWorkers()
+	checker := health.NewChecker(cfg.Health.CheckTimeout)
+
+	// Connect to Redis if it is used as a storage backend or a shared cache
+	var redisClient *goredis.Client
+	if cfg.Storage.Backend == backendRedis || cfg.Redis.Cache.Enabled {
+		var err error
+		redisClient, err = redisStorage.NewClient(ctx, redisStorage.Options{
+			Addr:     cfg.Redis.Addr,
+			Username: cfg.Redis.Username,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		if err != nil {
+			log.Error("failed to connect to redis", sl.Err(err))
 			os.Exit(1)
 		}
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				log.Error("failed to close redis client", sl.Err(err))
+			}
+		}()
+		checker.Register("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
 	}
-	storage, err := sqlite.New(cfg.StoragePath)
+
+	// Initialize storage
+	storage, err := newStorage(cfg, redisClient)
 	if err != nil {
 		log.Error("Failed to init storage", sl.Err(err))
 		os.Exit(1)
@@ -53,11 +108,171 @@ func main() {
 		}
 	}()
 
+	// Put caches in front of redirect lookups: in-process, then shared Redis, then storage.
+	// Invalidation hooks run in registration order, so the shared cache is invalidated first.
+	var urlGetter redirect.URLGetter = storage
+	var redisCache *redisStorage.Cache
+	if cfg.Redis.Cache.Enabled {
+		redisCache = redisStorage.NewCache(redisClient, urlGetter, redisStorage.CacheOptions{
+			KeyPrefix:   cfg.Redis.KeyPrefix,
+			TTL:         cfg.Redis.Cache.TTL,
+			NegativeTTL: cfg.Redis.Cache.NegativeTTL,
+			Channel:     cfg.Redis.Cache.Channel,
+		})
+		storage.OnChange(func(alias string) {
+			if err := redisCache.Invalidate(context.Background(), alias); err != nil {
+				log.Error("failed to invalidate shared cache", slog.String("alias", alias), sl.Err(err))
+			}
+		})
+		urlGetter = redisCache
+	}
+	if cfg.Cache.Enabled {
+		c := cache.New(urlGetter, cache.Options{
+			Size:        cfg.Cache.Size,
+			TTL:         cfg.Cache.TTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+		})
+		storage.OnChange(c.Invalidate)
+		if redisCache != nil {
+			// other replicas announce their changes through the shared cache
+			go runInvalidationSubscriber(ctx, log, redisCache, c.Invalidate, workers)
+		}
+		urlGetter = c
+	}
+
+	// Set up access to password-protected links
+	protection, err := newProtection(cfg.Protection)
+	if err != nil {
+		log.Error("failed to set up link protection", sl.Err(err))
+		os.Exit(1)
+	}
+	if cfg.Protection.CookieSecret == "" {
+		log.Warn("cookie secret is not set, unlocked links will be locked again after a restart")
+	}
+
+	pending, err := newPending(cfg.Schedule)
+	if err != nil {
+		log.Error("failed to load pending page", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if !redirect.ValidType(cfg.Redirect.DefaultType) {
+		log.Error("invalid default redirect type", slog.String("type", cfg.Redirect.DefaultType))
+		os.Exit(1)
+	}
+
+	var geo redirect.CountryLookup
+	if cfg.GeoIP.Database != "" {
+		db, err := geoip.Open(cfg.GeoIP.Database)
+		if err != nil {
+			log.Error("failed to load geoip database", sl.Err(err))
+			os.Exit(1)
+		}
+		geo = db
+	}
+
+	blocks, err := newBlocklist(cfg.Blocklist)
+	if err != nil {
+		log.Error("failed to load blocklist", sl.Err(err))
+		os.Exit(1)
+	}
+	if len(cfg.Blocklist.Feeds) > 0 {
+		log.Info("blocklist loaded", slog.Int("domains", blocks.Len()))
+		// a zero interval keeps the lists loaded on start
+		if cfg.Blocklist.ReloadInterval > 0 {
+			go runBlocklistWatcher(ctx, log, blocks, cfg.Blocklist.ReloadInterval, workers)
+		}
+	}
+
+	policy, err := newURLPolicy(cfg.URLPolicy, cfg.HttpServer.BaseURL, blocks)
+	if err != nil {
+		log.Error("invalid url policy", sl.Err(err))
+		os.Exit(1)
+	}
+
+	aliases, err := aliaspolicy.New(aliaspolicy.Config{
+		MinLength:       cfg.AliasPolicy.MinLength,
+		MaxLength:       cfg.AliasPolicy.MaxLength,
+		Reserved:        cfg.AliasPolicy.Reserved,
+		ProfanityFile:   cfg.AliasPolicy.ProfanityFile,
+		CaseInsensitive: cfg.AliasPolicy.CaseInsensitive,
+		Unicode:         cfg.AliasPolicy.Unicode,
+	})
+	if err != nil {
+		log.Error("invalid alias policy", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Links created before the aliases became normalized would not be found otherwise
+	if cfg.AliasPolicy.CaseInsensitive || cfg.AliasPolicy.Unicode {
+		renamed, err := storage.NormalizeAliases(ctx, aliases.Normalize)
+		if err != nil {
+			log.Error("failed to normalize aliases", sl.Err(err))
+			os.Exit(1)
+		}
+		if renamed > 0 {
+			log.Info("aliases normalized", slog.Int("renamed", renamed))
+		}
+	}
+
+	suggester := suggest.New(storage, aliases, cfg.AliasPolicy.Suggestions)
+
+	var pages save.PageFetcher
+	if cfg.PageFetch.Enabled {
+		fetcher := pagemeta.NewFetcher(pagemeta.Options{
+			Timeout:      cfg.PageFetch.Timeout,
+			MaxBytes:     cfg.PageFetch.MaxBytes,
+			MaxRedirects: cfg.PageFetch.MaxRedirects,
+			UserAgent:    cfg.PageFetch.UserAgent,
+		})
+		worker := pagemeta.NewWorker(log, fetcher, storage, cfg.PageFetch.Workers, cfg.PageFetch.QueueSize)
+		go runPageFetcher(ctx, worker, workers)
+		pages = worker
+	}
+
+	if cfg.LinkCheck.Enabled {
+		if cfg.LinkCheck.Interval <= 0 {
+			log.Error("link check interval must be positive", slog.Duration("interval", cfg.LinkCheck.Interval))
+			os.Exit(1)
+		}
+		linkChecker := linkcheck.New(log, storage, linkcheck.Options{
+			Interval:      cfg.LinkCheck.Interval,
+			Concurrency:   cfg.LinkCheck.Workers,
+			HostInterval:  cfg.LinkCheck.HostInterval,
+			Timeout:       cfg.LinkCheck.Timeout,
+			MaxRedirects:  cfg.LinkCheck.MaxRedirects,
+			FallbackAfter: cfg.LinkCheck.FallbackAfter,
+			UserAgent:     cfg.LinkCheck.UserAgent,
+		})
+		go runLinkChecker(ctx, linkChecker, workers)
+	}
+
+	// Redirects are counted in memory and written in batches, off the request path
+	clickBatcher := clicks.New(log, storage, clicks.Options{
+		FlushInterval: cfg.Clicks.FlushInterval,
+		QueueSize:     cfg.Clicks.QueueSize,
+		BatchSize:     cfg.Clicks.BatchSize,
+	})
+	clicksDone := make(chan struct{})
+	go func() {
+		defer close(clicksDone)
+		runClickBatcher(ctx, clickBatcher, workers)
+	}()
+
+	// Set up readiness checks
+	checker.Register("storage", storage.Ping)
+	checker.Register("migrations", storage.CheckMigrations)
+	checker.Register("workers", workers.Check)
+
 	// Create a new Chi router
 	router := chi.NewRouter()
 
 	// Middleware setup
 	router.Use(middleware.RequestID)
+	if cfg.HttpServer.TrustProxy {
+		// country rules and logs need the address of the client rather than the one of the proxy
+		router.Use(middleware.RealIP)
+	}
 	// TODO: Log requests with mismatched route using slog instead of chi logger
 	router.Use(middleware.Logger)
 	router.Use(mwLogger.New(log))
@@ -79,13 +294,59 @@ func main() {
 		// Uncomment and customize the following lines based on your routes
 		// r.Post("/", save.New(log, storage))
 		// r.Delete("/{alias}", hDelete.New(log, storage))
+		r.Get("/", list.New(log, storage))
+		r.Get("/alias-available", available.New(log, storage, aliases, suggester))
+		r.Get("/blocked", blocked.New(log, blocks))
+		r.Get("/broken", broken.New(log, storage))
+		r.Get("/utm-presets", presets.NewList(log, storage))
+		r.Post("/utm-presets", presets.NewSave(log, storage))
+		r.Delete("/utm-presets/{id}", presets.NewDelete(log, storage))
+		r.Group(func(r chi.Router) {
+			r.Use(aliases.NormalizeParam)
+			r.Get("/{alias}", info.New(log, storage))
+			r.Patch("/{alias}", update.New(log, storage))
+			r.Get("/{alias}/qr", qrcode.New(log, urlGetter, cfg.HttpServer.BaseURL))
+			r.Get("/{alias}/rules", rules.NewList(log, storage))
+			r.Post("/{alias}/rules", rules.NewAdd(log, storage, policy))
+			r.Put("/{alias}/rules/{id}", rules.NewUpdate(log, storage, policy))
+			r.Delete("/{alias}/rules/{id}", rules.NewDelete(log, storage))
+		})
 	})
 
+	// Define probes for the orchestrator
+	router.Get("/healthz", health.NewLiveness())
+	router.Get("/readyz", health.NewReadiness(log, checker))
+
 	// Define routes for saving, deleting, and redirecting
 	router.Get("/", greeting.New(log, "./static"))
-	router.Post("/", save.New(log, storage))
-	router.Delete("/{alias}", hDelete.New(log, storage))
-	router.Get("/{alias}", redirect.New(log, storage))
+	router.Post("/", save.New(log, storage, policy, aliases, pages, suggester))
+	// Aliases in paths are looked up the way they are stored
+	byAlias := router.With(aliases.NormalizeParam)
+	byAlias.Delete("/{alias}", hDelete.New(log, storage, aliases))
+	redirectHandler := redirect.New(log, urlGetter, clickBatcher, redirect.Options{
+		Protection:        protection,
+		Pending:           pending,
+		Geo:               geo,
+		DefaultType:       cfg.Redirect.DefaultType,
+		PermanentMaxAge:   cfg.Redirect.PermanentMaxAge,
+		InterstitialDelay: cfg.Redirect.InterstitialDelay,
+		VariantCookieTTL:  cfg.Redirect.VariantCookieTTL,
+		Unfurl:            cfg.Redirect.Unfurl,
+		Blocklist:         blocks,
+	})
+	byAlias.Get("/{alias}", redirectHandler)
+	byAlias.Get("/{alias}+", preview.New(log, storage, blocks))
+	byAlias.Get("/{alias}/*", redirectHandler)
+	byAlias.Post("/{alias}", redirect.NewUnlock(log, urlGetter, protection, redirectHandler))
+	byAlias.Post("/{alias}/*", redirectHandler)
+
+	// Aliases equal to the routes above would never be reached
+	routes, err := aliaspolicy.Routes(router)
+	if err != nil {
+		log.Error("failed to list routes", sl.Err(err))
+		os.Exit(1)
+	}
+	aliases.Reserve(routes...)
 
 	// Log information about the server start
 	log.Info("starting server", slog.String("address", cfg.Address))
@@ -105,7 +366,7 @@ func main() {
 
 	// Start the server in a goroutine
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Error("failed to start Server", slog.String("error", err.Error()))
 		}
 	}()
@@ -117,12 +378,24 @@ func main() {
 	<-done
 	log.Info("stopping server")
 
+	// Fail readiness first so that the load balancer drains the traffic before the server stops
+	checker.Shutdown()
+	time.Sleep(cfg.HttpServer.ShutdownDelay)
+
 	// Set up a context with a timeout for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.HttpServer.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HttpServer.ShutdownTimeout)
 	defer cancel()
 
 	// Attempt to gracefully stop the server
-	if err := srv.Shutdown(ctx); err != nil {
+	err = srv.Shutdown(shutdownCtx)
+
+	// Stop background workers once no more requests are served
+	stop()
+
+	// The storage is closed on return, the clicks counted until now must be written first
+	<-clicksDone
+
+	if err != nil {
 		log.Error("failed to stop server", sl.Err(err))
 		return
 	}
@@ -131,6 +404,192 @@ func main() {
 	log.Error("server stopped")
 }
 
+const (
+	backendSQLite = "sqlite"
+	backendRedis  = "redis"
+)
+
+// Storage is implemented by every storage backend.
+type Storage interface {
+	save.URLSaver
+	redirect.URLGetter
+	clicks.Store
+	hDelete.URLDeleter
+	info.LinkGetter
+	rules.RuleStore
+	presets.PresetStore
+	update.MetaUpdater
+	list.LinkLister
+	pagemeta.PageSaver
+	linkcheck.Store
+	suggest.Store
+	NormalizeAliases(ctx context.Context, normalize func(alias string) string) (int, error)
+	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
+	OnChange(hook storage.ChangeHook)
+	Close() error
+}
+
+func newStorage(cfg *config.Config, redisClient *goredis.Client) (Storage, error) {
+	switch cfg.Storage.Backend {
+	case backendSQLite, "":
+		dirPath := filepath.Dir(cfg.StoragePath)
+		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("make storage dirs: %w", err)
+		}
+		return sqlite.New(cfg.StoragePath, sqlite.Options{
+			ReadTimeout:  cfg.Storage.ReadTimeout,
+			WriteTimeout: cfg.Storage.WriteTimeout,
+			WAL:          cfg.Storage.WAL,
+			BusyTimeout:  cfg.Storage.BusyTimeout,
+			Synchronous:  cfg.Storage.Synchronous,
+			MaxReadConns: cfg.Storage.MaxReadConns,
+			MaxIdleConns: cfg.Storage.MaxIdleConns,
+		})
+	case backendRedis:
+		return redisStorage.New(redisClient, redisStorage.Options{
+			KeyPrefix:    cfg.Redis.KeyPrefix,
+			ReadTimeout:  cfg.Storage.ReadTimeout,
+			WriteTimeout: cfg.Storage.WriteTimeout,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+// newProtection builds the unlock cookie signer and the failed attempts throttle.
+// Without a configured secret a random one is used, which is only fine for a single replica.
+func newProtection(c config.Protection) (redirect.Protection, error) {
+	secret := []byte(c.CookieSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return redirect.Protection{}, fmt.Errorf("generate cookie secret: %w", err)
+		}
+	}
+
+	return redirect.Protection{
+		Signer:    signer.New(secret),
+		Throttle:  throttle.New(c.MaxFailures, c.FailureWindow),
+		CookieTTL: c.CookieTTL,
+	}, nil
+}
+
+// newPending configures what links serve before their activation time.
+func newPending(c config.Schedule) (redirect.Pending, error) {
+	pending := redirect.Pending{URL: c.PendingURL}
+	if c.PendingURL == "" && c.PendingPage != "" {
+		page, err := template.ParseFiles(c.PendingPage)
+		if err != nil {
+			return redirect.Pending{}, fmt.Errorf("parse pending page: %w", err)
+		}
+		pending.Page = page
+	}
+
+	return pending, nil
+}
+
+// newURLPolicy builds the destination policy. Links to the host of baseURL would redirect to themselves.
+func newURLPolicy(c config.URLPolicy, baseURL string, blocks *blocklist.List) (*urlpolicy.Policy, error) {
+	shortDomains := c.ShortDomains
+	if baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("parse base url: %w", err)
+		}
+		shortDomains = append([]string{u.Hostname()}, shortDomains...)
+	}
+
+	return urlpolicy.New(urlpolicy.Config{
+		Schemes:         c.Schemes,
+		Allow:           c.Allow,
+		Deny:            c.Deny,
+		ShortDomains:    shortDomains,
+		MaxLength:       c.MaxLength,
+		AllowHomographs: c.AllowHomographs,
+		Blocklist:       blocks,
+	})
+}
+
+// newBlocklist loads the phishing and malware feeds, the list is empty without them.
+func newBlocklist(c config.Blocklist) (*blocklist.List, error) {
+	feeds := make([]blocklist.Feed, 0, len(c.Feeds))
+	for _, f := range c.Feeds {
+		feeds = append(feeds, blocklist.Feed{Name: f.Name, Path: f.Path, Format: f.Format})
+	}
+
+	return blocklist.New(feeds)
+}
+
+// runInvalidationSubscriber applies invalidations published by all replicas to the in-process cache.
+// It resubscribes after failures until ctx is canceled.
+func runInvalidationSubscriber(
+	ctx context.Context,
+	log *slog.Logger,
+	c *redisStorage.Cache,
+	invalidate storage.ChangeHook,
+	workers *health.Workers,
+) {
+	const name = "redis-invalidation"
+	const retryDelay = time.Second
+
+	for {
+		workers.Started(name)
+		err := c.Subscribe(ctx, invalidate)
+		workers.Stopped(name)
+
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error("cache invalidation subscription failed", sl.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// runPageFetcher fetches the pages of new links until ctx is canceled.
+func runPageFetcher(ctx context.Context, worker *pagemeta.Worker, workers *health.Workers) {
+	const name = "page-fetcher"
+
+	workers.Started(name)
+	defer workers.Stopped(name)
+
+	worker.Run(ctx)
+}
+
+// runClickBatcher writes counted redirects until ctx is canceled.
+func runClickBatcher(ctx context.Context, batcher *clicks.Batcher, workers *health.Workers) {
+	const name = "click-counter"
+
+	workers.Started(name)
+	defer workers.Stopped(name)
+
+	batcher.Run(ctx)
+}
+
+// runLinkChecker checks the destinations of all links periodically until ctx is canceled.
+func runLinkChecker(ctx context.Context, checker *linkcheck.Checker, workers *health.Workers) {
+	const name = "link-checker"
+
+	workers.Started(name)
+	defer workers.Stopped(name)
+
+	checker.Run(ctx)
+}
+
+func runBlocklistWatcher(ctx context.Context, log *slog.Logger, blocks *blocklist.List, interval time.Duration, workers *health.Workers) {
+	const name = "blocklist"
+
+	workers.Started(name)
+	defer workers.Stopped(name)
+
+	blocks.Watch(ctx, log, interval)
+}
+
 func newSlogLogger(c config.Slog) *slog.Logger {
 	o := &slog.HandlerOptions{Level: c.Level, AddSource: c.AddSource}
 	w := os.Stdout
@@ -138,11 +597,12 @@ func newSlogLogger(c config.Slog) *slog.Logger {
 
 	switch c.Format {
 	case "pretty":
-		h = slogpretty.NewHandler().
+		h = slogpretty.NewHandler(w).
 			WithAddSource(c.AddSource).
 			WithLevel(c.Level).
 			WithLevelEmoji(c.Pretty.Emoji).
-			WithFieldsFormat(c.Pretty.FieldsFormat)
+			WithFieldsFormat(c.Pretty.FieldsFormat).
+			WithTimeLayout(c.Pretty.TimeLayout)
 	case "json":
 		h = slog.NewJSONHandler(w, o)
 	case "text":
@@ -12,10 +12,24 @@ import (
 
 type (
 	Config struct {
-		Env         string `yaml:"env" env-defaul:"local" env-required:"true"`
-		StoragePath string `yaml:"storage_path" env-required:"true"`
-		LoggerPath  string `yaml:"logger_path"`
-		Log         Log    `yaml:"log"`
+		Env         string      `yaml:"env" env-defaul:"local" env-required:"true"`
+		StoragePath string      `yaml:"storage_path" env-required:"true"`
+		Storage     Storage     `yaml:"storage"`
+		Clicks      Clicks      `yaml:"clicks"`
+		Cache       Cache       `yaml:"cache"`
+		Redis       Redis       `yaml:"redis"`
+		LoggerPath  string      `yaml:"logger_path"`
+		Log         Log         `yaml:"log"`
+		Health      Health      `yaml:"health"`
+		Protection  Protection  `yaml:"protection"`
+		Schedule    Schedule    `yaml:"schedule"`
+		Redirect    Redirect    `yaml:"redirect"`
+		GeoIP       GeoIP       `yaml:"geoip"`
+		PageFetch   PageFetch   `yaml:"page_fetch"`
+		URLPolicy   URLPolicy   `yaml:"url_policy"`
+		AliasPolicy AliasPolicy `yaml:"alias_policy"`
+		Blocklist   Blocklist   `yaml:"blocklist"`
+		LinkCheck   LinkCheck   `yaml:"link_check"`
 		HttpServer  `yaml:"http_server" `
 	}
 
@@ -24,8 +38,129 @@ type (
 		Timeout         time.Duration `yaml:"timeout" env-default:"4s"`
 		IdleTimeout     time.Duration `yaml:"idle_timeout" env-default:"60s"`
 		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+		ShutdownDelay   time.Duration `yaml:"shutdown_delay" env-default:"0s"` // time to keep serving after readiness starts failing
 		User            string        `yaml:"user" env-required:"true"`
 		Password        string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+		BaseURL         string        `yaml:"base_url" env:"HTTP_SERVER_BASE_URL"` // public address of short links, taken from the request when empty
+		TrustProxy      bool          `yaml:"trust_proxy"`                         // take the client address from X-Forwarded-For and X-Real-IP
+	}
+
+	Storage struct {
+		Backend      string        `yaml:"backend" env-default:"sqlite"`   // sqlite or redis
+		ReadTimeout  time.Duration `yaml:"read_timeout" env-default:"1s"`  // deadline for a single read query
+		WriteTimeout time.Duration `yaml:"write_timeout" env-default:"2s"` // deadline for a single write query
+		WAL          bool          `yaml:"wal" env-default:"true"`
+		BusyTimeout  time.Duration `yaml:"busy_timeout" env-default:"5s"`
+		Synchronous  string        `yaml:"synchronous" env-default:"NORMAL"` // OFF, NORMAL, FULL or EXTRA
+		MaxReadConns int           `yaml:"max_read_conns" env-default:"4"`
+		MaxIdleConns int           `yaml:"max_idle_conns" env-default:"4"`
+	}
+
+	Clicks struct {
+		FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"` // how long counted redirects wait before they are written
+		QueueSize     int           `yaml:"queue_size" env-default:"10000"`  // redirects beyond it are not counted
+		BatchSize     int           `yaml:"batch_size" env-default:"1000"`   // links written early once that many have clicks waiting
+	}
+
+	Cache struct {
+		Enabled     bool          `yaml:"enabled" env-default:"true"`
+		Size        int           `yaml:"size" env-default:"10000"`
+		TTL         time.Duration `yaml:"ttl" env-default:"5m"`
+		NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"30s"` // zero disables caching of unknown aliases
+	}
+
+	Redis struct {
+		Addr      string     `yaml:"addr" env-default:"localhost:6379"`
+		Username  string     `yaml:"username"`
+		Password  string     `yaml:"password" env:"REDIS_PASSWORD"`
+		DB        int        `yaml:"db"`
+		KeyPrefix string     `yaml:"key_prefix" env-default:"url-shortener:"`
+		Cache     RedisCache `yaml:"cache"`
+	}
+	RedisCache struct {
+		Enabled     bool          `yaml:"enabled"`
+		TTL         time.Duration `yaml:"ttl" env-default:"10m"`
+		NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"30s"`
+		Channel     string        `yaml:"channel" env-default:"url-shortener:invalidate"` // pub/sub channel for invalidations
+	}
+
+	Health struct {
+		CheckTimeout time.Duration `yaml:"check_timeout" env-default:"2s"`
+	}
+
+	Protection struct {
+		CookieSecret  string        `yaml:"cookie_secret" env:"PROTECTION_COOKIE_SECRET"` // random on every start when empty
+		CookieTTL     time.Duration `yaml:"cookie_ttl" env-default:"1h"`
+		MaxFailures   int           `yaml:"max_failures" env-default:"5"` // failed attempts per alias within failure_window
+		FailureWindow time.Duration `yaml:"failure_window" env-default:"15m"`
+	}
+
+	Schedule struct {
+		PendingURL  string `yaml:"pending_url"`  // where links redirect before their activation time
+		PendingPage string `yaml:"pending_page"` // HTML template served instead when pending_url is empty, built-in page when empty
+	}
+
+	Redirect struct {
+		DefaultType       string        `yaml:"default_type" env-default:"302"`        // 301, 302, 307, 308 or interstitial
+		PermanentMaxAge   time.Duration `yaml:"permanent_max_age" env-default:"24h"`   // Cache-Control max-age of 301 and 308 redirects
+		InterstitialDelay time.Duration `yaml:"interstitial_delay" env-default:"3s"`   // time before the interstitial page moves on
+		VariantCookieTTL  time.Duration `yaml:"variant_cookie_ttl" env-default:"720h"` // how long visitors stay on their A/B variant
+		Unfurl            bool          `yaml:"unfurl" env-default:"true"`             // serve Open Graph tags to chat app crawlers
+	}
+
+	GeoIP struct {
+		Database string `yaml:"database"` // CSV file with "start_ip,end_ip,country" ranges, country rules never match when empty
+	}
+
+	URLPolicy struct {
+		Schemes         []string `yaml:"schemes" env-default:"http,https"`
+		Allow           []string `yaml:"allow"`         // domain patterns like example.com or *.example.com, any domain when empty
+		Deny            []string `yaml:"deny"`          // domain patterns
+		ShortDomains    []string `yaml:"short_domains"` // in addition to the host of http_server.base_url
+		MaxLength       int      `yaml:"max_length" env-default:"2048"`
+		AllowHomographs bool     `yaml:"allow_homographs"` // accept hosts mixing look-alike letters of different scripts
+	}
+
+	AliasPolicy struct {
+		MinLength       int      `yaml:"min_length" env-default:"3"`
+		MaxLength       int      `yaml:"max_length" env-default:"64"`
+		Reserved        []string `yaml:"reserved" env-default:"api,admin,login,logout,assets"` // in addition to the first segments of the routes
+		ProfanityFile   string   `yaml:"profanity_file"`                                       // a word per line, not checked when empty
+		CaseInsensitive bool     `yaml:"case_insensitive"`                                     // /Promo and /promo are the same link, existing aliases are lower-cased on start
+		Unicode         bool     `yaml:"unicode"`                                              // allow letters of any script and hyphens
+		Suggestions     int      `yaml:"suggestions" env-default:"5"`                          // available aliases offered when the requested one is taken
+	}
+
+	Blocklist struct {
+		Feeds          []BlocklistFeed `yaml:"feeds"`
+		ReloadInterval time.Duration   `yaml:"reload_interval" env-default:"1m"` // how often the feed files are checked for changes, never when 0
+	}
+
+	BlocklistFeed struct {
+		Name   string `yaml:"name"` // shown on the warning page, like phishing or malware
+		Path   string `yaml:"path"`
+		Format string `yaml:"format"` // hosts, domains or urlhaus
+	}
+
+	LinkCheck struct {
+		Enabled       bool          `yaml:"enabled"`                   // periodically request the destinations of all links
+		Interval      time.Duration `yaml:"interval" env-default:"6h"` // between the starts of two rounds, must be positive
+		Workers       int           `yaml:"workers" env-default:"4"`
+		HostInterval  time.Duration `yaml:"host_interval" env-default:"1s"` // between two requests to the same host
+		Timeout       time.Duration `yaml:"timeout" env-default:"10s"`
+		MaxRedirects  int           `yaml:"max_redirects" env-default:"5"`
+		FallbackAfter int           `yaml:"fallback_after" env-default:"3"` // consecutive failures before the fallback URL is used, never when 0
+		UserAgent     string        `yaml:"user_agent" env-default:"url-shortener"`
+	}
+
+	PageFetch struct {
+		Enabled      bool          `yaml:"enabled" env-default:"true"` // fetch the title and Open Graph tags of new links
+		Workers      int           `yaml:"workers" env-default:"4"`
+		QueueSize    int           `yaml:"queue_size" env-default:"1000"` // links waiting beyond it are not fetched
+		Timeout      time.Duration `yaml:"timeout" env-default:"5s"`
+		MaxBytes     int64         `yaml:"max_bytes" env-default:"1048576"` // read from the start of the page
+		MaxRedirects int           `yaml:"max_redirects" env-default:"5"`
+		UserAgent    string        `yaml:"user_agent" env-default:"url-shortener"`
 	}
 
 	Log struct {
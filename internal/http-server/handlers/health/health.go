@@ -0,0 +1,207 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var (
+	ErrShuttingDown   = errors.New("shutting down")
+	ErrWorkersStopped = errors.New("workers are not running")
+)
+
+// CheckFunc reports whether a dependency of the service is healthy.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// CheckResult is the outcome of a single readiness check.
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// Checker runs the registered readiness checks.
+type Checker struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+// NewChecker creates a Checker that gives every check at most timeout to complete.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a named readiness check.
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Shutdown marks the service as shutting down, so that readiness starts failing
+// and load balancers stop sending new requests.
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Run executes all checks concurrently and reports whether all of them passed.
+func (c *Checker) Run(ctx context.Context) ([]CheckResult, bool) {
+	c.mu.RLock()
+	checks := append([]check{{name: "shutdown", fn: c.checkShutdown}}, c.checks...)
+	c.mu.RUnlock()
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func(i int, ch check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := ch.fn(ctx)
+
+			results[i] = CheckResult{
+				Name:    ch.name,
+				Status:  resp.StatusOk,
+				Latency: time.Since(start).String(),
+			}
+			if err != nil {
+				results[i].Status = resp.StatusError
+				results[i].Error = err.Error()
+			}
+		}(i, ch)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, r := range results {
+		if r.Status != resp.StatusOk {
+			ok = false
+		}
+	}
+
+	return results, ok
+}
+
+func (c *Checker) checkShutdown(_ context.Context) error {
+	if c.shuttingDown.Load() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// Workers tracks background workers that must be running for the service to be ready.
+type Workers struct {
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewWorkers() *Workers {
+	return &Workers{running: make(map[string]bool)}
+}
+
+// Started marks the named worker as running.
+func (w *Workers) Started(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running[name] = true
+}
+
+// Stopped marks the named worker as not running.
+func (w *Workers) Stopped(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running[name] = false
+}
+
+// Check fails if any registered worker is not running.
+func (w *Workers) Check(_ context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var stopped []string
+	for name, running := range w.running {
+		if !running {
+			stopped = append(stopped, name)
+		}
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("%w: %s", ErrWorkersStopped, strings.Join(stopped, ", "))
+	}
+
+	return nil
+}
+
+// NewLiveness reports that the process is alive and able to serve HTTP.
+func NewLiveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, resp.Ok())
+	}
+}
+
+// NewReadiness runs the readiness checks and responds with 503 if any of them failed.
+func NewReadiness(log *slog.Logger, checker *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.NewReadiness"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		results, ok := checker.Run(r.Context())
+
+		if !ok {
+			log.Warn("service is not ready", slog.Any("checks", results))
+
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, Response{
+				Response: resp.Error("service is not ready"),
+				Checks:   results,
+			})
+
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Checks:   results,
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/health"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		checkErr error
+		workers  func(w *health.Workers)
+		shutdown bool
+		code     int
+		failed   string
+	}{
+		{
+			name: "Ready",
+			code: http.StatusOK,
+		},
+		{
+			name:     "Storage Unavailable",
+			checkErr: errors.New("database is locked"),
+			code:     http.StatusServiceUnavailable,
+			failed:   "storage",
+		},
+		{
+			name: "Worker Stopped",
+			workers: func(w *health.Workers) {
+				w.Started("fetcher")
+				w.Stopped("fetcher")
+			},
+			code:   http.StatusServiceUnavailable,
+			failed: "workers",
+		},
+		{
+			name:     "Shutting Down",
+			shutdown: true,
+			code:     http.StatusServiceUnavailable,
+			failed:   "shutdown",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			workers := health.NewWorkers()
+			if tc.workers != nil {
+				tc.workers(workers)
+			}
+
+			checker := health.NewChecker(time.Second)
+			checker.Register("storage", func(context.Context) error { return tc.checkErr })
+			checker.Register("workers", workers.Check)
+			if tc.shutdown {
+				checker.Shutdown()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rr := httptest.NewRecorder()
+			health.NewReadiness(slogdiscard.NewDiscardLogger(), checker).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var body health.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			require.Len(t, body.Checks, 3)
+
+			for _, c := range body.Checks {
+				require.NotEmpty(t, c.Latency)
+				if c.Name == tc.failed {
+					require.Equal(t, resp.StatusError, c.Status)
+					require.NotEmpty(t, c.Error)
+				} else {
+					require.Equal(t, resp.StatusOk, c.Status, c.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessHandler_Timeout(t *testing.T) {
+	checker := health.NewChecker(10 * time.Millisecond)
+	checker.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rr := httptest.NewRecorder()
+	health.NewReadiness(slogdiscard.NewDiscardLogger(), checker).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func TestLivenessHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rr := httptest.NewRecorder()
+	health.NewLiveness().ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,186 @@
+package preview
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// States of a link as shown on the preview page.
+const (
+	StateActive    = "active"
+	StatePending   = "pending"   // not active yet
+	StateExpired   = "expired"   // past its activation window
+	StateExhausted = "exhausted" // no clicks left
+	StateBlocked   = "blocked"   // leads to a domain of a blocklist
+)
+
+type Response struct {
+	resp.Response
+	Alias     string            `json:"alias"`
+	URL       string            `json:"url,omitempty"`          // hidden for protected, limited and pending links
+	Variants  []Variant         `json:"variants,omitempty"`     // where visitors go instead of URL, hidden like URL
+	Fallback  string            `json:"fallback_url,omitempty"` // where visitors go while URL fails its checks, hidden like URL
+	Protected bool              `json:"protected"`
+	Varies    bool              `json:"varies,omitempty"` // the destination depends on the visitor
+	CreatedAt *time.Time        `json:"created_at,omitempty"`
+	Clicks    int64             `json:"clicks"`
+	State     string            `json:"state"`
+	Page      *storage.PageMeta `json:"page,omitempty"` // what the destination tells about itself, hidden like URL
+	Blocked   *blocklist.Match  `json:"blocked,omitempty"`
+}
+
+// Variant is a destination of a link splitting its traffic.
+type Variant struct {
+	URL   string `json:"url"`
+	Share int    `json:"share"` // percent of the visitors
+}
+
+// LinkGetter must read the storage directly, cached links may report stale click counts.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=LinkGetter --case=snake
+type LinkGetter interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+}
+
+//go:embed templates/preview.html
+var templates embed.FS
+
+var page = template.Must(template.ParseFS(templates, "templates/preview.html"))
+
+// New shows where a short link leads instead of following it, for routes like /{alias}+.
+// Clients asking for JSON in the Accept header get the same data as JSON. blocks may be nil.
+func New(log *slog.Logger, linkGetter LinkGetter, blocks *blocklist.List) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.preview.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		link, err := linkGetter.GetLink(r.Context(), alias)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		now := time.Now()
+		res := Response{
+			Response:  resp.Ok(),
+			Alias:     link.Alias,
+			Protected: link.Protected(),
+			Varies:    len(link.Rules) > 0 || len(link.Variants) > 0,
+			Clicks:    link.Clicks,
+			State:     state(link, now),
+		}
+		if !hidden(link, now) {
+			res.URL = link.URL
+			// visitors are sent to the variants first, then to the fallback, like in the redirect
+			switch {
+			case len(link.Variants) > 0:
+				res.Variants = variants(link.Variants)
+			case link.Health.Fallback && link.FallbackURL != "":
+				res.Fallback = link.FallbackURL
+			}
+			if !link.Page.FetchedAt.IsZero() {
+				res.Page = &link.Page
+			}
+		}
+		if !link.CreatedAt.IsZero() {
+			res.CreatedAt = &link.CreatedAt
+		}
+		if blocks != nil {
+			if m, ok := blocks.LookupLink(link); ok {
+				res.State = StateBlocked
+				res.Blocked = &m
+			}
+		}
+
+		// the click count changes with every visit
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Add("Vary", "Accept")
+
+		if render.GetAcceptedContentType(r) == render.ContentTypeJSON {
+			render.JSON(w, r, res)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		if err := page.Execute(w, res); err != nil {
+			log.Error("failed to render preview page", sl.Err(err))
+		}
+	}
+}
+
+// hidden reports whether the destination of link must not be shown: the preview would
+// let visitors skip the password, the click limit or the activation time.
+func hidden(link storage.Link, now time.Time) bool {
+	return link.Protected() || link.Limited() || link.Pending(now)
+}
+
+func variants(vs []storage.Variant) []Variant {
+	var total int
+	for _, v := range vs {
+		total += v.Weight
+	}
+
+	res := make([]Variant, 0, len(vs))
+	for _, v := range vs {
+		var share int
+		if total > 0 {
+			share = v.Weight * 100 / total
+		}
+		res = append(res, Variant{URL: v.URL, Share: share})
+	}
+
+	return res
+}
+
+func state(link storage.Link, now time.Time) string {
+	switch {
+	case link.Pending(now):
+		return StatePending
+	case link.Expired(now):
+		return StateExpired
+	case link.Limited() && link.ClicksLeft <= 0:
+		return StateExhausted
+	default:
+		return StateActive
+	}
+}
@@ -0,0 +1,202 @@
+package preview_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/preview"
+	"url-shortener/internal/http-server/handlers/preview/mocks"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		link   storage.Link
+		want   preview.Response
+		html   []string // fragments of the page
+		noHTML []string
+	}{
+		{
+			name: "Active",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com/?q=go", CreatedAt: created, Clicks: 42},
+			want: preview.Response{Alias: "abcd", URL: "https://google.com/?q=go", CreatedAt: &created, Clicks: 42, State: preview.StateActive},
+			html: []string{"https://google.com/?q=go", "2024-05-01 12:30 UTC", "42", `href="/abcd"`},
+		},
+		{
+			name:   "Protected",
+			link:   storage.Link{Alias: "abcd", URL: "https://google.com/secret", PasswordHash: "hash", Page: storage.PageMeta{Title: "Secret page", FetchedAt: created}},
+			want:   preview.Response{Alias: "abcd", Protected: true, State: preview.StateActive},
+			html:   []string{"password-protected", `href="/abcd"`},
+			noHTML: []string{"secret", "Secret page", "Created"},
+		},
+		{
+			name:   "Exhausted",
+			link:   storage.Link{Alias: "abcd", URL: "https://google.com", MaxClicks: 1, Clicks: 1},
+			want:   preview.Response{Alias: "abcd", Clicks: 1, State: preview.StateExhausted},
+			html:   []string{"no longer available"},
+			noHTML: []string{"Continue", "google.com"},
+		},
+		{
+			name:   "Limited",
+			link:   storage.Link{Alias: "abcd", URL: "https://google.com/once", MaxClicks: 1, ClicksLeft: 1, Page: storage.PageMeta{Title: "Once", FetchedAt: created}},
+			want:   preview.Response{Alias: "abcd", State: preview.StateActive},
+			html:   []string{"Continue"},
+			noHTML: []string{"google.com", "Once"},
+		},
+		{
+			name:   "Pending",
+			link:   storage.Link{Alias: "abcd", URL: "https://google.com/soon", NotBefore: time.Now().Add(time.Hour).UTC().Truncate(time.Second), Page: storage.PageMeta{Title: "Soon", FetchedAt: created}},
+			want:   preview.Response{Alias: "abcd", State: preview.StatePending},
+			html:   []string{"not active yet"},
+			noHTML: []string{"google.com", "Soon"},
+		},
+		{
+			name: "Fetched page",
+			link: storage.Link{Alias: "abcd", URL: "https://go.dev", Page: storage.PageMeta{Title: "Go", OGDescription: "Build systems", FetchedAt: created}},
+			want: preview.Response{Alias: "abcd", URL: "https://go.dev", State: preview.StateActive, Page: &storage.PageMeta{Title: "Go", OGDescription: "Build systems", FetchedAt: created}},
+			html: []string{"Go", "Build systems"},
+		},
+		{
+			name: "Variants",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", Variants: []storage.Variant{
+				{ID: 1, URL: "https://google.com/a", Weight: 1},
+				{ID: 2, URL: "https://google.com/b", Weight: 3},
+			}},
+			want: preview.Response{Alias: "abcd", URL: "https://google.com", Varies: true, State: preview.StateActive, Variants: []preview.Variant{
+				{URL: "https://google.com/a", Share: 25},
+				{URL: "https://google.com/b", Share: 75},
+			}},
+			html:   []string{"may differ", "split between", "https://google.com/a (25%)", "https://google.com/b (75%)"},
+			noHTML: []string{`break-all">https://google.com</p>`},
+		},
+		{
+			name: "Fallback",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com/gone", FallbackURL: "https://google.com/backup", Health: storage.LinkHealth{Failures: 3, Fallback: true}},
+			want: preview.Response{Alias: "abcd", URL: "https://google.com/gone", Fallback: "https://google.com/backup", State: preview.StateActive},
+			html: []string{`break-all">https://google.com/backup</p>`, "while https://google.com/gone is failing its checks"},
+		},
+		{
+			name:   "Fallback not in use",
+			link:   storage.Link{Alias: "abcd", URL: "https://google.com", FallbackURL: "https://google.com/backup"},
+			want:   preview.Response{Alias: "abcd", URL: "https://google.com", State: preview.StateActive},
+			noHTML: []string{"backup"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			linkGetterMock := mocks.NewLinkGetter(t)
+			linkGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(tc.link, nil)
+
+			r := chi.NewRouter()
+			r.Get("/{alias}+", preview.New(slogdiscard.NewDiscardLogger(), linkGetterMock, nil))
+
+			// JSON
+			req := httptest.NewRequest(http.MethodGet, "/abcd+", nil)
+			req.Header.Set("Accept", "application/json")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+
+			var got preview.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			tc.want.Response = resp.Ok()
+			if tc.want.CreatedAt == nil {
+				require.Nil(t, got.CreatedAt)
+			} else {
+				require.True(t, tc.want.CreatedAt.Equal(*got.CreatedAt))
+				got.CreatedAt = tc.want.CreatedAt
+			}
+			require.Equal(t, tc.want, got)
+
+			// HTML
+			req = httptest.NewRequest(http.MethodGet, "/abcd+", nil)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+			rr = httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+
+			body := rr.Body.String()
+			for _, s := range tc.html {
+				assert.Contains(t, body, s)
+			}
+			for _, s := range tc.noHTML {
+				assert.NotContains(t, body, s)
+			}
+		})
+	}
+}
+
+func TestPreviewHandler_NotFound(t *testing.T) {
+	linkGetterMock := mocks.NewLinkGetter(t)
+	linkGetterMock.On("GetLink", mock.Anything, "none").
+		Return(storage.Link{}, storage.ErrURLNotFound)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}+", preview.New(slogdiscard.NewDiscardLogger(), linkGetterMock, nil))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/none+", nil))
+
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPreviewHandler_Blocked(t *testing.T) {
+	feed := filepath.Join(t.TempDir(), "malware.hosts")
+	require.NoError(t, os.WriteFile(feed, []byte("0.0.0.0 evil.com\n"), 0o644))
+
+	list, err := blocklist.New([]blocklist.Feed{{Name: "malware", Path: feed, Format: blocklist.FormatHosts}})
+	require.NoError(t, err)
+
+	linkGetterMock := mocks.NewLinkGetter(t)
+	linkGetterMock.On("GetLink", mock.Anything, "abcd").
+		Return(storage.Link{Alias: "abcd", URL: "https://example.com", Variants: []storage.Variant{
+			{URL: "https://example.com/a", Weight: 1},
+			{URL: "https://cdn.evil.com/b", Weight: 1},
+		}}, nil)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}+", preview.New(slogdiscard.NewDiscardLogger(), linkGetterMock, list))
+
+	req := httptest.NewRequest(http.MethodGet, "/abcd+", nil)
+	req.Header.Set("Accept", "application/json")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var got preview.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, preview.StateBlocked, got.State)
+	assert.Equal(t, &blocklist.Match{Domain: "evil.com", Feed: "malware"}, got.Blocked)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd+", nil))
+
+	assert.Contains(t, rr.Body.String(), "evil.com is listed in the malware blocklist")
+	assert.NotContains(t, rr.Body.String(), "Continue")
+}
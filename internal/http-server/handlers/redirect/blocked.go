@@ -0,0 +1,24 @@
+package redirect
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/logger/sl"
+)
+
+var blockedPage = template.Must(template.ParseFS(templates, "templates/blocked.html"))
+
+// renderBlockedPage warns the visitor instead of sending them to a listed domain. The link is not shown.
+func renderBlockedPage(w http.ResponseWriter, log *slog.Logger, m blocklist.Match) {
+	// the feeds change, the answer must not outlive them
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+
+	if err := blockedPage.Execute(w, m); err != nil {
+		log.Error("failed to render blocked page", sl.Err(err))
+	}
+}
@@ -0,0 +1,45 @@
+package redirect
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+	"url-shortener/internal/storage"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// Pending configures what is served for a link before its activation time.
+type Pending struct {
+	URL  string             // redirect there when set
+	Page *template.Template // rendered otherwise, the built-in page when nil
+}
+
+// PendingPageData is passed to a custom pending page.
+type PendingPageData struct {
+	Alias     string
+	NotBefore time.Time // UTC
+}
+
+var pendingPage = template.Must(template.ParseFS(templates, "templates/pending.html"))
+
+func (p Pending) serve(w http.ResponseWriter, r *http.Request, log *slog.Logger, link storage.Link) {
+	if p.URL != "" {
+		http.Redirect(w, r, p.URL, http.StatusFound)
+
+		return
+	}
+
+	page := p.Page
+	if page == nil {
+		page = pendingPage
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+
+	if err := page.Execute(w, PendingPageData{Alias: link.Alias, NotBefore: link.NotBefore}); err != nil {
+		log.Error("failed to render pending page", sl.Err(err))
+	}
+}
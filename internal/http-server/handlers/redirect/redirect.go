@@ -1,27 +1,65 @@
 package redirect
 
 import (
+	"context"
+	"embed"
 	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 	"url-shortener/internal/storage"
 
 	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/forward"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/targeting"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 )
 
-// URLGetter is an interface for getting url by alias
+// URLGetter is an interface for getting a link by alias
 //
 //go:generate go run github.com/vektra/mockery/v2 --name=URLGetter --case=snake
 type URLGetter interface {
-	GetURL(alias string) (string, error)
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+// ClickConsumer takes a click from links with a click limit and counts the redirects of links and variants.
+// ConsumeClick must reach the storage itself, the count cannot be taken from a cache. CountClick runs
+// on every redirect and must not wait for the storage, see clicks.Batcher.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=ClickConsumer --case=snake
+type ClickConsumer interface {
+	ConsumeClick(ctx context.Context, alias string) (int64, error)
+	CountClick(ctx context.Context, alias string, variantID int64) error
+}
+
+//go:embed templates/*.html
+var templates embed.FS
+
+// Options configures the redirect handler.
+type Options struct {
+	Protection Protection
+	Pending    Pending
+	Geo        CountryLookup // nil disables country rules
+
+	VariantCookieTTL time.Duration // how long a visitor stays on the variant they were assigned to
+
+	DefaultType       string        // used for links without a redirect type
+	PermanentMaxAge   time.Duration // how long clients may cache permanent redirects
+	InterstitialDelay time.Duration // how long the interstitial page is shown before it moves on
+
+	Unfurl bool // serve Open Graph tags to chat app crawlers instead of the redirect
+
+	Blocklist *blocklist.List // warn instead of redirecting to listed domains, nil disables the check
+}
+
+func New(log *slog.Logger, urlGetter URLGetter, clicks ClickConsumer, opts Options) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.redirect.New"
 
@@ -39,7 +77,7 @@ func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
 			return
 		}
 
-		resURL, err := urlGetter.GetURL(alias)
+		link, err := urlGetter.GetLink(r.Context(), alias)
 		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("url not found", "alias", alias)
 
@@ -55,9 +93,159 @@ func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
 			return
 		}
 
-		log.Info("got url", slog.String("url", resURL))
+		if link.Protected() || link.Limited() || link.Scheduled() || len(link.Variants) > 0 {
+			// every visit has to reach the server, the answer must not be cached anywhere
+			w.Header().Set("Cache-Control", "no-store")
+		}
+
+		now := time.Now()
+
+		if link.Pending(now) {
+			log.Info("link is not active yet", slog.String("alias", alias))
+
+			opts.Pending.serve(w, r, log, link)
+
+			return
+		}
+
+		if link.Expired(now) {
+			log.Info("link has expired", slog.String("alias", alias))
+
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, resp.Error("link is no longer available"))
+
+			return
+		}
+
+		// unlock and variant cookies are scoped to the stored alias, browsers would not send them
+		// back for /ABC when the alias was normalized to abc
+		if link.Protected() || len(link.Variants) > 0 {
+			if path, ok := canonicalPath(r, link.Alias); !ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				log.Info("redirecting to the stored alias", slog.String("alias", alias))
+
+				if r.URL.RawQuery != "" {
+					path += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, path, http.StatusFound)
+
+				return
+			}
+		}
+
+		if link.Protected() && !opts.Protection.unlocked(r, link) {
+			log.Info("link is locked", slog.String("alias", alias))
+
+			renderUnlockPage(w, log, http.StatusUnauthorized, alias, "")
+
+			return
+		}
+
+		suffix := pathSuffix(r)
+		if !link.ForwardPath && strings.Trim(suffix, "/") != "" {
+			log.Info("path forwarding is disabled", slog.String("alias", alias))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+
+		destination, variantID := opts.destination(w, r, link)
+
+		target, err := forward.Target(destination, suffix, r.URL.RawQuery, link.QueryMode)
+		if errors.Is(err, forward.ErrInvalidSuffix) {
+			log.Info("invalid path suffix", slog.String("suffix", suffix))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to build target url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		if opts.Blocklist != nil {
+			if m, ok := opts.Blocklist.Lookup(target); ok {
+				log.Warn("destination is blocked", slog.String("alias", alias), slog.String("domain", m.Domain), slog.String("feed", m.Feed))
+
+				opts.Blocklist.Record(alias, target, m)
+				renderBlockedPage(w, log, m)
+
+				return
+			}
+		}
+
+		// previews must neither use up nor count clicks
+		if opts.Unfurl && targeting.Crawler(r.UserAgent()) {
+			log.Info("serving unfurl page", slog.String("alias", alias))
+
+			unfurl(w, log, link, target)
+
+			return
+		}
+
+		if link.Limited() {
+			left, err := clicks.ConsumeClick(r.Context(), alias)
+			if errors.Is(err, storage.ErrExhausted) {
+				log.Info("link has no clicks left", slog.String("alias", alias))
+
+				render.Status(r, http.StatusGone)
+				render.JSON(w, r, resp.Error("link is no longer available"))
+
+				return
+			}
+			if errors.Is(err, storage.ErrURLNotFound) {
+				log.Info("url not found", "alias", alias)
+
+				render.JSON(w, r, resp.Error("not found"))
+
+				return
+			}
+			if err != nil {
+				log.Error("failed to consume click", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+
+			log.Info("click consumed", slog.Int64("clicks_left", left))
+		}
+
+		// a lost click must not break the redirect
+		if err := clicks.CountClick(r.Context(), alias, variantID); err != nil {
+			log.Error("failed to count click", sl.Err(err))
+		}
+
+		log.Info("got url", slog.String("url", target))
 
 		// redirect to found url
-		http.Redirect(w, r, resURL, http.StatusFound)
+		opts.respond(w, r, log, link, target)
 	}
 }
+
+// canonicalPath returns the escaped request path with its first segment replaced by alias,
+// and whether the request already used it.
+func canonicalPath(r *http.Request, alias string) (string, bool) {
+	requested := "/" + strings.TrimPrefix(r.URL.EscapedPath(), "/")
+	suffix := pathSuffix(r)
+	path := "/" + url.PathEscape(alias) + suffix
+
+	return path, path == requested
+}
+
+// pathSuffix returns the escaped path following the alias, for routes like /{alias}/*.
+func pathSuffix(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/")
+
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[i:]
+	}
+
+	return ""
+}
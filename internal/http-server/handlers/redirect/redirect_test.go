@@ -1,19 +1,52 @@
 package redirect_test
 
 import (
+	"context"
+	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"url-shortener/internal/http-server/handlers/redirect"
 	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	"url-shortener/internal/lib/aliaspolicy"
 	"url-shortener/internal/lib/api"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/clicks"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/signer"
+	"url-shortener/internal/lib/throttle"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/sqlite"
 )
 
+func newProtection() redirect.Protection {
+	return redirect.Protection{
+		Signer:    signer.New([]byte("secret")),
+		Throttle:  throttle.New(3, time.Minute),
+		CookieTTL: time.Hour,
+	}
+}
+
+// newClickConsumer returns a mock that accepts any number of counted clicks.
+func newClickConsumer(t *testing.T) *mocks.ClickConsumer {
+	clicksMock := mocks.NewClickConsumer(t)
+	clicksMock.On("CountClick", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int64")).
+		Return(nil).
+		Maybe()
+
+	return clicksMock
+}
+
 func TestSaveHandler(t *testing.T) {
 	cases := []struct {
 		name      string
@@ -34,12 +67,12 @@ func TestSaveHandler(t *testing.T) {
 			urlGetterMock := mocks.NewURLGetter(t)
 
 			if tc.respError == "" || tc.mockError != nil {
-				urlGetterMock.On("GetURL", tc.alias).
-					Return(tc.url, tc.mockError).Once()
+				urlGetterMock.On("GetLink", mock.Anything, tc.alias).
+					Return(storage.Link{Alias: tc.alias, URL: tc.url}, tc.mockError).Once()
 			}
 
 			r := chi.NewRouter()
-			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock))
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t), redirect.Options{Protection: newProtection()}))
 
 			ts := httptest.NewServer(r)
 			defer ts.Close()
@@ -52,3 +85,848 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestLimitedLink(t *testing.T) {
+	cases := []struct {
+		name      string
+		mockError error
+		code      int
+	}{
+		{name: "Clicks left", code: http.StatusFound},
+		{name: "Exhausted", mockError: storage.ErrExhausted, code: http.StatusGone},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(storage.Link{Alias: "abcd", URL: "https://www.google.com/", MaxClicks: 1, ClicksLeft: 1}, nil).
+				Once()
+
+			clicksMock := newClickConsumer(t)
+			clicksMock.On("ConsumeClick", mock.Anything, "abcd").
+				Return(int64(0), tc.mockError).
+				Once()
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clicksMock, redirect.Options{Protection: newProtection()}))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd", nil))
+
+			require.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestScheduledLink(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name     string
+		link     storage.Link
+		pending  redirect.Pending
+		code     int
+		location string
+	}{
+		{
+			name:     "Active",
+			link:     storage.Link{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)},
+			code:     http.StatusFound,
+			location: "https://www.google.com/",
+		},
+		{
+			name: "Pending",
+			link: storage.Link{NotBefore: now.Add(time.Hour)},
+			code: http.StatusNotFound,
+		},
+		{
+			name:     "Pending with fallback",
+			link:     storage.Link{NotBefore: now.Add(time.Hour)},
+			pending:  redirect.Pending{URL: "https://example.com/soon"},
+			code:     http.StatusFound,
+			location: "https://example.com/soon",
+		},
+		{
+			name: "Expired",
+			link: storage.Link{NotAfter: now.Add(-time.Hour)},
+			code: http.StatusGone,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.link.Alias = "abcd"
+			tc.link.URL = "https://www.google.com/"
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(tc.link, nil).
+				Once()
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t), redirect.Options{
+				Protection: newProtection(),
+				Pending:    tc.pending,
+			}))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd", nil))
+
+			require.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.location, rr.Header().Get("Location"))
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestForwarding(t *testing.T) {
+	cases := []struct {
+		name     string
+		link     storage.Link
+		uri      string
+		code     int
+		location string
+	}{
+		{
+			name:     "Query is dropped by default",
+			link:     storage.Link{URL: "https://example.com/?a=1"},
+			uri:      "/abcd?utm_source=x",
+			code:     http.StatusFound,
+			location: "https://example.com/?a=1",
+		},
+		{
+			name:     "Query is merged",
+			link:     storage.Link{URL: "https://example.com/?a=1#top", QueryMode: "request"},
+			uri:      "/abcd?a=2&utm_source=x",
+			code:     http.StatusFound,
+			location: "https://example.com/?a=2&utm_source=x#top",
+		},
+		{
+			name:     "Trailing slash",
+			link:     storage.Link{URL: "https://example.com/docs"},
+			uri:      "/abcd/",
+			code:     http.StatusFound,
+			location: "https://example.com/docs",
+		},
+		{
+			name:     "Path is forwarded",
+			link:     storage.Link{URL: "https://example.com/docs", ForwardPath: true},
+			uri:      "/abcd/guide/file.pdf?page=2",
+			code:     http.StatusFound,
+			location: "https://example.com/docs/guide/file.pdf",
+		},
+		{
+			name: "Path forwarding is disabled",
+			link: storage.Link{URL: "https://example.com/docs"},
+			uri:  "/abcd/guide",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "Dot segments",
+			link: storage.Link{URL: "https://example.com/docs/", ForwardPath: true},
+			uri:  "/abcd/%2e%2e/admin",
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.link.Alias = "abcd"
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(tc.link, nil).
+				Once()
+
+			handler := redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t),
+				redirect.Options{Protection: newProtection()})
+
+			r := chi.NewRouter()
+			r.Use(middleware.URLFormat)
+			r.Get("/{alias}", handler)
+			r.Get("/{alias}/*", handler)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.uri, nil))
+
+			require.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.location, rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectTypes(t *testing.T) {
+	cases := []struct {
+		name         string
+		link         storage.Link
+		defaultType  string
+		method       string
+		code         int
+		cacheControl string
+		body         string
+	}{
+		{
+			name:         "Server default",
+			defaultType:  redirect.TypeMovedPermanently,
+			code:         http.StatusMovedPermanently,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Found",
+			link:         storage.Link{RedirectType: redirect.TypeFound},
+			defaultType:  redirect.TypeMovedPermanently,
+			code:         http.StatusFound,
+			cacheControl: "no-cache",
+		},
+		{
+			name:         "Permanent redirect",
+			link:         storage.Link{RedirectType: redirect.TypePermanentRedirect},
+			code:         http.StatusPermanentRedirect,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "Temporary redirect keeps POST",
+			link:         storage.Link{RedirectType: redirect.TypeTemporaryRedirect},
+			method:       http.MethodPost,
+			code:         http.StatusTemporaryRedirect,
+			cacheControl: "no-cache",
+		},
+		{
+			name:         "Permanent but limited",
+			link:         storage.Link{RedirectType: redirect.TypeMovedPermanently, MaxClicks: 10, ClicksLeft: 10},
+			code:         http.StatusMovedPermanently,
+			cacheControl: "no-store",
+		},
+		{
+			name:         "Interstitial",
+			link:         storage.Link{URL: "https://example.com/?a=1&b=2", RedirectType: redirect.TypeInterstitial},
+			code:         http.StatusOK,
+			cacheControl: "no-cache",
+			body:         `content="2;url=https://example.com/?a=1&amp;b=2"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.link.Alias = "abcd"
+			if tc.link.URL == "" {
+				tc.link.URL = "https://www.google.com/"
+			}
+			if tc.method == "" {
+				tc.method = http.MethodGet
+			}
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(tc.link, nil)
+
+			clicksMock := newClickConsumer(t)
+			if tc.link.Limited() {
+				clicksMock.On("ConsumeClick", mock.Anything, "abcd").
+					Return(int64(9), nil).
+					Once()
+			}
+
+			log := slogdiscard.NewDiscardLogger()
+			protection := newProtection()
+			handler := redirect.New(log, urlGetterMock, clicksMock, redirect.Options{
+				Protection:        protection,
+				DefaultType:       tc.defaultType,
+				PermanentMaxAge:   time.Hour,
+				InterstitialDelay: 2 * time.Second,
+			})
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", handler)
+			r.Post("/{alias}", redirect.NewUnlock(log, urlGetterMock, protection, handler))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(tc.method, "/abcd", nil))
+
+			require.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.cacheControl, rr.Header().Get("Cache-Control"))
+			if tc.body != "" {
+				assert.Contains(t, rr.Body.String(), tc.body)
+			} else {
+				assert.Equal(t, tc.link.URL, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+type countryLookup map[string]string
+
+func (c countryLookup) Country(addr netip.Addr) string {
+	return c[addr.String()]
+}
+
+func TestTargetingRules(t *testing.T) {
+	link := storage.Link{
+		Alias:        "abcd",
+		URL:          "https://example.com/",
+		RedirectType: redirect.TypeMovedPermanently,
+		Rules: []storage.Rule{
+			{ID: 1, Position: 1, Platform: "ios", URL: "https://apps.apple.com/app"},
+			{ID: 2, Position: 2, Platform: "android", URL: "https://play.google.com/app"},
+			{ID: 3, Position: 3, Country: "DE", URL: "https://example.de/"},
+			{ID: 4, Position: 4, Language: "fr", URL: "https://example.com/fr"},
+		},
+	}
+
+	cases := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		remoteAddr     string
+		location       string
+	}{
+		{
+			name:      "iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)",
+			location:  "https://apps.apple.com/app",
+		},
+		{
+			name:      "Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8)",
+			location:  "https://play.google.com/app",
+		},
+		{
+			name:       "Country",
+			remoteAddr: "198.51.100.7:1234",
+			location:   "https://example.de/",
+		},
+		{
+			name:           "Language",
+			acceptLanguage: "fr-CH, fr;q=0.9, en;q=0.8",
+			location:       "https://example.com/fr",
+		},
+		{
+			name:      "Default",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+			location:  "https://example.com/",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(link, nil)
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t), redirect.Options{
+				Protection:      newProtection(),
+				Geo:             countryLookup{"198.51.100.7": "DE"},
+				PermanentMaxAge: time.Hour,
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+			req.Header.Set("User-Agent", tc.userAgent)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			if tc.remoteAddr != "" {
+				req.RemoteAddr = tc.remoteAddr
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusMovedPermanently, rr.Code)
+			assert.Equal(t, tc.location, rr.Header().Get("Location"))
+			assert.Equal(t, "private, max-age=3600", rr.Header().Get("Cache-Control"))
+			assert.Equal(t, "User-Agent, Accept-Language", rr.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	link := storage.Link{
+		Alias: "abcd",
+		URL:   "https://example.com/",
+		Variants: []storage.Variant{
+			{ID: 1, URL: "https://example.com/a", Weight: 1},
+			{ID: 2, URL: "https://example.com/b", Weight: 1},
+		},
+		Rules: []storage.Rule{{ID: 1, Platform: "ios", URL: "https://apps.apple.com/app"}},
+	}
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "abcd").
+		Return(link, nil)
+
+	var clicks int
+	var counted []int64
+	clicksMock := mocks.NewClickConsumer(t)
+	clicksMock.On("CountClick", mock.Anything, "abcd", mock.AnythingOfType("int64")).
+		Run(func(args mock.Arguments) {
+			clicks++
+			if id := args.Get(2).(int64); id != 0 {
+				counted = append(counted, id)
+			}
+		}).
+		Return(nil)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clicksMock, redirect.Options{
+		Protection:       newProtection(),
+		VariantCookieTTL: time.Hour,
+	}))
+
+	// a new visitor is assigned to a variant and keeps it
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd", nil))
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/abcd", cookies[0].Path)
+	assert.Equal(t, 3600, cookies[0].MaxAge)
+	first := rr.Header().Get("Location")
+	assert.Contains(t, []string{"https://example.com/a", "https://example.com/b"}, first)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+		req.AddCookie(cookies[0])
+
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, first, rr.Header().Get("Location"))
+		require.Empty(t, rr.Result().Cookies())
+	}
+
+	// an unknown variant is replaced
+	req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+	req.AddCookie(&http.Cookie{Name: "variant", Value: "42"})
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Len(t, rr.Result().Cookies(), 1)
+
+	require.Len(t, counted, 7)
+	for _, id := range counted[:6] {
+		require.Equal(t, counted[0], id)
+	}
+
+	// targeting rules win over variants, only the link counts the click
+	req = httptest.NewRequest(http.MethodGet, "/abcd", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, "https://apps.apple.com/app", rr.Header().Get("Location"))
+	require.Len(t, counted, 7)
+	require.Equal(t, 8, clicks)
+}
+
+func TestVariants_UnicodeAlias(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "привет").
+		Return(storage.Link{Alias: "привет", URL: "https://example.com/", Variants: []storage.Variant{
+			{ID: 1, URL: "https://example.com/a", Weight: 1},
+			{ID: 2, URL: "https://example.com/b", Weight: 1},
+		}}, nil)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t), redirect.Options{
+		Protection:       newProtection(),
+		VariantCookieTTL: time.Hour,
+	}))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82", nil))
+	require.Equal(t, http.StatusFound, rr.Code)
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82", cookies[0].Path)
+}
+
+func TestVariants_CaseInsensitive(t *testing.T) {
+	aliases, err := aliaspolicy.New(aliaspolicy.Config{CaseInsensitive: true})
+	require.NoError(t, err)
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "abcd").
+		Return(storage.Link{Alias: "abcd", URL: "https://example.com/", ForwardPath: true, Variants: []storage.Variant{
+			{ID: 1, URL: "https://example.com/a", Weight: 1},
+			{ID: 2, URL: "https://example.com/b", Weight: 1},
+		}}, nil)
+
+	handler := redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t), redirect.Options{
+		Protection:       newProtection(),
+		VariantCookieTTL: time.Hour,
+	})
+	r := chi.NewRouter()
+	r.With(aliases.NormalizeParam).Get("/{alias}", handler)
+	r.With(aliases.NormalizeParam).Get("/{alias}/*", handler)
+
+	// the variant cookie would never come back for /ABCD, the visitor is sent to the stored alias first
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ABCD/docs?q=1", nil))
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "/abcd/docs?q=1", rr.Header().Get("Location"))
+	assert.Empty(t, rr.Result().Cookies())
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd", nil))
+	require.Equal(t, http.StatusFound, rr.Code)
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/abcd", cookies[0].Path)
+	first := rr.Header().Get("Location")
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+		req.AddCookie(cookies[0])
+
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, first, rr.Header().Get("Location"))
+	}
+}
+
+func TestVariants_Weights(t *testing.T) {
+	link := storage.Link{
+		Alias: "abcd",
+		URL:   "https://example.com/",
+		Variants: []storage.Variant{
+			{ID: 1, URL: "https://example.com/a", Weight: 1},
+			{ID: 2, URL: "https://example.com/b", Weight: 3},
+		},
+	}
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "abcd").
+		Return(link, nil)
+	clicksMock := mocks.NewClickConsumer(t)
+	clicksMock.On("CountClick", mock.Anything, "abcd", mock.AnythingOfType("int64")).
+		Return(nil)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clicksMock, redirect.Options{Protection: newProtection()}))
+
+	const visits = 4000
+	hits := map[string]int{}
+	for i := 0; i < visits; i++ {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd", nil))
+		hits[rr.Header().Get("Location")]++
+	}
+
+	// 3/4 of the traffic with a generous margin
+	assert.InDelta(t, 0.75, float64(hits["https://example.com/b"])/visits, 0.05)
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	s, err := sqlite.New(filepath.Join(b.TempDir(), "storage.db"), sqlite.Options{
+		WAL:          true,
+		BusyTimeout:  time.Second,
+		Synchronous:  "NORMAL",
+		MaxReadConns: 4,
+		MaxIdleConns: 4,
+	})
+	require.NoError(b, err)
+	defer func() { _ = s.Close() }()
+
+	_, err = s.SaveLink(context.Background(), storage.Link{Alias: "bench", URL: "https://www.google.com/"})
+	require.NoError(b, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batcher := clicks.New(slogdiscard.NewDiscardLogger(), s, clicks.Options{FlushInterval: time.Second, QueueSize: 10000, BatchSize: 1000})
+	go batcher.Run(ctx)
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), s, batcher, redirect.Options{Protection: newProtection()}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/bench", nil))
+
+			if rr.Code != http.StatusFound {
+				b.Errorf("unexpected status %d", rr.Code)
+				return
+			}
+		}
+	})
+}
+
+func TestUnfurl(t *testing.T) {
+	const slackbot = "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+
+	page := storage.PageMeta{
+		Title:         "Example page",
+		OGDescription: "Fetched description",
+		OGImage:       "https://example.com/fetched.png",
+		FetchedAt:     time.Now(),
+	}
+
+	cases := []struct {
+		name      string
+		link      storage.Link
+		userAgent string
+		disabled  bool
+		code      int
+		vary      string
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name: "Custom tags",
+			link: storage.Link{
+				Meta: storage.Meta{OGTitle: "Custom title", OGImage: "https://example.com/custom.png"},
+				Page: page,
+			},
+			userAgent: slackbot,
+			code:      http.StatusOK,
+			vary:      "User-Agent",
+			contains: []string{
+				`<meta property="og:title" content="Custom title" />`,
+				`<meta property="og:description" content="Fetched description" />`,
+				`<meta property="og:image" content="https://example.com/custom.png" />`,
+				`<meta property="og:url" content="https://example.com/" />`,
+				`content="summary_large_image"`,
+			},
+		},
+		{
+			name:      "Fetched tags",
+			link:      storage.Link{Page: page},
+			userAgent: "facebookexternalhit/1.1",
+			code:      http.StatusOK,
+			vary:      "User-Agent",
+			contains:  []string{`<title>Example page</title>`, `https://example.com/fetched.png`},
+		},
+		{
+			name:      "Nothing known",
+			link:      storage.Link{},
+			userAgent: "Twitterbot/1.0",
+			code:      http.StatusOK,
+			vary:      "User-Agent",
+			contains:  []string{`<meta property="og:title" content="https://example.com/" />`, `content="summary"`},
+			excludes:  []string{"og:description", "og:image"},
+		},
+		{
+			name:      "Limited link does not lose clicks",
+			link:      storage.Link{MaxClicks: 1, ClicksLeft: 1, Page: page},
+			userAgent: slackbot,
+			code:      http.StatusOK,
+			vary:      "User-Agent",
+			contains:  []string{`<title>abcd</title>`},
+			excludes:  []string{"https://example.com/", "og:url", "href=", "http-equiv", "Example page", "Fetched description"},
+		},
+		{
+			name:      "Limited link keeps its own tags",
+			link:      storage.Link{MaxClicks: 1, ClicksLeft: 1, Meta: storage.Meta{OGTitle: "Custom title", OGImage: "https://cdn.example.org/custom.png"}, Page: page},
+			userAgent: slackbot,
+			code:      http.StatusOK,
+			vary:      "User-Agent",
+			contains:  []string{`<meta property="og:title" content="Custom title" />`, `https://cdn.example.org/custom.png`},
+			excludes:  []string{"https://example.com/", "http-equiv"},
+		},
+		{
+			name:      "Variants are not revealed",
+			link:      storage.Link{Variants: []storage.Variant{{ID: 1, URL: "https://example.com/a", Weight: 1}, {ID: 2, URL: "https://example.com/b", Weight: 1}}},
+			userAgent: slackbot,
+			code:      http.StatusOK,
+			vary:      "User-Agent",
+			excludes:  []string{"https://example.com/", "og:url", "href=", "http-equiv"},
+		},
+		{
+			name:      "Protected link stays locked",
+			link:      storage.Link{PasswordHash: "hash", Meta: storage.Meta{OGTitle: "Secret"}},
+			userAgent: slackbot,
+			code:      http.StatusUnauthorized,
+			excludes:  []string{"Secret", "https://example.com/"},
+		},
+		{
+			name:      "Browser",
+			link:      storage.Link{Page: page},
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+			code:      http.StatusFound,
+			vary:      "User-Agent",
+		},
+		{
+			name:      "Disabled",
+			link:      storage.Link{Page: page},
+			userAgent: slackbot,
+			disabled:  true,
+			code:      http.StatusFound,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			link := tc.link
+			link.Alias = "abcd"
+			link.URL = "https://example.com/"
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(link, nil).
+				Once()
+
+			// crawlers must not reach the click consumer at all
+			clicksMock := mocks.NewClickConsumer(t)
+			if tc.code == http.StatusFound {
+				clicksMock = newClickConsumer(t)
+			}
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clicksMock, redirect.Options{
+				Protection: newProtection(),
+				Unfurl:     !tc.disabled,
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+			req.Header.Set("User-Agent", tc.userAgent)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.vary, rr.Header().Get("Vary"))
+
+			body := rr.Body.String()
+			for _, s := range tc.contains {
+				assert.Contains(t, body, s)
+			}
+			for _, s := range tc.excludes {
+				assert.NotContains(t, body, s)
+			}
+		})
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	feed := filepath.Join(t.TempDir(), "phishing.txt")
+	require.NoError(t, os.WriteFile(feed, []byte("evil.com\n"), 0o644))
+
+	list, err := blocklist.New([]blocklist.Feed{{Name: "phishing", Path: feed, Format: blocklist.FormatDomains}})
+	require.NoError(t, err)
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "evil").
+		Return(storage.Link{Alias: "evil", URL: "https://login.evil.com/", MaxClicks: 5, ClicksLeft: 5}, nil)
+	urlGetterMock.On("GetLink", mock.Anything, "good").
+		Return(storage.Link{Alias: "good", URL: "https://example.com/"}, nil)
+
+	// blocked visits neither use up nor count clicks
+	clicksMock := mocks.NewClickConsumer(t)
+	clicksMock.On("CountClick", mock.Anything, "good", int64(0)).
+		Return(nil).
+		Once()
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, clicksMock, redirect.Options{
+		Protection: newProtection(),
+		Unfurl:     true,
+		Blocklist:  list,
+	}))
+
+	for _, ua := range []string{"Mozilla/5.0 (X11; Linux x86_64)", "Slackbot-LinkExpanding 1.0"} {
+		req := httptest.NewRequest(http.MethodGet, "/evil", nil)
+		req.Header.Set("User-Agent", ua)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		require.Equal(t, http.StatusForbidden, rr.Code, ua)
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		assert.Contains(t, rr.Body.String(), "evil.com")
+		assert.Contains(t, rr.Body.String(), "phishing")
+		assert.NotContains(t, rr.Body.String(), "https://login.evil.com/")
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/good", nil))
+	require.Equal(t, http.StatusFound, rr.Code)
+
+	hits := list.Hits()
+	require.Len(t, hits, 1)
+	assert.Equal(t, "evil", hits[0].Alias)
+	assert.Equal(t, int64(2), hits[0].Count)
+	assert.Equal(t, blocklist.Match{Domain: "evil.com", Feed: "phishing"}, hits[0].Match)
+}
+
+func TestFallback(t *testing.T) {
+	cases := []struct {
+		name   string
+		link   storage.Link
+		target string
+	}{
+		{
+			name:   "Healthy",
+			link:   storage.Link{URL: "https://example.com/", FallbackURL: "https://archive.org/"},
+			target: "https://example.com/",
+		},
+		{
+			name:   "Failing but below the threshold",
+			link:   storage.Link{URL: "https://example.com/", FallbackURL: "https://archive.org/", Health: storage.LinkHealth{Failures: 1}},
+			target: "https://example.com/",
+		},
+		{
+			name:   "Broken",
+			link:   storage.Link{URL: "https://example.com/", FallbackURL: "https://archive.org/", Health: storage.LinkHealth{Failures: 3, Fallback: true}},
+			target: "https://archive.org/",
+		},
+		{
+			name:   "Targeting rules win",
+			link:   storage.Link{URL: "https://example.com/", FallbackURL: "https://archive.org/", Health: storage.LinkHealth{Failures: 3, Fallback: true}, Rules: []storage.Rule{{ID: 1, URL: "https://example.com/any"}}},
+			target: "https://example.com/any",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.link.Alias = "abcd"
+
+			urlGetterMock := mocks.NewURLGetter(t)
+			urlGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(tc.link, nil).
+				Once()
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, newClickConsumer(t), redirect.Options{Protection: newProtection()}))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abcd", nil))
+
+			require.Equal(t, http.StatusFound, rr.Code)
+			assert.Equal(t, tc.target, rr.Header().Get("Location"))
+		})
+	}
+}
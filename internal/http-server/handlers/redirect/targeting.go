@@ -0,0 +1,71 @@
+package redirect
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+
+	"url-shortener/internal/lib/targeting"
+	"url-shortener/internal/storage"
+)
+
+// CountryLookup resolves the country of a visitor, see lib/geoip.
+type CountryLookup interface {
+	Country(addr netip.Addr) string
+}
+
+// destination picks the URL for the visitor: the first matching targeting rule wins,
+// then one of the variants, then the link's own URL, or its fallback URL while the link checker
+// finds it broken. variantID is zero unless a variant was picked.
+func (o Options) destination(w http.ResponseWriter, r *http.Request, link storage.Link) (url string, variantID int64) {
+	if len(link.Rules) > 0 {
+		v := targeting.Visitor{
+			Platform:  targeting.Platform(r.UserAgent()),
+			Languages: targeting.Languages(r.Header.Get("Accept-Language")),
+		}
+		if o.Geo != nil && hasCountryRule(link.Rules) {
+			if addr, ok := remoteAddr(r); ok {
+				v.Country = o.Geo.Country(addr)
+			}
+		}
+
+		if rule, ok := targeting.Match(link.Rules, v); ok {
+			return rule.URL, 0
+		}
+	}
+
+	if len(link.Variants) > 0 {
+		variant := o.assignVariant(w, r, link)
+
+		return variant.URL, variant.ID
+	}
+
+	if link.Health.Fallback && link.FallbackURL != "" {
+		return link.FallbackURL, 0
+	}
+
+	return link.URL, 0
+}
+
+func hasCountryRule(rules []storage.Rule) bool {
+	for _, rule := range rules {
+		if rule.Country != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteAddr parses r.RemoteAddr. It holds a bare address when it was set by middleware.RealIP,
+// which is only installed with http_server.trust_proxy, the address of the proxy otherwise.
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+
+	return addr, err == nil
+}
@@ -0,0 +1,100 @@
+package redirect
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+)
+
+// Redirect types stored with a link.
+const (
+	TypeMovedPermanently  = "301"
+	TypeFound             = "302"
+	TypeTemporaryRedirect = "307" // keeps the method and the body
+	TypePermanentRedirect = "308" // keeps the method and the body
+	TypeInterstitial      = "interstitial"
+)
+
+var typeStatus = map[string]int{
+	TypeMovedPermanently:  http.StatusMovedPermanently,
+	TypeFound:             http.StatusFound,
+	TypeTemporaryRedirect: http.StatusTemporaryRedirect,
+	TypePermanentRedirect: http.StatusPermanentRedirect,
+}
+
+// ValidType reports whether t is a known redirect type.
+func ValidType(t string) bool {
+	_, ok := typeStatus[t]
+
+	return ok || t == TypeInterstitial
+}
+
+var interstitialPage = template.Must(template.ParseFS(templates, "templates/interstitial.html"))
+
+// respond sends the client to target the way the redirect type asks for.
+// A Cache-Control header set before is kept, links checked on every visit set it to no-store.
+func (o Options) respond(w http.ResponseWriter, r *http.Request, log *slog.Logger, link storage.Link, target string) {
+	redirectType := link.RedirectType
+	if redirectType == "" {
+		redirectType = o.DefaultType
+	}
+
+	status, ok := typeStatus[redirectType]
+	if !ok && redirectType != TypeInterstitial {
+		status = http.StatusFound
+	}
+
+	scope := "public"
+	if len(link.Rules) > 0 {
+		// the target depends on the visitor, country rules cannot even be expressed with Vary
+		w.Header().Add("Vary", "User-Agent, Accept-Language")
+		scope = "private"
+	} else if o.Unfurl {
+		// crawlers get the unfurl page instead
+		w.Header().Add("Vary", "User-Agent")
+	}
+
+	if w.Header().Get("Cache-Control") == "" {
+		if status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect {
+			w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int(o.PermanentMaxAge.Seconds())))
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+	}
+
+	if redirectType != TypeInterstitial {
+		http.Redirect(w, r, target, status)
+
+		return
+	}
+
+	data := struct {
+		Target  string
+		Delay   int
+		Refresh bool
+	}{
+		Target: target,
+		Delay:  int(o.InterstitialDelay / time.Second),
+		// html/template sanitizes the link but not the refresh header, so only well-known schemes refresh
+		Refresh: isHTTP(target),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if err := interstitialPage.Execute(w, data); err != nil {
+		log.Error("failed to render interstitial page", sl.Err(err))
+	}
+}
+
+func isHTTP(target string) bool {
+	u, err := url.Parse(target)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
@@ -0,0 +1,73 @@
+package redirect
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/storage"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+var unfurlPage = template.Must(template.ParseFS(templates, "templates/unfurl.html"))
+
+// unfurlData is what chat app crawlers see of a link: the Open Graph tags set for the link,
+// else the ones fetched from the destination.
+type unfurlData struct {
+	Target      string // empty when the destination must not be revealed
+	Title       string
+	Description string
+	Image       string
+	Refresh     bool
+}
+
+func newUnfurlData(link storage.Link, target string) unfurlData {
+	if hideTarget(link) {
+		return unfurlData{
+			Title:       first(link.OGTitle, link.Alias),
+			Description: link.OGDescription,
+			Image:       link.OGImage,
+		}
+	}
+
+	return unfurlData{
+		Target:      target,
+		Title:       first(link.OGTitle, link.Page.OGTitle, link.Page.Title, target),
+		Description: first(link.OGDescription, link.Page.OGDescription),
+		Image:       first(link.OGImage, link.Page.OGImage),
+		Refresh:     isHTTP(target),
+	}
+}
+
+// hideTarget reports whether crawlers must only see the tags set for link. Chat apps show
+// the page to everyone in the channel, who could skip the password, the click limit or
+// the variant assignment by following the destination directly. Pending links never get
+// here, crawlers see the pending page like everyone else.
+func hideTarget(link storage.Link) bool {
+	return link.Protected() || link.Limited() || len(link.Variants) > 0
+}
+
+// unfurl serves the preview page to a crawler instead of redirecting it.
+// The answer depends on the User-Agent, so redirects say so with Vary too.
+func unfurl(w http.ResponseWriter, log *slog.Logger, link storage.Link, target string) {
+	w.Header().Add("Vary", "User-Agent")
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	if err := unfurlPage.Execute(w, newUnfurlData(link, target)); err != nil {
+		log.Error("failed to render unfurl page", sl.Err(err))
+	}
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
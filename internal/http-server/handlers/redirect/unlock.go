@@ -0,0 +1,164 @@
+package redirect
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"url-shortener/internal/storage"
+
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/lib/signer"
+	"url-shortener/internal/lib/throttle"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Protection holds what is needed to serve password-protected links.
+type Protection struct {
+	Signer    *signer.Signer     // signs unlock cookies
+	Throttle  *throttle.Throttle // limits failed attempts per alias
+	CookieTTL time.Duration      // how long an unlocked link stays open
+}
+
+// cookieName is the unlock cookie. It is scoped to the path of one alias.
+const cookieName = "unlock"
+
+// maxFormSize limits the unlock form, a password is at most 72 bytes anyway.
+const maxFormSize = 4 << 10
+
+var unlockPage = template.Must(template.ParseFS(templates, "templates/unlock.html"))
+
+// NewUnlock verifies the password posted from the unlock page. On success it
+// issues a signed cookie and redirects back to the alias. Requests for links that
+// are not protected are passed to next, so that 307 and 308 redirects work for POST.
+func NewUnlock(log *slog.Logger, urlGetter URLGetter, protection Protection, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.redirect.NewUnlock"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		link, err := urlGetter.GetLink(r.Context(), alias)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", "alias", alias)
+
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		if !link.Protected() {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		now := time.Now()
+
+		// the attempt counts as failed until the password is verified, so that parallel guesses
+		// cannot all pass the throttle while bcrypt is running
+		if ok, wait := protection.Throttle.Attempt(alias, now); !ok {
+			log.Warn("too many failed attempts", slog.String("alias", alias))
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			renderUnlockPage(w, log, http.StatusTooManyRequests, alias, "Too many attempts, try again later")
+
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := r.ParseForm(); err != nil {
+			log.Info("failed to parse form", sl.Err(err))
+
+			renderUnlockPage(w, log, http.StatusBadRequest, alias, "Invalid request")
+
+			return
+		}
+
+		if !password.Verify(link.PasswordHash, r.PostForm.Get("password")) {
+			log.Info("wrong password", slog.String("alias", alias))
+
+			renderUnlockPage(w, log, http.StatusUnauthorized, alias, "Wrong password")
+
+			return
+		}
+
+		protection.Throttle.Reset(alias)
+
+		// cookie paths are matched against the escaped request path
+		path := "/" + url.PathEscape(alias)
+		expires := now.Add(protection.CookieTTL)
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    protection.Signer.Sign(unlockSubject(link), expires),
+			Path:     path,
+			Expires:  expires,
+			MaxAge:   int(protection.CookieTTL.Seconds()),
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		log.Info("link unlocked", slog.String("alias", alias))
+
+		http.Redirect(w, r, path, http.StatusSeeOther)
+	}
+}
+
+// unlocked reports whether the request carries a valid unlock cookie for link.
+func (p Protection) unlocked(r *http.Request, link storage.Link) bool {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return false
+	}
+
+	return p.Signer.Verify(cookie.Value, unlockSubject(link), time.Now())
+}
+
+// unlockSubject binds the cookie to the password hash, so that changing
+// the password or reusing the alias invalidates previously issued cookies.
+func unlockSubject(link storage.Link) string {
+	return link.Alias + "\x00" + link.PasswordHash
+}
+
+func renderUnlockPage(w http.ResponseWriter, log *slog.Logger, status int, alias, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	data := struct {
+		Alias string
+		Error string
+	}{Alias: alias, Error: errMsg}
+
+	if err := unlockPage.Execute(w, data); err != nil {
+		log.Error("failed to render unlock page", sl.Err(err))
+	}
+}
@@ -0,0 +1,154 @@
+package redirect_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/redirect"
+	"url-shortener/internal/http-server/handlers/redirect/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/storage"
+)
+
+func newProtectedRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	hash, err := password.Hash("secret")
+	require.NoError(t, err)
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "abcd").
+		Return(storage.Link{Alias: "abcd", URL: "https://www.google.com/", PasswordHash: hash}, nil).
+		Maybe()
+
+	protection := newProtection()
+	log := slogdiscard.NewDiscardLogger()
+
+	handler := redirect.New(log, urlGetterMock, newClickConsumer(t), redirect.Options{Protection: protection})
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", handler)
+	r.Post("/{alias}", redirect.NewUnlock(log, urlGetterMock, protection, handler))
+
+	return r
+}
+
+func get(h http.Handler, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/abcd", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func unlock(h http.Handler, pass string) *httptest.ResponseRecorder {
+	form := url.Values{"password": {pass}}
+	req := httptest.NewRequest(http.MethodPost, "/abcd", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestProtectedLink(t *testing.T) {
+	h := newProtectedRouter(t)
+
+	rr := get(h)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), `action="/abcd"`)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+	rr = unlock(h, "wrong")
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Wrong password")
+	assert.Empty(t, rr.Result().Cookies())
+
+	rr = unlock(h, "secret")
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/abcd", rr.Header().Get("Location"))
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/abcd", cookies[0].Path)
+	assert.True(t, cookies[0].HttpOnly)
+
+	rr = get(h, cookies[0])
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://www.google.com/", rr.Header().Get("Location"))
+
+	forged := *cookies[0]
+	forged.Value += "x"
+	rr = get(h, &forged)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestProtectedLink_UnicodeAlias(t *testing.T) {
+	hash, err := password.Hash("secret")
+	require.NoError(t, err)
+
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "привет").
+		Return(storage.Link{Alias: "привет", URL: "https://www.google.com/", PasswordHash: hash}, nil)
+
+	protection := newProtection()
+	log := slogdiscard.NewDiscardLogger()
+	handler := redirect.New(log, urlGetterMock, newClickConsumer(t), redirect.Options{Protection: protection})
+
+	r := chi.NewRouter()
+	r.Get("/{alias}", handler)
+	r.Post("/{alias}", redirect.NewUnlock(log, urlGetterMock, protection, handler))
+
+	const path = "/%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82"
+
+	form := url.Values{"password": {"secret"}}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, path, rr.Header().Get("Location"))
+
+	// browsers compare the path of the cookie with the escaped request path
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, path, cookies[0].Path)
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://www.google.com/", rr.Header().Get("Location"))
+}
+
+func TestProtectedLink_Throttle(t *testing.T) {
+	h := newProtectedRouter(t)
+
+	for i := 0; i < 3; i++ {
+		rr := unlock(h, "wrong")
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	// even the right password is rejected until the window ends
+	rr := unlock(h, "secret")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	assert.Empty(t, rr.Result().Cookies())
+}
@@ -0,0 +1,62 @@
+package redirect
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"url-shortener/internal/storage"
+)
+
+// variantCookie remembers the variant a visitor was assigned to, so that they do not flip between variants.
+const variantCookie = "variant"
+
+// assignVariant returns the variant from the visitor's cookie while it still exists,
+// otherwise it picks one by weight and remembers it in the cookie.
+func (o Options) assignVariant(w http.ResponseWriter, r *http.Request, link storage.Link) storage.Variant {
+	if cookie, err := r.Cookie(variantCookie); err == nil {
+		if id, err := strconv.ParseInt(cookie.Value, 10, 64); err == nil {
+			for _, v := range link.Variants {
+				if v.ID == id {
+					return v
+				}
+			}
+		}
+	}
+
+	variant := pickVariant(link.Variants, rand.Int63n)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookie,
+		Value:    strconv.FormatInt(variant.ID, 10),
+		Path:     "/" + url.PathEscape(link.Alias), // matched against the escaped request path
+		MaxAge:   int(o.VariantCookieTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return variant
+}
+
+// pickVariant picks a variant with a probability proportional to its weight.
+// randN returns a random number in [0, n).
+func pickVariant(variants []storage.Variant, randN func(n int64) int64) storage.Variant {
+	var total int64
+	for _, v := range variants {
+		total += int64(max(v.Weight, 0))
+	}
+	if total == 0 {
+		return variants[randN(int64(len(variants)))]
+	}
+
+	n := randN(total)
+	for _, v := range variants {
+		n -= int64(max(v.Weight, 0))
+		if n < 0 {
+			return v
+		}
+	}
+
+	return variants[len(variants)-1]
+}
@@ -0,0 +1,88 @@
+package available
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"url-shortener/internal/lib/aliaspolicy"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Alias       string   `json:"alias"` // the way it would be stored
+	Available   bool     `json:"available"`
+	Reason      string   `json:"reason,omitempty"`      // why the alias policy rejects the alias
+	Suggestions []string `json:"suggestions,omitempty"` // available aliases similar to an unavailable one
+}
+
+//go:generate go run github.com/vektra/mockery/v2 --name=AliasChecker --case=snake
+type AliasChecker interface {
+	AliasExists(ctx context.Context, alias string) (bool, error)
+}
+
+// AliasSuggester offers available aliases similar to a taken one.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=AliasSuggester --case=snake
+type AliasSuggester interface {
+	Suggest(ctx context.Context, alias string) ([]string, error)
+}
+
+// New tells whether the alias query parameter may be claimed for a new link, and suggests
+// alternatives when it is taken or rejected by aliases.
+func New(log *slog.Logger, checker AliasChecker, aliases *aliaspolicy.Policy, suggester AliasSuggester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.available.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := aliases.Normalize(r.URL.Query().Get("alias"))
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("alias is required"))
+
+			return
+		}
+
+		res := Response{Response: resp.Ok(), Alias: alias}
+
+		var aliasErr *aliaspolicy.Error
+		if errors.As(aliases.Check(alias), &aliasErr) {
+			res.Reason = aliasErr.Reason
+		} else {
+			exists, err := checker.AliasExists(r.Context(), alias)
+			if err != nil {
+				log.Error("failed to check that alias exists", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("failed to check alias"))
+
+				return
+			}
+			res.Available = !exists
+		}
+
+		if !res.Available {
+			var err error
+			res.Suggestions, err = suggester.Suggest(r.Context(), alias)
+			if err != nil {
+				log.Error("failed to suggest aliases", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("failed to check alias"))
+
+				return
+			}
+		}
+
+		render.JSON(w, r, res)
+	}
+}
@@ -0,0 +1,116 @@
+package available_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/available"
+	"url-shortener/internal/http-server/handlers/url/available/mocks"
+	"url-shortener/internal/lib/aliaspolicy"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestAvailableHandler(t *testing.T) {
+	taken := true
+
+	cases := []struct {
+		name        string
+		query       string
+		alias       string // normalized
+		exists      *bool  // AliasExists is not called when nil
+		suggestions []string
+		want        available.Response
+		code        int
+		respError   string
+		mockError   error
+	}{
+		{
+			name:   "Available",
+			query:  "?alias=Promo",
+			alias:  "promo",
+			exists: new(bool),
+			want:   available.Response{Available: true},
+			code:   http.StatusOK,
+		},
+		{
+			name:        "Taken",
+			query:       "?alias=promo",
+			alias:       "promo",
+			exists:      &taken,
+			suggestions: []string{"offer", "promo2"},
+			want:        available.Response{Suggestions: []string{"offer", "promo2"}},
+			code:        http.StatusOK,
+		},
+		{
+			name:        "Reserved",
+			query:       "?alias=admin",
+			alias:       "admin",
+			suggestions: []string{"admin2"},
+			want:        available.Response{Reason: `alias "admin" is reserved`, Suggestions: []string{"admin2"}},
+			code:        http.StatusOK,
+		},
+		{
+			name:      "Empty",
+			code:      http.StatusBadRequest,
+			respError: "alias is required",
+		},
+		{
+			name:      "Storage error",
+			query:     "?alias=promo",
+			alias:     "promo",
+			exists:    new(bool),
+			code:      http.StatusOK,
+			respError: "failed to check alias",
+			mockError: errors.New("unexpected error"),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			aliases, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 3, Reserved: []string{"admin"}, CaseInsensitive: true})
+			require.NoError(t, err)
+
+			checkerMock := mocks.NewAliasChecker(t)
+			if tc.exists != nil {
+				checkerMock.On("AliasExists", mock.Anything, tc.alias).
+					Return(*tc.exists, tc.mockError).
+					Once()
+			}
+
+			suggesterMock := mocks.NewAliasSuggester(t)
+			if tc.suggestions != nil {
+				suggesterMock.On("Suggest", mock.Anything, tc.alias).
+					Return(tc.suggestions, nil).
+					Once()
+			}
+
+			rr := httptest.NewRecorder()
+			handler := available.New(slogdiscard.NewDiscardLogger(), checkerMock, aliases, suggesterMock)
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/alias-available"+tc.query, nil))
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var resp available.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.respError != "" {
+				return
+			}
+
+			require.Equal(t, tc.alias, resp.Alias)
+			require.Equal(t, tc.want.Available, resp.Available)
+			require.Equal(t, tc.want.Reason, resp.Reason)
+			require.Equal(t, tc.want.Suggestions, resp.Suggestions)
+		})
+	}
+}
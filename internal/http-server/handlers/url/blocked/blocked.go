@@ -0,0 +1,46 @@
+package blocked
+
+import (
+	"log/slog"
+	"net/http"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/blocklist"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Domains int             `json:"domains"` // listed by all feeds
+	Hits    []blocklist.Hit `json:"hits"`
+}
+
+// HitReporter holds the visits of links that led to blocked domains.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=HitReporter --case=snake
+type HitReporter interface {
+	Len() int
+	Hits() []blocklist.Hit
+}
+
+// New reports the links whose visits were blocked since the start of this instance, the most recent first.
+func New(log *slog.Logger, reporter HitReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.blocked.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		hits := reporter.Hits()
+		if hits == nil {
+			hits = []blocklist.Hit{}
+		}
+
+		log.Info("blocked hits reported", slog.Int("links", len(hits)))
+
+		render.JSON(w, r, Response{Response: resp.Ok(), Domains: reporter.Len(), Hits: hits})
+	}
+}
@@ -0,0 +1,57 @@
+package blocked_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/blocked"
+	"url-shortener/internal/http-server/handlers/url/blocked/mocks"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+)
+
+func TestBlockedHandler(t *testing.T) {
+	hit := blocklist.Hit{
+		Alias:   "abcd",
+		URL:     "https://login.evil.com/",
+		Match:   blocklist.Match{Domain: "evil.com", Feed: "phishing"},
+		Count:   3,
+		LastHit: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		name string
+		hits []blocklist.Hit
+		want []blocklist.Hit
+	}{
+		{name: "Hits", hits: []blocklist.Hit{hit}, want: []blocklist.Hit{hit}},
+		{name: "None", hits: nil, want: []blocklist.Hit{}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reporterMock := mocks.NewHitReporter(t)
+			reporterMock.On("Hits").Return(tc.hits).Once()
+			reporterMock.On("Len").Return(42).Once()
+
+			rr := httptest.NewRecorder()
+			blocked.New(slogdiscard.NewDiscardLogger(), reporterMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/blocked", nil))
+
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var resp blocked.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, 42, resp.Domains)
+			require.Equal(t, tc.want, resp.Hits)
+		})
+	}
+}
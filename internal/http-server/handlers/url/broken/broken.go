@@ -0,0 +1,108 @@
+package broken
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Link is a link whose destination failed its last check.
+type Link struct {
+	Alias       string             `json:"alias"`
+	URL         string             `json:"url"`
+	FallbackURL string             `json:"fallback_url,omitempty"`
+	Health      storage.LinkHealth `json:"health"`
+}
+
+type Response struct {
+	resp.Response
+	Links []Link `json:"links"`
+}
+
+// LinkLister must be the storage itself.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=LinkLister --case=snake
+type LinkLister interface {
+	ListLinks(ctx context.Context, filter storage.LinkFilter) ([]storage.Link, error)
+}
+
+// New lists the links the link checker found broken, paged by the limit and offset query parameters
+// like the listing of all links.
+func New(log *slog.Logger, lister LinkLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.broken.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		filter, err := parseFilter(r)
+		if err != nil {
+			log.Info("invalid filter", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		links, err := lister.ListLinks(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list broken urls", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to list broken urls"))
+
+			return
+		}
+
+		res := Response{Response: resp.Ok(), Links: make([]Link, 0, len(links))}
+		for _, link := range links {
+			res.Links = append(res.Links, Link{
+				Alias:       link.Alias,
+				URL:         link.URL,
+				FallbackURL: link.FallbackURL,
+				Health:      link.Health,
+			})
+		}
+
+		render.JSON(w, r, res)
+	}
+}
+
+func parseFilter(r *http.Request) (storage.LinkFilter, error) {
+	q := r.URL.Query()
+
+	filter := storage.LinkFilter{Broken: true, Limit: defaultLimit}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return storage.LinkFilter{}, errors.New("limit must be between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return storage.LinkFilter{}, errors.New("offset must not be negative")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,99 @@
+package broken_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/broken"
+	"url-shortener/internal/http-server/handlers/url/broken/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestBrokenHandler(t *testing.T) {
+	health := storage.LinkHealth{
+		Status:    http.StatusNotFound,
+		Error:     "unexpected status 404",
+		LatencyMS: 120,
+		Failures:  3,
+		Fallback:  true,
+		CheckedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		name      string
+		query     string
+		filter    storage.LinkFilter
+		links     []storage.Link
+		want      []broken.Link
+		code      int
+		respError string
+		mockError error
+	}{
+		{
+			name:   "Broken links",
+			filter: storage.LinkFilter{Broken: true, Limit: 100},
+			links: []storage.Link{
+				{Alias: "abcd", URL: "https://gone.example.com/", FallbackURL: "https://archive.org/", Health: health, Clicks: 7},
+			},
+			want: []broken.Link{
+				{Alias: "abcd", URL: "https://gone.example.com/", FallbackURL: "https://archive.org/", Health: health},
+			},
+			code: http.StatusOK,
+		},
+		{
+			name:   "None",
+			query:  "?limit=10&offset=20",
+			filter: storage.LinkFilter{Broken: true, Limit: 10, Offset: 20},
+			want:   []broken.Link{},
+			code:   http.StatusOK,
+		},
+		{
+			name:      "Invalid limit",
+			query:     "?limit=0",
+			code:      http.StatusBadRequest,
+			respError: "limit must be between 1 and 1000",
+		},
+		{
+			name:      "ListLinks error",
+			filter:    storage.LinkFilter{Broken: true, Limit: 100},
+			code:      http.StatusOK,
+			respError: "failed to list broken urls",
+			mockError: errors.New("unexpected error"),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			listerMock := mocks.NewLinkLister(t)
+			if tc.code == http.StatusOK {
+				listerMock.On("ListLinks", mock.Anything, tc.filter).
+					Return(tc.links, tc.mockError).
+					Once()
+			}
+
+			rr := httptest.NewRecorder()
+			broken.New(slogdiscard.NewDiscardLogger(), listerMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/broken"+tc.query, nil))
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var resp broken.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.respError == "" {
+				require.Equal(t, tc.want, resp.Links)
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package delete
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"url-shortener/internal/lib/aliaspolicy"
 	resp "url-shortener/internal/lib/api/response"
-	utils "url-shortener/internal/lib/helpers"
 	"url-shortener/internal/lib/logger/sl"
 	"url-shortener/internal/storage"
 
@@ -16,10 +17,10 @@ import (
 
 //go:generate go run github.com/vektra/mockery/v2 --name=URLDeleter --case=snake
 type URLDeleter interface {
-	DeleteURL(alias string) error
+	DeleteURL(ctx context.Context, alias string) error
 }
 
-func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
+func New(log *slog.Logger, urlDeleter URLDeleter, aliases *aliaspolicy.Policy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.delete.New"
 
@@ -35,7 +36,7 @@ func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
 			return
 		}
 
-		if !utils.IsValidAlias(alias) {
+		if !aliases.Valid(alias) {
 			log.Info("url alias not valid", slog.String("alias", alias))
 
 			render.JSON(w, r, resp.Error("url alias not valid"))
@@ -43,7 +44,7 @@ func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
 			return
 		}
 
-		err := urlDeleter.DeleteURL(alias)
+		err := urlDeleter.DeleteURL(r.Context(), alias)
 
 		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("url alias not found", slog.String("alias", alias))
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"url-shortener/internal/lib/aliaspolicy"
 	resp2 "url-shortener/internal/lib/api/response"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
 	"url-shortener/internal/storage"
@@ -70,13 +71,16 @@ func TestDeleteHandler(t *testing.T) {
 			urlDeleterMock := mocks.NewURLDeleter(t)
 
 			if tc.respError == "" || tc.mockError != nil {
-				urlDeleterMock.On("DeleteURL", mock.AnythingOfType("string")).
+				urlDeleterMock.On("DeleteURL", mock.Anything, mock.AnythingOfType("string")).
 					Return(tc.mockError).
 					Once()
 			}
 
+			aliases, err := aliaspolicy.New(aliaspolicy.Config{})
+			require.NoError(t, err)
+
 			handler := chi.NewRouter()
-			handler.Delete("/url/{alias}", delete.New(slogdiscard.NewDiscardLogger(), urlDeleterMock))
+			handler.Delete("/url/{alias}", delete.New(slogdiscard.NewDiscardLogger(), urlDeleterMock, aliases))
 
 			req, err := http.NewRequest(http.MethodDelete, tc.uri, nil)
 			require.NoError(t, err)
@@ -0,0 +1,118 @@
+package info
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Alias           string            `json:"alias"`
+	URL             string            `json:"url"`
+	Protected       bool              `json:"protected"`
+	MaxClicks       int64             `json:"max_clicks,omitempty"`
+	RemainingClicks *int64            `json:"remaining_clicks,omitempty"` // only set for links with a click limit
+	NotBefore       *time.Time        `json:"not_before,omitempty"`
+	NotAfter        *time.Time        `json:"not_after,omitempty"`
+	QueryMode       string            `json:"query_mode,omitempty"`
+	ForwardPath     bool              `json:"forward_path,omitempty"`
+	RedirectType    string            `json:"redirect_type,omitempty"` // empty for the server default
+	Rules           []storage.Rule    `json:"rules,omitempty"`
+	Variants        []storage.Variant `json:"variants,omitempty"` // with the clicks each of them got
+	FallbackURL     string            `json:"fallback_url,omitempty"`
+	CreatedAt       *time.Time        `json:"created_at,omitempty"`
+	Clicks          int64             `json:"clicks"`
+	storage.Meta
+	Page   *storage.PageMeta   `json:"page,omitempty"`   // set once the destination was fetched
+	Health *storage.LinkHealth `json:"health,omitempty"` // set once the destination was checked
+}
+
+// LinkGetter must read the storage directly, cached links may report stale click counts.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=LinkGetter --case=snake
+type LinkGetter interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+}
+
+func New(log *slog.Logger, linkGetter LinkGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.info.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("alias is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		link, err := linkGetter.GetLink(r.Context(), alias)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		res := Response{
+			Response:     resp.Ok(),
+			Alias:        link.Alias,
+			URL:          link.URL,
+			Protected:    link.Protected(),
+			MaxClicks:    link.MaxClicks,
+			QueryMode:    link.QueryMode,
+			ForwardPath:  link.ForwardPath,
+			RedirectType: link.RedirectType,
+			Rules:        link.Rules,
+			Variants:     link.Variants,
+			FallbackURL:  link.FallbackURL,
+			Clicks:       link.Clicks,
+			Meta:         link.Meta,
+		}
+		if link.Limited() {
+			res.RemainingClicks = &link.ClicksLeft
+		}
+		if !link.NotBefore.IsZero() {
+			res.NotBefore = &link.NotBefore
+		}
+		if !link.NotAfter.IsZero() {
+			res.NotAfter = &link.NotAfter
+		}
+		if !link.CreatedAt.IsZero() {
+			res.CreatedAt = &link.CreatedAt
+		}
+		if !link.Page.FetchedAt.IsZero() {
+			res.Page = &link.Page
+		}
+		if !link.Health.CheckedAt.IsZero() {
+			res.Health = &link.Health
+		}
+
+		render.JSON(w, r, res)
+	}
+}
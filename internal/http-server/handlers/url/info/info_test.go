@@ -0,0 +1,125 @@
+package info_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/info"
+	"url-shortener/internal/http-server/handlers/url/info/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestInfoHandler(t *testing.T) {
+	zero := int64(0)
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		link      storage.Link
+		mockError error
+		code      int
+		want      info.Response
+	}{
+		{
+			name: "Plain",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com"},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com"},
+		},
+		{
+			name: "Exhausted",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", PasswordHash: "hash", MaxClicks: 3},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com", Protected: true, MaxClicks: 3, RemainingClicks: &zero},
+		},
+		{
+			name: "Scheduled",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", NotAfter: notAfter},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com", NotAfter: &notAfter},
+		},
+		{
+			name: "Created",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", CreatedAt: notAfter, Clicks: 7},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com", CreatedAt: &notAfter, Clicks: 7},
+		},
+		{
+			name: "Meta",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", Meta: storage.Meta{Title: "Search", Notes: "n", Tags: []string{"a", "b"}}},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com", Meta: storage.Meta{Title: "Search", Notes: "n", Tags: []string{"a", "b"}}},
+		},
+		{
+			name: "Page",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", Page: storage.PageMeta{Title: "Google", OGImage: "https://google.com/logo.png", FetchedAt: notAfter}},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com", Page: &storage.PageMeta{Title: "Google", OGImage: "https://google.com/logo.png", FetchedAt: notAfter}},
+		},
+		{
+			name: "Variants",
+			link: storage.Link{Alias: "abcd", URL: "https://google.com", Variants: []storage.Variant{
+				{ID: 1, URL: "https://google.com/a", Weight: 1, Clicks: 10},
+				{ID: 2, URL: "https://google.com/b", Weight: 3, Clicks: 31},
+			}},
+			code: http.StatusOK,
+			want: info.Response{Alias: "abcd", URL: "https://google.com", Variants: []storage.Variant{
+				{ID: 1, URL: "https://google.com/a", Weight: 1, Clicks: 10},
+				{ID: 2, URL: "https://google.com/b", Weight: 3, Clicks: 31},
+			}},
+		},
+		{
+			name:      "Not Found",
+			mockError: storage.ErrURLNotFound,
+			code:      http.StatusNotFound,
+			want:      info.Response{},
+		},
+		{
+			name:      "Storage Error",
+			mockError: errors.New("unexpected error"),
+			code:      http.StatusOK,
+			want:      info.Response{},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			linkGetterMock := mocks.NewLinkGetter(t)
+			linkGetterMock.On("GetLink", mock.Anything, "abcd").
+				Return(tc.link, tc.mockError).
+				Once()
+
+			handler := chi.NewRouter()
+			handler.Get("/url/{alias}", info.New(slogdiscard.NewDiscardLogger(), linkGetterMock))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/abcd", nil))
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var got info.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+
+			if tc.mockError != nil {
+				require.NotEmpty(t, got.Error)
+				return
+			}
+
+			tc.want.Status = "OK"
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tags"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Link is a link in the listing, without its redirect settings.
+type Link struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Clicks    int64      `json:"clicks"`
+	storage.Meta
+}
+
+type Response struct {
+	resp.Response
+	Links []Link `json:"links"`
+}
+
+// LinkLister must be the storage itself.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=LinkLister --case=snake
+type LinkLister interface {
+	ListLinks(ctx context.Context, filter storage.LinkFilter) ([]storage.Link, error)
+}
+
+// New lists links ordered by creation, filtered by the query parameters
+//
+//	tag     links having the tag, repeated or comma-separated for links having all of them
+//	q       text in the alias, URL, title or description
+//	limit   page size, 100 by default and 1000 at most
+//	offset  links to skip
+func New(log *slog.Logger, lister LinkLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		filter, err := parseFilter(r)
+		if err != nil {
+			log.Info("invalid filter", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		links, err := lister.ListLinks(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list urls", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to list urls"))
+
+			return
+		}
+
+		res := Response{Response: resp.Ok(), Links: make([]Link, 0, len(links))}
+		for _, link := range links {
+			item := Link{Alias: link.Alias, URL: link.URL, Clicks: link.Clicks, Meta: link.Meta}
+			if !link.CreatedAt.IsZero() {
+				createdAt := link.CreatedAt
+				item.CreatedAt = &createdAt
+			}
+			res.Links = append(res.Links, item)
+		}
+
+		render.JSON(w, r, res)
+	}
+}
+
+func parseFilter(r *http.Request) (storage.LinkFilter, error) {
+	q := r.URL.Query()
+
+	var tagList []string
+	for _, v := range q["tag"] {
+		tagList = append(tagList, strings.Split(v, ",")...)
+	}
+
+	filter := storage.LinkFilter{
+		Tags:  tags.Normalize(tagList),
+		Query: strings.TrimSpace(q.Get("q")),
+		Limit: defaultLimit,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return storage.LinkFilter{}, errors.New("limit must be between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return storage.LinkFilter{}, errors.New("offset must not be negative")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
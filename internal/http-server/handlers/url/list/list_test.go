@@ -0,0 +1,78 @@
+package list_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/list"
+	"url-shortener/internal/http-server/handlers/url/list/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestListHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		filter    *storage.LinkFilter // passed to the storage, not called when nil
+		code      int
+		respError string
+	}{
+		{
+			name:   "Defaults",
+			query:  "",
+			filter: &storage.LinkFilter{Limit: 100},
+			code:   http.StatusOK,
+		},
+		{
+			name:   "Tags and query",
+			query:  "?tag=Go&tag=lang,news&q=+spring+&limit=10&offset=20",
+			filter: &storage.LinkFilter{Tags: []string{"go", "lang", "news"}, Query: "spring", Limit: 10, Offset: 20},
+			code:   http.StatusOK,
+		},
+		{
+			name:      "Limit too large",
+			query:     "?limit=5000",
+			code:      http.StatusBadRequest,
+			respError: "limit must be between 1 and 1000",
+		},
+		{
+			name:      "Negative offset",
+			query:     "?offset=-1",
+			code:      http.StatusBadRequest,
+			respError: "offset must not be negative",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			listerMock := mocks.NewLinkLister(t)
+			if tc.filter != nil {
+				listerMock.On("ListLinks", mock.Anything, *tc.filter).
+					Return([]storage.Link{{Alias: "abcd", URL: "https://go.dev", Clicks: 3, Meta: storage.Meta{Title: "Go"}}}, nil).
+					Once()
+			}
+
+			rr := httptest.NewRecorder()
+			list.New(slogdiscard.NewDiscardLogger(), listerMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url"+tc.query, nil))
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var resp list.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.filter != nil {
+				require.Equal(t, []list.Link{{Alias: "abcd", URL: "https://go.dev", Clicks: 3, Meta: storage.Meta{Title: "Go"}}}, resp.Links)
+			}
+		})
+	}
+}
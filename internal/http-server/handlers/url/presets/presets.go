@@ -0,0 +1,179 @@
+package presets
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/utm"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// Request describes a named set of UTM tags.
+type Request struct {
+	Name string `json:"name" validate:"required,max=100"`
+	utm.Params
+}
+
+type Response struct {
+	resp.Response
+	ID int64 `json:"id,omitempty"`
+}
+
+type ListResponse struct {
+	resp.Response
+	Presets []storage.UTMPreset `json:"presets"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2 --name=PresetStore --case=snake
+type PresetStore interface {
+	ListUTMPresets(ctx context.Context) ([]storage.UTMPreset, error)
+	SaveUTMPreset(ctx context.Context, preset storage.UTMPreset) (int64, error)
+	DeleteUTMPreset(ctx context.Context, id int64) error
+}
+
+// NewList returns all UTM presets.
+func NewList(log *slog.Logger, store PresetStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.presets.NewList"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		presets, err := store.ListUTMPresets(r.Context())
+		if err != nil {
+			log.Error("failed to list utm presets", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+		if presets == nil {
+			presets = []storage.UTMPreset{}
+		}
+
+		render.JSON(w, r, ListResponse{Response: resp.Ok(), Presets: presets})
+	}
+}
+
+// NewSave adds a UTM preset. Its ID is used as utm.preset_id when links are saved.
+func NewSave(log *slog.Logger, store PresetStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.presets.NewSave"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.Error("request validation failed", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		if len(req.Values()) == 0 {
+			log.Info("utm preset has no tags")
+
+			render.JSON(w, r, resp.Error("utm preset must have at least one tag"))
+
+			return
+		}
+
+		id, err := store.SaveUTMPreset(r.Context(), storage.UTMPreset{
+			Name:     req.Name,
+			Source:   req.Source,
+			Medium:   req.Medium,
+			Campaign: req.Campaign,
+			Term:     req.Term,
+			Content:  req.Content,
+		})
+		if errors.Is(err, storage.ErrPresetExists) {
+			log.Info("utm preset already exists", slog.String("name", req.Name))
+
+			render.JSON(w, r, resp.Error("utm preset already exists"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to add utm preset", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to add utm preset"))
+
+			return
+		}
+
+		log.Info("utm preset saved", slog.Int64("id", id))
+
+		render.JSON(w, r, Response{Response: resp.Ok(), ID: id})
+	}
+}
+
+// NewDelete removes a UTM preset. Links created with it keep their tags.
+func NewDelete(log *slog.Logger, store PresetStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.presets.NewDelete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err == nil {
+			err = store.DeleteUTMPreset(r.Context(), id)
+		}
+		if errors.Is(err, storage.ErrPresetNotFound) || errors.Is(err, strconv.ErrSyntax) || errors.Is(err, strconv.ErrRange) {
+			log.Info("utm preset not found", slog.String("id", chi.URLParam(r, "id")))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("utm preset not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to delete utm preset", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to delete utm preset"))
+
+			return
+		}
+
+		log.Info("utm preset deleted", slog.Int64("id", id))
+
+		render.JSON(w, r, resp.Ok())
+	}
+}
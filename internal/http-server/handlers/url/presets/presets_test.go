@@ -0,0 +1,123 @@
+package presets_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/presets"
+	"url-shortener/internal/http-server/handlers/url/presets/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/utm"
+	"url-shortener/internal/storage"
+)
+
+func newRouter(store presets.PresetStore) http.Handler {
+	log := slogdiscard.NewDiscardLogger()
+
+	r := chi.NewRouter()
+	r.Get("/url/utm-presets", presets.NewList(log, store))
+	r.Post("/url/utm-presets", presets.NewSave(log, store))
+	r.Delete("/url/utm-presets/{id}", presets.NewDelete(log, store))
+
+	return r
+}
+
+func TestSaveHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		req       presets.Request
+		mockError error
+		respError string
+	}{
+		{
+			name: "Success",
+			req:  presets.Request{Name: "newsletter", Params: utm.Params{Source: "newsletter", Medium: "email"}},
+		},
+		{
+			name:      "No tags",
+			req:       presets.Request{Name: "empty"},
+			respError: "utm preset must have at least one tag",
+		},
+		{
+			name:      "No name",
+			req:       presets.Request{Params: utm.Params{Source: "newsletter"}},
+			respError: "field Name is a required field",
+		},
+		{
+			name:      "Exists",
+			req:       presets.Request{Name: "newsletter", Params: utm.Params{Source: "newsletter"}},
+			mockError: storage.ErrPresetExists,
+			respError: "utm preset already exists",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storeMock := mocks.NewPresetStore(t)
+			if tc.respError == "" || tc.mockError != nil {
+				storeMock.On("SaveUTMPreset", mock.Anything, storage.UTMPreset{
+					Name:   tc.req.Name,
+					Source: tc.req.Source,
+					Medium: tc.req.Medium,
+				}).
+					Return(int64(3), tc.mockError).
+					Once()
+			}
+
+			body, err := json.Marshal(tc.req)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			newRouter(storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url/utm-presets", bytes.NewReader(body)))
+
+			var resp presets.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.respError == "" {
+				require.EqualValues(t, 3, resp.ID)
+			}
+		})
+	}
+}
+
+func TestListDeleteHandlers(t *testing.T) {
+	storeMock := mocks.NewPresetStore(t)
+	storeMock.On("ListUTMPresets", mock.Anything).
+		Return([]storage.UTMPreset{{ID: 3, Name: "newsletter", Source: "newsletter"}}, nil).
+		Once()
+	storeMock.On("DeleteUTMPreset", mock.Anything, int64(3)).
+		Return(nil).
+		Once()
+	storeMock.On("DeleteUTMPreset", mock.Anything, int64(4)).
+		Return(storage.ErrPresetNotFound).
+		Once()
+
+	rr := httptest.NewRecorder()
+	newRouter(storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/utm-presets", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp presets.ListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Presets, 1)
+
+	for path, code := range map[string]int{
+		"/url/utm-presets/3": http.StatusOK,
+		"/url/utm-presets/4": http.StatusNotFound,
+		"/url/utm-presets/x": http.StatusNotFound,
+	} {
+		rr = httptest.NewRecorder()
+		newRouter(storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, path, nil))
+		require.Equal(t, code, rr.Code, path)
+	}
+}
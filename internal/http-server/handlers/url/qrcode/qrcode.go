@@ -0,0 +1,221 @@
+package qrcode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/qr"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	defaultSize = 256
+	minSize     = 32
+	maxSize     = 2048
+)
+
+// URLGetter only has to confirm that the link exists, it may be a cache.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=URLGetter --case=snake
+type URLGetter interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// New renders the QR code of a short link as PNG or SVG. The format is taken from the
+// format query parameter or the extension of the path (qr.svg), the look from
+//
+//	size        width and height in pixels, 256 by default
+//	level       error correction level: L, M (default), Q or H
+//	fg, bg      colors as RGB, RRGGBB or RRGGBBAA hex, black on white by default
+//	quiet_zone  false drops the margin around the code
+//
+// baseURL is the public address of the service, the one of the request is used when it is empty.
+func New(log *slog.Logger, urlGetter URLGetter, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.qrcode.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+
+		format, opts, err := parseOptions(r)
+		if err != nil {
+			log.Info("invalid qr code options", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		_, err = urlGetter.GetLink(r.Context(), alias)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		content := shortURL(r, baseURL, alias)
+
+		// the image only depends on the short URL and the options, so it can be revalidated without rendering
+		etag := etag(content, format, opts)
+		if matchETag(r.Header.Get("If-None-Match"), etag) {
+			setCacheHeaders(w, etag)
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		contentType := "image/png"
+		encode := qr.PNG
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			encode = qr.SVG
+		}
+
+		image, err := encode(content, opts)
+		if errors.Is(err, qr.ErrTooSmall) {
+			log.Info("qr code does not fit", slog.Int("size", opts.Size))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("size is too small for the link"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to render qr code", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		setCacheHeaders(w, etag)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+		if _, err := w.Write(image); err != nil {
+			log.Error("failed to write qr code", sl.Err(err))
+		}
+	}
+}
+
+func setCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+}
+
+func parseOptions(r *http.Request) (string, qr.Options, error) {
+	q := r.URL.Query()
+
+	format := q.Get("format")
+	if format == "" {
+		format, _ = r.Context().Value(middleware.URLFormatCtxKey).(string)
+	}
+	switch format {
+	case "":
+		format = "png"
+	case "png", "svg":
+	default:
+		return "", qr.Options{}, errors.New("format must be png or svg")
+	}
+
+	opts := qr.Options{
+		Size:       defaultSize,
+		Level:      "M",
+		Foreground: qr.Black,
+		Background: qr.White,
+		QuietZone:  true,
+	}
+
+	if v := q.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < minSize || size > maxSize {
+			return "", qr.Options{}, fmt.Errorf("size must be between %d and %d", minSize, maxSize)
+		}
+		opts.Size = size
+	}
+
+	if v := q.Get("level"); v != "" {
+		opts.Level = strings.ToUpper(v)
+		if !qr.ValidLevel(opts.Level) {
+			return "", qr.Options{}, errors.New("level must be L, M, Q or H")
+		}
+	}
+
+	var err error
+	if v := q.Get("fg"); v != "" {
+		if opts.Foreground, err = qr.ParseColor(v); err != nil {
+			return "", qr.Options{}, errors.New("fg is not a valid color")
+		}
+	}
+	if v := q.Get("bg"); v != "" {
+		if opts.Background, err = qr.ParseColor(v); err != nil {
+			return "", qr.Options{}, errors.New("bg is not a valid color")
+		}
+	}
+
+	if v := q.Get("quiet_zone"); v != "" {
+		if opts.QuietZone, err = strconv.ParseBool(v); err != nil {
+			return "", qr.Options{}, errors.New("quiet_zone must be true or false")
+		}
+	}
+
+	return format, opts, nil
+}
+
+// shortURL is the address the code points to.
+func shortURL(r *http.Request, baseURL, alias string) string {
+	if baseURL == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + r.Host
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + alias
+}
+
+func etag(content, format string, o qr.Options) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s\x00%v\x00%v\x00%t",
+		content, format, o.Size, o.Level, o.Foreground, o.Background, o.QuietZone)))
+
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// matchETag implements the weak comparison of If-None-Match.
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/qrcode"
+	"url-shortener/internal/http-server/handlers/url/qrcode/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func newRouter(t *testing.T) http.Handler {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetLink", mock.Anything, "abcd").
+		Return(storage.Link{Alias: "abcd", URL: "https://example.com"}, nil).
+		Maybe()
+	urlGetterMock.On("GetLink", mock.Anything, "none").
+		Return(storage.Link{}, storage.ErrURLNotFound).
+		Maybe()
+
+	r := chi.NewRouter()
+	r.Use(middleware.URLFormat)
+	r.Get("/url/{alias}/qr", qrcode.New(slogdiscard.NewDiscardLogger(), urlGetterMock, "https://sho.rt/"))
+
+	return r
+}
+
+func TestQRCode(t *testing.T) {
+	cases := []struct {
+		name        string
+		path        string
+		code        int
+		contentType string
+	}{
+		{name: "PNG by default", path: "/url/abcd/qr?size=300", code: http.StatusOK, contentType: "image/png"},
+		{name: "SVG by extension", path: "/url/abcd/qr.svg", code: http.StatusOK, contentType: "image/svg+xml"},
+		{name: "SVG by parameter", path: "/url/abcd/qr?format=svg&fg=%23336699&bg=ffffff00&level=h&quiet_zone=false", code: http.StatusOK, contentType: "image/svg+xml"},
+		{name: "Unknown format", path: "/url/abcd/qr.gif", code: http.StatusBadRequest},
+		{name: "Size too large", path: "/url/abcd/qr?size=5000", code: http.StatusBadRequest},
+		{name: "Size too small for the content", path: "/url/abcd/qr?size=32", code: http.StatusBadRequest},
+		{name: "Invalid level", path: "/url/abcd/qr?level=x", code: http.StatusBadRequest},
+		{name: "Invalid color", path: "/url/abcd/qr?fg=red", code: http.StatusBadRequest},
+		{name: "Not found", path: "/url/none/qr", code: http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			newRouter(t).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			require.Equal(t, tc.code, rr.Code)
+			if tc.contentType == "" {
+				assert.Empty(t, rr.Header().Get("ETag"))
+				return
+			}
+			assert.Equal(t, tc.contentType, rr.Header().Get("Content-Type"))
+			assert.NotEmpty(t, rr.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestQRCode_PNGSize(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newRouter(t).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/abcd/qr.png?size=300", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	img, err := png.Decode(bytes.NewReader(rr.Body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 300, img.Bounds().Dx())
+}
+
+func TestQRCode_ETag(t *testing.T) {
+	r := newRouter(t)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/abcd/qr.svg", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/url/abcd/qr.svg", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotModified, rr.Code)
+	require.Empty(t, rr.Body.Bytes())
+
+	// other options make another image
+	req = httptest.NewRequest(http.MethodGet, "/url/abcd/qr.svg?level=Q", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEqual(t, etag, rr.Header().Get("ETag"))
+}
@@ -0,0 +1,255 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// Request describes a targeting rule. A rule needs at least one condition.
+type Request struct {
+	Position int    `json:"position,omitempty" validate:"omitempty,min=1"` // appended after the existing rules when empty
+	Platform string `json:"platform,omitempty" validate:"omitempty,oneof=ios android mobile windows macos linux desktop"`
+	Language string `json:"language,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Country  string `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+	URL      string `json:"url" validate:"required,url"`
+}
+
+type Response struct {
+	resp.Response
+	Rule *storage.Rule `json:"rule,omitempty"`
+}
+
+type ListResponse struct {
+	resp.Response
+	Rules []storage.Rule `json:"rules"`
+}
+
+// RuleStore must be the storage itself, cached links may hold outdated rules.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=RuleStore --case=snake
+type RuleStore interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+	AddRule(ctx context.Context, alias string, rule storage.Rule) (storage.Rule, error)
+	UpdateRule(ctx context.Context, alias string, rule storage.Rule) error
+	DeleteRule(ctx context.Context, alias string, id int64) error
+}
+
+// NewList returns the rules of a link in the order they are evaluated.
+func NewList(log *slog.Logger, store RuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.rules.NewList"
+
+		log := requestLogger(log, r, op)
+
+		alias := chi.URLParam(r, "alias")
+
+		link, err := store.GetLink(r.Context(), alias)
+		if err != nil {
+			renderStoreError(w, r, log, err, "failed to get url")
+
+			return
+		}
+
+		rules := link.Rules
+		if rules == nil {
+			rules = []storage.Rule{}
+		}
+
+		render.JSON(w, r, ListResponse{Response: resp.Ok(), Rules: rules})
+	}
+}
+
+// NewAdd adds a rule to a link.
+func NewAdd(log *slog.Logger, store RuleStore, policy *urlpolicy.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.rules.NewAdd"
+
+		log := requestLogger(log, r, op)
+
+		alias := chi.URLParam(r, "alias")
+
+		rule, ok := decodeRule(w, r, log, policy)
+		if !ok {
+			return
+		}
+
+		rule, err := store.AddRule(r.Context(), alias, rule)
+		if err != nil {
+			renderStoreError(w, r, log, err, "failed to add rule")
+
+			return
+		}
+
+		log.Info("rule added", slog.String("alias", alias), slog.Int64("id", rule.ID))
+
+		render.JSON(w, r, Response{Response: resp.Ok(), Rule: &rule})
+	}
+}
+
+// NewUpdate replaces a rule of a link.
+func NewUpdate(log *slog.Logger, store RuleStore, policy *urlpolicy.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.rules.NewUpdate"
+
+		log := requestLogger(log, r, op)
+
+		alias := chi.URLParam(r, "alias")
+
+		id, ok := ruleID(w, r, log)
+		if !ok {
+			return
+		}
+
+		rule, ok := decodeRule(w, r, log, policy)
+		if !ok {
+			return
+		}
+		rule.ID = id
+
+		if err := store.UpdateRule(r.Context(), alias, rule); err != nil {
+			renderStoreError(w, r, log, err, "failed to update rule")
+
+			return
+		}
+
+		log.Info("rule updated", slog.String("alias", alias), slog.Int64("id", id))
+
+		render.JSON(w, r, Response{Response: resp.Ok(), Rule: &rule})
+	}
+}
+
+// NewDelete removes a rule from a link.
+func NewDelete(log *slog.Logger, store RuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.rules.NewDelete"
+
+		log := requestLogger(log, r, op)
+
+		alias := chi.URLParam(r, "alias")
+
+		id, ok := ruleID(w, r, log)
+		if !ok {
+			return
+		}
+
+		if err := store.DeleteRule(r.Context(), alias, id); err != nil {
+			renderStoreError(w, r, log, err, "failed to delete rule")
+
+			return
+		}
+
+		log.Info("rule deleted", slog.String("alias", alias), slog.Int64("id", id))
+
+		render.JSON(w, r, resp.Ok())
+	}
+}
+
+func requestLogger(log *slog.Logger, r *http.Request, op string) *slog.Logger {
+	return log.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+}
+
+func decodeRule(w http.ResponseWriter, r *http.Request, log *slog.Logger, policy *urlpolicy.Policy) (storage.Rule, bool) {
+	var req Request
+
+	err := render.DecodeJSON(r.Body, &req)
+	if errors.Is(err, io.EOF) {
+		log.Error("request body is empty")
+
+		render.JSON(w, r, resp.Error("empty request"))
+
+		return storage.Rule{}, false
+	}
+	if err != nil {
+		log.Error("failed to decode request", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("failed to decode request"))
+
+		return storage.Rule{}, false
+	}
+
+	req.Country = strings.ToUpper(req.Country)
+
+	if err := validator.New().Struct(req); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+
+		log.Error("request validation failed", sl.Err(err))
+
+		render.JSON(w, r, resp.ValidationError(validateErr))
+
+		return storage.Rule{}, false
+	}
+
+	if req.Platform == "" && req.Language == "" && req.Country == "" {
+		log.Info("rule has no conditions")
+
+		render.JSON(w, r, resp.Error("rule must have a platform, language or country"))
+
+		return storage.Rule{}, false
+	}
+
+	if err := policy.Check(req.URL); err != nil {
+		log.Info("url rejected by policy", sl.Err(err))
+
+		render.JSON(w, r, resp.Error(err.Error()))
+
+		return storage.Rule{}, false
+	}
+
+	return storage.Rule{
+		Position: req.Position,
+		Platform: req.Platform,
+		Language: req.Language,
+		Country:  req.Country,
+		URL:      req.URL,
+	}, true
+}
+
+func ruleID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		log.Info("invalid rule id", slog.String("id", chi.URLParam(r, "id")))
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("rule not found"))
+
+		return 0, false
+	}
+
+	return id, true
+}
+
+func renderStoreError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, msg string) {
+	switch {
+	case errors.Is(err, storage.ErrURLNotFound):
+		log.Info("url not found", slog.String("alias", chi.URLParam(r, "alias")))
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("not found"))
+	case errors.Is(err, storage.ErrRuleNotFound):
+		log.Info("rule not found", slog.String("id", chi.URLParam(r, "id")))
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("rule not found"))
+	default:
+		log.Error(msg, sl.Err(err))
+
+		render.JSON(w, r, resp.Error(msg))
+	}
+}
@@ -0,0 +1,187 @@
+package rules_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/rules"
+	"url-shortener/internal/http-server/handlers/url/rules/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/storage"
+)
+
+func newRouter(t *testing.T, store rules.RuleStore) http.Handler {
+	log := slogdiscard.NewDiscardLogger()
+
+	policy, err := urlpolicy.New(urlpolicy.Config{Deny: []string{"evil.com"}})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Get("/url/{alias}/rules", rules.NewList(log, store))
+	r.Post("/url/{alias}/rules", rules.NewAdd(log, store, policy))
+	r.Put("/url/{alias}/rules/{id}", rules.NewUpdate(log, store, policy))
+	r.Delete("/url/{alias}/rules/{id}", rules.NewDelete(log, store))
+
+	return r
+}
+
+func TestAddHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		req       rules.Request
+		rule      storage.Rule // passed to the storage, not called when empty
+		mockError error
+		code      int
+		respError string
+	}{
+		{
+			name: "Success",
+			req:  rules.Request{Platform: "ios", URL: "https://apps.apple.com/app"},
+			rule: storage.Rule{Platform: "ios", URL: "https://apps.apple.com/app"},
+			code: http.StatusOK,
+		},
+		{
+			name: "Country is normalized",
+			req:  rules.Request{Country: "de", Language: "de-AT", URL: "https://example.de"},
+			rule: storage.Rule{Country: "DE", Language: "de-AT", URL: "https://example.de"},
+			code: http.StatusOK,
+		},
+		{
+			name:      "No conditions",
+			req:       rules.Request{URL: "https://example.com"},
+			code:      http.StatusOK,
+			respError: "rule must have a platform, language or country",
+		},
+		{
+			name:      "Unknown platform",
+			req:       rules.Request{Platform: "beos", URL: "https://example.com"},
+			code:      http.StatusOK,
+			respError: "field Platform is not valid",
+		},
+		{
+			name:      "Invalid URL",
+			req:       rules.Request{Platform: "ios", URL: "not a url"},
+			code:      http.StatusOK,
+			respError: "field URL is not a valid URL",
+		},
+		{
+			name:      "Denied domain",
+			req:       rules.Request{Platform: "ios", URL: "https://evil.com/app"},
+			code:      http.StatusOK,
+			respError: `url rejected: domain "evil.com" is denied`,
+		},
+		{
+			name:      "Link not found",
+			req:       rules.Request{Platform: "ios", URL: "https://apps.apple.com/app"},
+			rule:      storage.Rule{Platform: "ios", URL: "https://apps.apple.com/app"},
+			mockError: storage.ErrURLNotFound,
+			code:      http.StatusNotFound,
+			respError: "not found",
+		},
+		{
+			name:      "Storage error",
+			req:       rules.Request{Platform: "ios", URL: "https://apps.apple.com/app"},
+			rule:      storage.Rule{Platform: "ios", URL: "https://apps.apple.com/app"},
+			mockError: errors.New("unexpected error"),
+			code:      http.StatusOK,
+			respError: "failed to add rule",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storeMock := mocks.NewRuleStore(t)
+			if tc.rule.URL != "" {
+				saved := tc.rule
+				saved.ID, saved.Position = 7, 1
+				storeMock.On("AddRule", mock.Anything, "abcd", tc.rule).
+					Return(saved, tc.mockError).
+					Once()
+			}
+
+			body, err := json.Marshal(tc.req)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/url/abcd/rules", bytes.NewReader(body)))
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var resp rules.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.respError == "" {
+				require.EqualValues(t, 7, resp.Rule.ID)
+			}
+		})
+	}
+}
+
+func TestListHandler(t *testing.T) {
+	storeMock := mocks.NewRuleStore(t)
+	storeMock.On("GetLink", mock.Anything, "abcd").
+		Return(storage.Link{Alias: "abcd", Rules: []storage.Rule{{ID: 1, Position: 1, Platform: "ios", URL: "https://apps.apple.com/app"}}}, nil).
+		Once()
+	storeMock.On("GetLink", mock.Anything, "none").
+		Return(storage.Link{}, storage.ErrURLNotFound).
+		Once()
+
+	rr := httptest.NewRecorder()
+	newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/abcd/rules", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp rules.ListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Rules, 1)
+
+	rr = httptest.NewRecorder()
+	newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/url/none/rules", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestUpdateDeleteHandlers(t *testing.T) {
+	rule := storage.Rule{ID: 3, Position: 2, Language: "fr", URL: "https://example.com/fr"}
+
+	storeMock := mocks.NewRuleStore(t)
+	storeMock.On("UpdateRule", mock.Anything, "abcd", rule).
+		Return(nil).
+		Once()
+	storeMock.On("DeleteRule", mock.Anything, "abcd", int64(3)).
+		Return(nil).
+		Once()
+	storeMock.On("DeleteRule", mock.Anything, "abcd", int64(4)).
+		Return(storage.ErrRuleNotFound).
+		Once()
+
+	body, err := json.Marshal(rules.Request{Position: 2, Language: "fr", URL: "https://example.com/fr"})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/url/abcd/rules/3", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/url/abcd/rules/3", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/url/abcd/rules/4", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	newRouter(t, storeMock).ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/url/abcd/rules/x", nil))
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -2,30 +2,35 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+	storage "url-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // URLSaver is an autogenerated mock type for the URLSaver type
 type URLSaver struct {
 	mock.Mock
 }
 
-// AliasExists provides a mock function with given fields: alias
-func (_m *URLSaver) AliasExists(alias string) (bool, error) {
-	ret := _m.Called(alias)
+// AliasExists provides a mock function with given fields: ctx, alias
+func (_m *URLSaver) AliasExists(ctx context.Context, alias string) (bool, error) {
+	ret := _m.Called(ctx, alias)
 
 	var r0 bool
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (bool, error)); ok {
-		return rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, alias)
 	}
-	if rf, ok := ret.Get(0).(func(string) bool); ok {
-		r0 = rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, alias)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(alias)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, alias)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -33,23 +38,47 @@ func (_m *URLSaver) AliasExists(alias string) (bool, error) {
 	return r0, r1
 }
 
-// GetAliasByURL provides a mock function with given fields: urlToFind
-func (_m *URLSaver) GetAliasByURL(urlToFind string) (string, error) {
-	ret := _m.Called(urlToFind)
+// GetAliasByURL provides a mock function with given fields: ctx, urlToFind
+func (_m *URLSaver) GetAliasByURL(ctx context.Context, urlToFind string) (string, error) {
+	ret := _m.Called(ctx, urlToFind)
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (string, error)); ok {
-		return rf(urlToFind)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, urlToFind)
 	}
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(urlToFind)
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, urlToFind)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(urlToFind)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, urlToFind)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUTMPreset provides a mock function with given fields: ctx, id
+func (_m *URLSaver) GetUTMPreset(ctx context.Context, id int64) (storage.UTMPreset, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 storage.UTMPreset
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (storage.UTMPreset, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) storage.UTMPreset); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(storage.UTMPreset)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -57,23 +86,23 @@ func (_m *URLSaver) GetAliasByURL(urlToFind string) (string, error) {
 	return r0, r1
 }
 
-// SaveURL provides a mock function with given fields: urlToSave, alias
-func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
-	ret := _m.Called(urlToSave, alias)
+// SaveLink provides a mock function with given fields: ctx, link
+func (_m *URLSaver) SaveLink(ctx context.Context, link storage.Link) (int64, error) {
+	ret := _m.Called(ctx, link)
 
 	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (int64, error)); ok {
-		return rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Link) (int64, error)); ok {
+		return rf(ctx, link)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) int64); ok {
-		r0 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, storage.Link) int64); ok {
+		r0 = rf(ctx, link)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(1).(func(context.Context, storage.Link) error); ok {
+		r1 = rf(ctx, link)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -81,23 +110,23 @@ func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
 	return r0, r1
 }
 
-// URLExists provides a mock function with given fields: urlToCheck
-func (_m *URLSaver) URLExists(urlToCheck string) (bool, error) {
-	ret := _m.Called(urlToCheck)
+// URLExists provides a mock function with given fields: ctx, urlToCheck
+func (_m *URLSaver) URLExists(ctx context.Context, urlToCheck string) (bool, error) {
+	ret := _m.Called(ctx, urlToCheck)
 
 	var r0 bool
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (bool, error)); ok {
-		return rf(urlToCheck)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, urlToCheck)
 	}
-	if rf, ok := ret.Get(0).(func(string) bool); ok {
-		r0 = rf(urlToCheck)
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, urlToCheck)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(urlToCheck)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, urlToCheck)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -1,14 +1,21 @@
 package save
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
+	"url-shortener/internal/lib/aliaspolicy"
 	"url-shortener/internal/lib/api/response"
-	utils "url-shortener/internal/lib/helpers"
 	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/password"
 	"url-shortener/internal/lib/random"
+	"url-shortener/internal/lib/tags"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/lib/utm"
 	"url-shortener/internal/storage"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -17,13 +24,65 @@ import (
 )
 
 type Request struct {
-	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	URL          string    `json:"url" validate:"required,url"`
+	Alias        string    `json:"alias,omitempty"`
+	Password     string    `json:"password,omitempty" validate:"omitempty,min=4"`   // at most password.MaxBytes bytes
+	MaxClicks    int64     `json:"max_clicks,omitempty" validate:"omitempty,min=1"` // the link stops working after this many redirects
+	NotBefore    string    `json:"not_before,omitempty"`                            // RFC 3339, or a local time in Timezone
+	NotAfter     string    `json:"not_after,omitempty"`                             // RFC 3339, or a local time in Timezone
+	Timezone     string    `json:"timezone,omitempty"`                              // IANA name, UTC when empty
+	QueryMode    string    `json:"query_mode,omitempty" validate:"omitempty,oneof=target request append"`
+	ForwardPath  bool      `json:"forward_path,omitempty"` // append the path after the alias
+	RedirectType string    `json:"redirect_type,omitempty" validate:"omitempty,oneof=301 302 307 308 interstitial"`
+	Variants     []Variant `json:"variants,omitempty" validate:"omitempty,min=2,dive"` // split the traffic instead of redirecting to URL
+	FallbackURL  string    `json:"fallback_url,omitempty" validate:"omitempty,url"`    // used once the link checker finds URL broken
+	UTM          *UTM      `json:"utm,omitempty"`                                      // tags added to URL before it is saved
+	Title        string    `json:"title,omitempty" validate:"max=200"`
+	Description  string    `json:"description,omitempty" validate:"max=1000"`
+	Notes        string    `json:"notes,omitempty" validate:"max=5000"`
+	Tags         []string  `json:"tags,omitempty" validate:"max=20,dive,max=50"`
+	// Open Graph tags for chat app previews, the ones of the destination are used when empty
+	OGTitle       string `json:"og_title,omitempty" validate:"max=200"`
+	OGDescription string `json:"og_description,omitempty" validate:"max=1000"`
+	OGImage       string `json:"og_image,omitempty" validate:"omitempty,http_url,max=2000"`
+}
+
+// UTM tags of a request. Tags set explicitly override the ones of the preset.
+type UTM struct {
+	PresetID int64 `json:"preset_id,omitempty" validate:"omitempty,min=1"`
+	utm.Params
+}
+
+// Variant is one of the destinations of an A/B split.
+type Variant struct {
+	URL    string `json:"url" validate:"required,url"`
+	Weight int    `json:"weight" validate:"required,min=1,max=1000000"`
+}
+
+// LogValue keeps the password out of the logs.
+func (r Request) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("url", r.URL),
+		slog.String("alias", r.Alias),
+		slog.Bool("protected", r.Password != ""),
+		slog.Int64("max_clicks", r.MaxClicks),
+		slog.String("not_before", r.NotBefore),
+		slog.String("not_after", r.NotAfter),
+		slog.String("timezone", r.Timezone),
+		slog.String("query_mode", r.QueryMode),
+		slog.Bool("forward_path", r.ForwardPath),
+		slog.String("redirect_type", r.RedirectType),
+		slog.Int("variants", len(r.Variants)),
+		slog.String("fallback_url", r.FallbackURL),
+		slog.Any("utm", r.UTM),
+		slog.Any("tags", r.Tags),
+	)
 }
 
 type Response struct {
 	response.Response
-	Alias string `json:"alias,omitempty"`
+	Alias       string   `json:"alias,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"` // available aliases similar to a taken one
 }
 
 // TODO: move to config
@@ -31,13 +90,37 @@ const aliasLength = 4
 
 //go:generate go run github.com/vektra/mockery/v2 --name=URLSaver --case=snake
 type URLSaver interface {
-	SaveURL(urlToSave string, alias string) (int64, error)
-	AliasExists(alias string) (bool, error)
-	URLExists(urlToCheck string) (bool, error)
-	GetAliasByURL(urlToFind string) (string, error)
+	SaveLink(ctx context.Context, link storage.Link) (int64, error)
+	AliasExists(ctx context.Context, alias string) (bool, error)
+	URLExists(ctx context.Context, urlToCheck string) (bool, error)
+	GetAliasByURL(ctx context.Context, urlToFind string) (string, error)
+	GetUTMPreset(ctx context.Context, id int64) (storage.UTMPreset, error)
+}
+
+// PageFetcher fetches the title and Open Graph tags of the destinations of new links in the background.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=PageFetcher --case=snake
+type PageFetcher interface {
+	Enqueue(alias, url string)
+}
+
+// AliasSuggester offers available aliases similar to a taken one.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=AliasSuggester --case=snake
+type AliasSuggester interface {
+	Suggest(ctx context.Context, alias string) ([]string, error)
 }
 
-func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+// New creates the handler saving links. Destinations breaking policy and aliases breaking aliases
+// are rejected. pages and suggester may be nil.
+func New(
+	log *slog.Logger,
+	urlSaver URLSaver,
+	policy *urlpolicy.Policy,
+	aliases *aliaspolicy.Policy,
+	pages PageFetcher,
+	suggester AliasSuggester,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 
@@ -77,19 +160,115 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 
 			return
 		}
+		// the validator counts characters, a non-ASCII password may be short in them but too long in bytes
+		if len(req.Password) > password.MaxBytes {
+			log.Info("password is too long", slog.Int("bytes", len(req.Password)))
 
-		alias := req.Alias
-		if alias == "" {
-			// Generate a random alias until a unique one is found
-			exists, err := urlSaver.URLExists(req.URL)
+			render.JSON(w, r, response.Error(fmt.Sprintf("field Password is longer than %d bytes", password.MaxBytes)))
+
+			return
+		}
+
+		notBefore, notAfter, err := parseSchedule(req)
+		if err != nil {
+			log.Info("invalid schedule", sl.Err(err))
+
+			render.JSON(w, r, response.Error(err.Error()))
+
+			return
+		}
+
+		target := req.URL
+		if req.UTM != nil {
+			params := req.UTM.Params
+			if req.UTM.PresetID != 0 {
+				preset, err := urlSaver.GetUTMPreset(r.Context(), req.UTM.PresetID)
+				if errors.Is(err, storage.ErrPresetNotFound) {
+					log.Info("utm preset not found", slog.Int64("preset_id", req.UTM.PresetID))
+
+					render.JSON(w, r, response.Error("utm preset not found"))
+
+					return
+				}
+				if err != nil {
+					log.Error("failed to get utm preset", sl.Err(err))
+
+					render.JSON(w, r, response.Error("failed to add url"))
+
+					return
+				}
+				params = presetParams(preset).Merge(params)
+			}
+
+			target, err = utm.Apply(req.URL, params)
 			if err != nil {
-				log.Error("failed to check that URL exists in DB", sl.Err(err))
-				render.JSON(w, r, response.Error("failed to check that URL exists in DB"))
+				log.Info("failed to add utm tags", sl.Err(err))
+
+				render.JSON(w, r, response.Error("field URL is not a valid URL"))
+
+				return
+			}
+		}
+
+		link := storage.Link{
+			URL:          target,
+			MaxClicks:    req.MaxClicks,
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+			QueryMode:    req.QueryMode,
+			ForwardPath:  req.ForwardPath,
+			RedirectType: req.RedirectType,
+			FallbackURL:  req.FallbackURL,
+			CreatedAt:    time.Now().UTC(),
+			Meta: storage.Meta{
+				Title:       req.Title,
+				Description: req.Description,
+				Notes:       req.Notes,
+				Tags:        tags.Normalize(req.Tags),
+
+				OGTitle:       req.OGTitle,
+				OGDescription: req.OGDescription,
+				OGImage:       req.OGImage,
+			},
+		}
+		for _, v := range req.Variants {
+			link.Variants = append(link.Variants, storage.Variant{URL: v.URL, Weight: v.Weight})
+		}
+
+		if err := checkPolicy(policy, link); err != nil {
+			log.Info("url rejected by policy", sl.Err(err))
+
+			render.JSON(w, r, response.Error(err.Error()))
+
+			return
+		}
+
+		alias := aliases.Normalize(req.Alias)
+		if alias != "" {
+			if err := aliases.Check(alias); err != nil {
+				log.Info("alias rejected by policy", slog.String("alias", req.Alias), sl.Err(err))
+
+				render.JSON(w, r, response.Error(err.Error()))
+
 				return
 			}
+		}
+
+		if alias == "" {
+			// A link with settings must not be handed out as an existing plain one, and vice versa:
+			// the storage only finds plain links by URL
+			exists := false
+			if req.Password == "" && link.Plain() {
+				exists, err = urlSaver.URLExists(r.Context(), link.URL)
+				if err != nil {
+					log.Error("failed to check that URL exists in DB", sl.Err(err))
+					render.JSON(w, r, response.Error("failed to check that URL exists in DB"))
+					return
+				}
+			}
 
 			if exists {
-				alias, err = urlSaver.GetAliasByURL(req.URL)
+				alias, err = urlSaver.GetAliasByURL(r.Context(), link.URL)
 				if err != nil {
 					log.Error("failed to get alias connected to URL", sl.Err(err))
 					render.JSON(w, r, response.Error("failed to get alias connected to URL"))
@@ -100,12 +279,17 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 				return
 			}
 
+			// Generate a random alias until a unique one is found
 			const maxAttempts = 64 // Maximum number of generation attempts
 			exists = true
 
 			for attempt := 1; attempt <= maxAttempts; attempt++ {
-				alias = random.NewRandomString(aliasLength)
-				exists, err = urlSaver.AliasExists(alias)
+				alias = aliases.Normalize(random.NewRandomString(max(aliasLength, aliases.MinLength())))
+				if aliases.Check(alias) != nil {
+					// spells a reserved or offensive word
+					continue
+				}
+				exists, err = urlSaver.AliasExists(r.Context(), alias)
 				if err != nil {
 					log.Error("failed to generate alias", sl.Err(err))
 					render.JSON(w, r, response.Error("failed to generate url"))
@@ -123,19 +307,35 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			}
 		}
 
-		if !utils.IsValidAlias(alias) {
-			log.Info("url alias not valid", slog.String("alias", req.URL))
+		link.Alias = alias
+		if req.Password != "" {
+			link.PasswordHash, err = password.Hash(req.Password)
+			if err != nil {
+				log.Error("failed to hash password", sl.Err(err))
 
-			render.JSON(w, r, response.Error("url alias not valid"))
+				render.JSON(w, r, response.Error("failed to add url"))
 
-			return
+				return
+			}
 		}
 
-		id, err := urlSaver.SaveURL(req.URL, alias)
+		id, err := urlSaver.SaveLink(r.Context(), link)
 		if errors.Is(err, storage.ErrURLExists) {
 			log.Info("url already exists", slog.String("url", req.URL))
 
-			render.JSON(w, r, response.Error("url already exists"))
+			var suggestions []string
+			if req.Alias != "" && suggester != nil {
+				suggestions, err = suggester.Suggest(r.Context(), alias)
+				if err != nil {
+					// the conflict is reported anyway
+					log.Error("failed to suggest aliases", sl.Err(err))
+				}
+			}
+
+			render.JSON(w, r, Response{
+				Response:    response.Error("url already exists"),
+				Suggestions: suggestions,
+			})
 
 			return
 		}
@@ -149,10 +349,37 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 
 		log.Info("url saved", slog.Int64("id", id))
 
+		if pages != nil {
+			pages.Enqueue(alias, link.URL)
+		}
+
 		responseOK(w, r, alias)
 	}
 }
 
+func presetParams(p storage.UTMPreset) utm.Params {
+	return utm.Params{Source: p.Source, Medium: p.Medium, Campaign: p.Campaign, Term: p.Term, Content: p.Content}
+}
+
+// checkPolicy checks the URL of link, its fallback URL and the ones of its variants.
+func checkPolicy(policy *urlpolicy.Policy, link storage.Link) error {
+	if err := policy.Check(link.URL); err != nil {
+		return err
+	}
+	if link.FallbackURL != "" {
+		if err := policy.Check(link.FallbackURL); err != nil {
+			return err
+		}
+	}
+	for _, v := range link.Variants {
+		if err := policy.Check(v.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func responseOK(w http.ResponseWriter, r *http.Request, alias string) {
 	render.JSON(w, r, Response{
 		Response: response.Ok(),
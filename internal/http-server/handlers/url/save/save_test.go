@@ -4,24 +4,55 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"url-shortener/internal/http-server/handlers/url/save"
 	"url-shortener/internal/http-server/handlers/url/save/mocks"
+	"url-shortener/internal/lib/aliaspolicy"
 	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/password"
+	"url-shortener/internal/lib/urlpolicy"
+	"url-shortener/internal/lib/utm"
+	"url-shortener/internal/storage"
 )
 
+func newPolicy(t *testing.T) *urlpolicy.Policy {
+	policy, err := urlpolicy.New(urlpolicy.Config{ShortDomains: []string{"sho.rt"}})
+	require.NoError(t, err)
+
+	return policy
+}
+
+func newAliasPolicy(t *testing.T) *aliaspolicy.Policy {
+	aliases, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 3, MaxLength: 32, Reserved: []string{"admin", "url"}})
+	require.NoError(t, err)
+
+	return aliases
+}
+
 func TestSaveHandler(t *testing.T) {
 	cases := []struct {
 		name      string
 		alias     string
 		url       string
+		password  string
+		maxClicks int64
+		notBefore string
+		notAfter  string
+		timezone  string
+		queryMode string
+		redirect  string
+		variants  []save.Variant
+		fallback  string
+		wantStart time.Time
 		respError string
 		mockError error
 	}{
@@ -35,6 +66,136 @@ func TestSaveHandler(t *testing.T) {
 			alias: "",
 			url:   "https://google.com",
 		},
+		{
+			name:     "Protected",
+			alias:    "test_alias",
+			url:      "https://google.com",
+			password: "secret",
+		},
+		{
+			name:     "Protected with empty alias",
+			alias:    "",
+			url:      "https://google.com",
+			password: "secret",
+		},
+		{
+			name:      "One-time with empty alias",
+			alias:     "",
+			url:       "https://google.com",
+			maxClicks: 1,
+		},
+		{
+			name:      "Negative max clicks",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			maxClicks: -1,
+			respError: "field MaxClicks is not valid",
+		},
+		{
+			name:      "Scheduled with offset",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			notBefore: "2030-01-01T09:00:00+03:00",
+			wantStart: time.Date(2030, 1, 1, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "Scheduled in timezone",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			notBefore: "2030-07-01T09:00",
+			notAfter:  "2030-07-02",
+			timezone:  "Europe/Berlin",
+			wantStart: time.Date(2030, 7, 1, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "Invalid timezone",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			notBefore: "2030-07-01T09:00",
+			timezone:  "Mars/Olympus",
+			respError: "field Timezone is not a valid timezone",
+		},
+		{
+			name:      "Invalid time",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			notAfter:  "tomorrow",
+			respError: "field NotAfter is not a valid time",
+		},
+		{
+			name:      "Window ends before it starts",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			notBefore: "2030-01-02T00:00:00Z",
+			notAfter:  "2030-01-01T00:00:00Z",
+			respError: "field NotAfter must be later than NotBefore",
+		},
+		{
+			name:      "Forwarding with empty alias",
+			alias:     "",
+			url:       "https://google.com",
+			queryMode: "request",
+		},
+		{
+			name:      "Invalid query mode",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			queryMode: "sometimes",
+			respError: "field QueryMode is not valid",
+		},
+		{
+			name:      "Invalid redirect type",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			redirect:  "303",
+			respError: "field RedirectType is not valid",
+		},
+		{
+			name:  "Variants with empty alias",
+			alias: "",
+			url:   "https://google.com",
+			variants: []save.Variant{
+				{URL: "https://google.com/a", Weight: 1},
+				{URL: "https://google.com/b", Weight: 3},
+			},
+		},
+		{
+			name:      "Single variant",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			variants:  []save.Variant{{URL: "https://google.com/a", Weight: 1}},
+			respError: "field Variants is not valid",
+		},
+		{
+			name:  "Variant without weight",
+			alias: "test_alias",
+			url:   "https://google.com",
+			variants: []save.Variant{
+				{URL: "https://google.com/a", Weight: 1},
+				{URL: "https://google.com/b"},
+			},
+			respError: "field Weight is a required field",
+		},
+		{
+			name:      "Short password",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			password:  "abc",
+			respError: "field Password is not valid",
+		},
+		{
+			name:     "Non-ASCII password within the bcrypt limit",
+			alias:    "test_alias",
+			url:      "https://google.com",
+			password: strings.Repeat("пароль", 5), // 30 characters, 60 bytes
+		},
+		{
+			name:      "Non-ASCII password over the bcrypt limit",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			password:  strings.Repeat("пароль", 7), // 42 characters, 84 bytes
+			respError: "field Password is longer than 72 bytes",
+		},
 		{
 			name:      "Empty URL",
 			url:       "",
@@ -48,7 +209,67 @@ func TestSaveHandler(t *testing.T) {
 			respError: "field URL is not a valid URL",
 		},
 		{
-			name:      "SaveURL Error",
+			name:      "Javascript URL",
+			url:       "javascript:alert(1)",
+			alias:     "some_alias",
+			respError: `url rejected: scheme "javascript" is not allowed`,
+		},
+		{
+			name:      "Redirect loop",
+			url:       "https://sho.rt/abcd",
+			alias:     "some_alias",
+			respError: "url rejected: url leads to a short link",
+		},
+		{
+			name:  "Variant rejected",
+			alias: "test_alias",
+			url:   "https://google.com",
+			variants: []save.Variant{
+				{URL: "https://google.com/a", Weight: 1},
+				{URL: "https://sho.rt/b", Weight: 1},
+			},
+			respError: "url rejected: url leads to a short link",
+		},
+		{
+			name:     "Fallback with empty alias",
+			alias:    "",
+			url:      "https://google.com",
+			fallback: "https://web.archive.org/web/https://google.com",
+		},
+		{
+			name:      "Invalid fallback",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			fallback:  "not a url",
+			respError: "field FallbackURL is not a valid URL",
+		},
+		{
+			name:      "Fallback rejected",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			fallback:  "https://sho.rt/b",
+			respError: "url rejected: url leads to a short link",
+		},
+		{
+			name:      "Invalid alias",
+			alias:     "my alias",
+			url:       "https://google.com",
+			respError: "alias rejected: alias may only contain latin letters, digits and underscores",
+		},
+		{
+			name:      "Short alias",
+			alias:     "ab",
+			url:       "https://google.com",
+			respError: "alias rejected: alias is shorter than 3 characters",
+		},
+		{
+			name:      "Reserved alias",
+			alias:     "URL",
+			url:       "https://google.com",
+			respError: `alias rejected: alias "URL" is reserved`,
+		},
+		{
+			name:      "SaveLink Error",
 			alias:     "test_alias",
 			url:       "https://google.com",
 			respError: "failed to add url",
@@ -64,17 +285,58 @@ func TestSaveHandler(t *testing.T) {
 
 			urlSaverMock := mocks.NewURLSaver(t)
 
+			if tc.alias == "" && tc.respError == "" {
+				if tc.password == "" && tc.maxClicks == 0 && tc.queryMode == "" && len(tc.variants) == 0 && tc.fallback == "" {
+					urlSaverMock.On("URLExists", mock.Anything, tc.url).
+						Return(false, nil).
+						Once()
+				}
+				urlSaverMock.On("AliasExists", mock.Anything, mock.AnythingOfType("string")).
+					Return(false, nil).
+					Once()
+			}
+
 			if tc.respError == "" || tc.mockError != nil {
-				urlSaverMock.On("SaveURL", tc.url, mock.AnythingOfType("string")).
+				matchLink := mock.MatchedBy(func(link storage.Link) bool {
+					if link.CreatedAt.IsZero() || link.URL != tc.url || link.MaxClicks != tc.maxClicks || link.QueryMode != tc.queryMode || link.FallbackURL != tc.fallback || !link.NotBefore.Equal(tc.wantStart) {
+						return false
+					}
+					if len(link.Variants) != len(tc.variants) {
+						return false
+					}
+					for i, v := range tc.variants {
+						if link.Variants[i].URL != v.URL || link.Variants[i].Weight != v.Weight {
+							return false
+						}
+					}
+					if tc.password == "" {
+						return !link.Protected()
+					}
+					return password.Verify(link.PasswordHash, tc.password)
+				})
+				urlSaverMock.On("SaveLink", mock.Anything, matchLink).
 					Return(int64(1), tc.mockError).
 					Once()
 			}
 
-			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock)
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newPolicy(t), newAliasPolicy(t), nil, nil)
 
-			input := fmt.Sprintf(`{"url": "%s", "alias": "%s"}`, tc.url, tc.alias)
+			input, err := json.Marshal(save.Request{
+				URL:          tc.url,
+				Alias:        tc.alias,
+				Password:     tc.password,
+				MaxClicks:    tc.maxClicks,
+				NotBefore:    tc.notBefore,
+				NotAfter:     tc.notAfter,
+				Timezone:     tc.timezone,
+				QueryMode:    tc.queryMode,
+				RedirectType: tc.redirect,
+				Variants:     tc.variants,
+				FallbackURL:  tc.fallback,
+			})
+			require.NoError(t, err)
 
-			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(input)))
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader(input))
 			require.NoError(t, err)
 
 			rr := httptest.NewRecorder()
@@ -97,3 +359,156 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveHandler_UTM(t *testing.T) {
+	preset := storage.UTMPreset{ID: 5, Name: "newsletter", Source: "newsletter", Medium: "email", Campaign: "spring"}
+
+	cases := []struct {
+		name      string
+		url       string
+		utm       *save.UTM
+		wantURL   string
+		respError string
+	}{
+		{
+			name:    "Explicit tags",
+			url:     "https://google.com/?q=go&utm_source=old",
+			utm:     &save.UTM{Params: utm.Params{Source: "twitter", Campaign: "black friday"}},
+			wantURL: "https://google.com/?q=go&utm_campaign=black+friday&utm_source=twitter",
+		},
+		{
+			name:    "Preset with override",
+			url:     "https://google.com/",
+			utm:     &save.UTM{PresetID: 5, Params: utm.Params{Campaign: "summer"}},
+			wantURL: "https://google.com/?utm_campaign=summer&utm_medium=email&utm_source=newsletter",
+		},
+		{
+			name:      "Unknown preset",
+			url:       "https://google.com/",
+			utm:       &save.UTM{PresetID: 6},
+			respError: "utm preset not found",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			urlSaverMock.On("GetUTMPreset", mock.Anything, int64(5)).
+				Return(preset, nil).
+				Maybe()
+			urlSaverMock.On("GetUTMPreset", mock.Anything, int64(6)).
+				Return(storage.UTMPreset{}, storage.ErrPresetNotFound).
+				Maybe()
+
+			if tc.respError == "" {
+				// the tagged URL is what gets deduplicated and saved
+				urlSaverMock.On("URLExists", mock.Anything, tc.wantURL).
+					Return(false, nil).
+					Once()
+				urlSaverMock.On("AliasExists", mock.Anything, mock.AnythingOfType("string")).
+					Return(false, nil).
+					Once()
+				urlSaverMock.On("SaveLink", mock.Anything, mock.MatchedBy(func(link storage.Link) bool {
+					return link.URL == tc.wantURL
+				})).
+					Return(int64(1), nil).
+					Once()
+			}
+
+			input, err := json.Marshal(save.Request{URL: tc.url, UTM: tc.utm})
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newPolicy(t), newAliasPolicy(t), nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(input)))
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
+
+func TestSaveHandler_Meta(t *testing.T) {
+	urlSaverMock := mocks.NewURLSaver(t)
+	// links with metadata are never deduplicated against existing ones
+	urlSaverMock.On("AliasExists", mock.Anything, mock.AnythingOfType("string")).
+		Return(false, nil).
+		Once()
+	urlSaverMock.On("SaveLink", mock.Anything, mock.MatchedBy(func(link storage.Link) bool {
+		return link.Title == "Go" && link.Notes == "spring campaign" && reflect.DeepEqual(link.Tags, []string{"go", "lang"}) &&
+			link.OGImage == "https://go.dev/og.png"
+	})).
+		Return(int64(1), nil).
+		Once()
+
+	pagesMock := mocks.NewPageFetcher(t)
+	pagesMock.On("Enqueue", mock.AnythingOfType("string"), "https://go.dev").
+		Once()
+
+	input, err := json.Marshal(save.Request{
+		URL:     "https://go.dev",
+		Title:   "Go",
+		Notes:   "spring campaign",
+		Tags:    []string{"Lang", " go ", "lang"},
+		OGImage: "https://go.dev/og.png",
+	})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newPolicy(t), newAliasPolicy(t), pagesMock, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(input)))
+
+	var resp save.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Empty(t, resp.Error)
+	require.NotEmpty(t, resp.Alias)
+}
+
+func TestSaveHandler_CaseInsensitive(t *testing.T) {
+	aliases, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 3, CaseInsensitive: true, Unicode: true})
+	require.NoError(t, err)
+
+	urlSaverMock := mocks.NewURLSaver(t)
+	urlSaverMock.On("SaveLink", mock.Anything, mock.MatchedBy(func(link storage.Link) bool {
+		return link.Alias == "привет-2024"
+	})).
+		Return(int64(1), nil).
+		Once()
+
+	input, err := json.Marshal(save.Request{URL: "https://go.dev", Alias: "Привет-2024"})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newPolicy(t), aliases, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(input)))
+
+	var resp save.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Empty(t, resp.Error)
+	require.Equal(t, "привет-2024", resp.Alias)
+}
+
+func TestSaveHandler_AliasTaken(t *testing.T) {
+	urlSaverMock := mocks.NewURLSaver(t)
+	urlSaverMock.On("SaveLink", mock.Anything, mock.AnythingOfType("storage.Link")).
+		Return(int64(0), storage.ErrURLExists).
+		Once()
+
+	suggesterMock := mocks.NewAliasSuggester(t)
+	suggesterMock.On("Suggest", mock.Anything, "promo").
+		Return([]string{"offer", "promo2"}, nil).
+		Once()
+
+	input, err := json.Marshal(save.Request{URL: "https://go.dev", Alias: "promo"})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newPolicy(t), newAliasPolicy(t), nil, suggesterMock).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(input)))
+
+	var resp save.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "url already exists", resp.Error)
+	require.Equal(t, []string{"offer", "promo2"}, resp.Suggestions)
+}
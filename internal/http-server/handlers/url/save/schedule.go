@@ -0,0 +1,62 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// localLayouts are accepted for times without an offset, they are read in the request timezone.
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseSchedule reads the activation window of the request.
+// Zero times mean that the corresponding bound is not set.
+func parseSchedule(req Request) (notBefore, notAfter time.Time, err error) {
+	loc := time.UTC
+	if req.Timezone != "" {
+		loc, err = time.LoadLocation(req.Timezone)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("field Timezone is not a valid timezone")
+		}
+	}
+
+	if notBefore, err = parseTime(req.NotBefore, loc); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("field NotBefore %w", err)
+	}
+	if notAfter, err = parseTime(req.NotAfter, loc); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("field NotAfter %w", err)
+	}
+
+	if !notBefore.IsZero() && !notAfter.IsZero() && !notAfter.After(notBefore) {
+		return time.Time{}, time.Time{}, errors.New("field NotAfter must be later than NotBefore")
+	}
+
+	return notBefore.UTC(), notAfter.UTC(), nil
+}
+
+var errInvalidTime = errors.New("is not a valid time")
+
+// parseTime accepts RFC 3339 times with an offset, and local times which are read in loc.
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errInvalidTime
+}
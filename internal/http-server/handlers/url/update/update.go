@@ -0,0 +1,149 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	resp "url-shortener/internal/lib/api/response"
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/tags"
+	"url-shortener/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// Request changes the metadata of a link. Fields left out keep their value, empty ones clear it.
+type Request struct {
+	Title       *string   `json:"title,omitempty" validate:"omitempty,max=200"`
+	Description *string   `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Notes       *string   `json:"notes,omitempty" validate:"omitempty,max=5000"`
+	Tags        *[]string `json:"tags,omitempty" validate:"omitempty,max=20,dive,max=50"`
+
+	OGTitle       *string `json:"og_title,omitempty" validate:"omitempty,max=200"`
+	OGDescription *string `json:"og_description,omitempty" validate:"omitempty,max=1000"`
+	OGImage       *string `json:"og_image,omitempty" validate:"omitempty,max=2000"` // checked by New, an empty one clears the image
+}
+
+type Response struct {
+	resp.Response
+	storage.Meta
+}
+
+// MetaUpdater must be the storage itself, cached links may hold outdated metadata.
+//
+//go:generate go run github.com/vektra/mockery/v2 --name=MetaUpdater --case=snake
+type MetaUpdater interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+	UpdateMeta(ctx context.Context, alias string, meta storage.Meta) error
+}
+
+func New(log *slog.Logger, updater MetaUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.update.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.Error("request validation failed", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+		if req.OGImage != nil && *req.OGImage != "" {
+			if err := validate.Var(*req.OGImage, "http_url"); err != nil {
+				log.Error("request validation failed", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("field OGImage is not a valid URL"))
+
+				return
+			}
+		}
+
+		link, err := updater.GetLink(r.Context(), alias)
+		if err != nil {
+			renderStoreError(w, r, log, err, alias)
+
+			return
+		}
+
+		meta := link.Meta
+		if req.Title != nil {
+			meta.Title = *req.Title
+		}
+		if req.Description != nil {
+			meta.Description = *req.Description
+		}
+		if req.Notes != nil {
+			meta.Notes = *req.Notes
+		}
+		if req.Tags != nil {
+			meta.Tags = tags.Normalize(*req.Tags)
+		}
+		if req.OGTitle != nil {
+			meta.OGTitle = *req.OGTitle
+		}
+		if req.OGDescription != nil {
+			meta.OGDescription = *req.OGDescription
+		}
+		if req.OGImage != nil {
+			meta.OGImage = *req.OGImage
+		}
+
+		if err := updater.UpdateMeta(r.Context(), alias, meta); err != nil {
+			renderStoreError(w, r, log, err, alias)
+
+			return
+		}
+
+		log.Info("link updated", slog.String("alias", alias))
+
+		render.JSON(w, r, Response{Response: resp.Ok(), Meta: meta})
+	}
+}
+
+func renderStoreError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error, alias string) {
+	if errors.Is(err, storage.ErrURLNotFound) {
+		log.Info("url not found", slog.String("alias", alias))
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("not found"))
+
+		return
+	}
+
+	log.Error("failed to update url", sl.Err(err))
+
+	render.JSON(w, r, resp.Error("failed to update url"))
+}
@@ -0,0 +1,117 @@
+package update_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/http-server/handlers/url/update"
+	"url-shortener/internal/http-server/handlers/url/update/mocks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	current := storage.Meta{Title: "Go", Description: "The Go site", Tags: []string{"go"}}
+
+	cases := []struct {
+		name      string
+		alias     string
+		body      string
+		want      *storage.Meta // passed to the storage, not called when nil
+		code      int
+		respError string
+	}{
+		{
+			name:  "Partial",
+			alias: "abcd",
+			body:  `{"title": "Go home", "tags": ["Lang", "go"]}`,
+			want:  &storage.Meta{Title: "Go home", Description: "The Go site", Tags: []string{"go", "lang"}},
+			code:  http.StatusOK,
+		},
+		{
+			name:  "Clear",
+			alias: "abcd",
+			body:  `{"description": "", "tags": []}`,
+			want:  &storage.Meta{Title: "Go"},
+			code:  http.StatusOK,
+		},
+		{
+			name:  "Open Graph",
+			alias: "abcd",
+			body:  `{"og_title": "Go!", "og_image": "https://go.dev/og.png"}`,
+			want:  &storage.Meta{Title: "Go", Description: "The Go site", Tags: []string{"go"}, OGTitle: "Go!", OGImage: "https://go.dev/og.png"},
+			code:  http.StatusOK,
+		},
+		{
+			name:  "Clear image",
+			alias: "abcd",
+			body:  `{"og_image": ""}`,
+			want:  &current,
+			code:  http.StatusOK,
+		},
+		{
+			name:      "Invalid image",
+			alias:     "abcd",
+			body:      `{"og_image": "javascript:alert(1)"}`,
+			code:      http.StatusOK,
+			respError: "field OGImage is not a valid URL",
+		},
+		{
+			name:      "Too many tags",
+			alias:     "abcd",
+			body:      `{"tags": [` + strings.Repeat(`"t",`, 20) + `"t"]}`,
+			code:      http.StatusOK,
+			respError: "field Tags is not valid",
+		},
+		{
+			name:      "Not found",
+			alias:     "none",
+			body:      `{"title": "None"}`,
+			code:      http.StatusNotFound,
+			respError: "not found",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			updaterMock := mocks.NewMetaUpdater(t)
+			updaterMock.On("GetLink", mock.Anything, "abcd").
+				Return(storage.Link{Alias: "abcd", URL: "https://go.dev", Meta: current}, nil).
+				Maybe()
+			updaterMock.On("GetLink", mock.Anything, "none").
+				Return(storage.Link{}, storage.ErrURLNotFound).
+				Maybe()
+			if tc.want != nil {
+				updaterMock.On("UpdateMeta", mock.Anything, tc.alias, *tc.want).
+					Return(nil).
+					Once()
+			}
+
+			r := chi.NewRouter()
+			r.Patch("/url/{alias}", update.New(slogdiscard.NewDiscardLogger(), updaterMock))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/url/"+tc.alias, strings.NewReader(tc.body)))
+
+			require.Equal(t, tc.code, rr.Code)
+
+			var resp update.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+			if tc.want != nil {
+				require.Equal(t, *tc.want, resp.Meta)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package aliaspolicy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"url-shortener/internal/lib/confusable"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Rules an alias can be rejected by.
+const (
+	RuleLength     = "length"
+	RuleCharset    = "charset"
+	RuleReserved   = "reserved"
+	RuleProfanity  = "profanity"
+	RuleConfusable = "confusable"
+)
+
+var ErrRejected = errors.New("alias rejected")
+
+// Error tells which rule rejected an alias.
+type Error struct {
+	Rule   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRejected, e.Reason)
+}
+
+func (e *Error) Unwrap() error {
+	return ErrRejected
+}
+
+// Config of a Policy. Words are compared ignoring case.
+type Config struct {
+	MinLength       int      // in characters, 1 when 0
+	MaxLength       int      // in characters, unlimited when 0
+	Reserved        []string // aliases nobody may claim, the first segments of the routes are added by Reserve
+	ProfanityFile   string   // a word per line, aliases made of or containing one of them as a word are rejected
+	CaseInsensitive bool     // aliases differing in case only are the same, they are stored and looked up lower-cased
+	Unicode         bool     // allow letters of any script and hyphens, aliases are stored and looked up in NFC
+}
+
+// Policy decides which aliases may be claimed for new links.
+type Policy struct {
+	minLength int
+	maxLength int
+	profanity map[string]bool
+
+	caseInsensitive bool
+	unicode         bool
+
+	mu       sync.RWMutex
+	reserved map[string]bool
+}
+
+var charset = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+func New(cfg Config) (*Policy, error) {
+	const op = "lib.aliaspolicy.New"
+
+	p := &Policy{
+		minLength: max(cfg.MinLength, 1),
+		maxLength: cfg.MaxLength,
+		profanity: make(map[string]bool),
+		reserved:  make(map[string]bool),
+
+		caseInsensitive: cfg.CaseInsensitive,
+		unicode:         cfg.Unicode,
+	}
+	if p.maxLength > 0 && p.maxLength < p.minLength {
+		return nil, fmt.Errorf("%s: max length %d is less than min length %d", op, p.maxLength, p.minLength)
+	}
+
+	p.Reserve(cfg.Reserved...)
+
+	if cfg.ProfanityFile != "" {
+		words, err := readWordsFile(cfg.ProfanityFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, w := range words {
+			p.profanity[w] = true
+		}
+	}
+
+	return p, nil
+}
+
+// Reserve adds words to the reserved ones.
+func (p *Policy) Reserve(words ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, w := range words {
+		if w = fold(strings.TrimSpace(w)); w != "" {
+			p.reserved[w] = true
+		}
+	}
+}
+
+// MinLength returns the length new aliases must have at least.
+func (p *Policy) MinLength() int {
+	return p.minLength
+}
+
+// Normalize returns the form alias is stored and looked up in: NFC-normalized and, when aliases
+// are case-insensitive, lower-cased.
+func (p *Policy) Normalize(alias string) string {
+	if p.caseInsensitive {
+		alias = strings.ToLower(alias)
+	}
+
+	return norm.NFC.String(alias)
+}
+
+// NormalizeParam replaces the alias URL parameter of the matched route with its normalized form.
+// The parameter is only known once the route is matched, so it must be added with With or Group.
+func (p *Policy) NormalizeParam(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			for i, key := range rctx.URLParams.Keys {
+				if key == "alias" {
+					rctx.URLParams.Values[i] = p.Normalize(rctx.URLParams.Values[i])
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Valid reports whether alias is made of the allowed characters. Unlike Check it does not
+// apply the rules for new aliases, links created before they changed stay reachable.
+func (p *Policy) Valid(alias string) bool {
+	if !p.unicode {
+		return charset.MatchString(alias)
+	}
+
+	return alias != "" && !strings.ContainsFunc(alias, func(r rune) bool { return !unicodeChar(r) })
+}
+
+// unicodeChar reports whether r may be a part of a Unicode alias: a letter of any script with its
+// combining marks, a latin digit, an underscore or a hyphen.
+func unicodeChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.M, r) || ('0' <= r && r <= '9') || r == '_' || r == '-'
+}
+
+// Check returns an *Error if alias may not be claimed for a new link. alias is expected
+// to be normalized.
+func (p *Policy) Check(alias string) error {
+	if p.unicode && strings.ContainsFunc(alias, dashLookalike) {
+		return &Error{Rule: RuleConfusable, Reason: "alias contains a character looking like a hyphen, use -"}
+	}
+	if !p.Valid(alias) {
+		if p.unicode {
+			return &Error{Rule: RuleCharset, Reason: "alias may only contain letters, latin digits, underscores and hyphens"}
+		}
+
+		return &Error{Rule: RuleCharset, Reason: "alias may only contain latin letters, digits and underscores"}
+	}
+
+	n := utf8.RuneCountInString(alias)
+	if n < p.minLength {
+		return &Error{Rule: RuleLength, Reason: fmt.Sprintf("alias is shorter than %d characters", p.minLength)}
+	}
+	if p.maxLength > 0 && n > p.maxLength {
+		return &Error{Rule: RuleLength, Reason: fmt.Sprintf("alias is longer than %d characters", p.maxLength)}
+	}
+
+	if p.unicode {
+		if reason := confusing(alias); reason != "" {
+			return &Error{Rule: RuleConfusable, Reason: reason}
+		}
+	}
+
+	folded := fold(alias)
+
+	p.mu.RLock()
+	reserved := p.reserved[folded]
+	p.mu.RUnlock()
+	if reserved {
+		return &Error{Rule: RuleReserved, Reason: fmt.Sprintf("alias %q is reserved", alias)}
+	}
+
+	if p.offensive(alias) {
+		// the word is not repeated back
+		return &Error{Rule: RuleProfanity, Reason: "alias contains a word that is not allowed"}
+	}
+
+	return nil
+}
+
+// confusing returns why alias could be mistaken for another alias, or "" when it could not.
+func confusing(alias string) string {
+	switch {
+	case norm.NFKC.String(alias) != norm.NFC.String(alias):
+		return "alias contains compatibility characters like fullwidth letters"
+	case confusable.Mixed(alias):
+		return "alias mixes look-alike letters of different scripts"
+	case confusable.LatinLookalike(alias):
+		return "alias is written in letters looking like latin ones"
+	case strings.HasPrefix(alias, "-") || strings.HasSuffix(alias, "-") || strings.Contains(alias, "--"):
+		return "hyphens may only separate letters and digits"
+	}
+
+	return ""
+}
+
+// dashLookalike reports whether r looks like a hyphen without being one.
+func dashLookalike(r rune) bool {
+	switch r {
+	case '-':
+		return false
+	case '\u00ad', '\u02d7', '\u2043', '\u2212': // soft hyphen, modifier minus, hyphen bullet, minus sign
+		return true
+	}
+
+	return unicode.Is(unicode.Pd, r)
+}
+
+// offensive reports whether a word of alias, or alias as a whole once separators are dropped
+// and digits read as the letters they stand for, is a listed word.
+func (p *Policy) offensive(alias string) bool {
+	if len(p.profanity) == 0 {
+		return false
+	}
+
+	for _, w := range words(alias) {
+		if p.profanity[fold(w)] {
+			return true
+		}
+	}
+
+	return p.profanity[deleet(fold(alias))]
+}
+
+// words splits alias at separators, digits and the start of capitalized words: "Bad_wordHere1"
+// has the words "Bad", "word" and "Here".
+func words(alias string) []string {
+	var result []string
+	var cur []rune
+
+	flush := func() {
+		if len(cur) > 0 {
+			result = append(result, string(cur))
+			cur = cur[:0]
+		}
+	}
+
+	var prev rune
+	for _, r := range alias {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.Is(unicode.M, r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+		prev = r
+	}
+	flush()
+
+	return result
+}
+
+// leet maps the digits written in place of letters.
+var leet = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b'}
+
+func deleet(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if l, ok := leet[r]; ok {
+			r = l
+		}
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+func fold(s string) string {
+	return strings.ToLower(s)
+}
+
+// Routes returns the first static segments of the paths handled by r, like "static" for
+// "/static/*" and "url" for "/url/{alias}". Aliases equal to them would be shadowed.
+func Routes(r chi.Routes) ([]string, error) {
+	const op = "lib.aliaspolicy.Routes"
+
+	seen := make(map[string]bool)
+	var result []string
+
+	err := chi.Walk(r, func(_ string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+		if segment == "" || strings.ContainsAny(segment, "{*") || seen[segment] {
+			return nil
+		}
+		seen[segment] = true
+		result = append(result, segment)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+func readWordsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words, err := ReadWords(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return words, nil
+}
+
+// ReadWords reads a word per line, folded to lower case. Empty lines and text after # are skipped.
+func ReadWords(r io.Reader) ([]string, error) {
+	var result []string
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if w := fold(strings.TrimSpace(line)); w != "" {
+			result = append(result, w)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,194 @@
+package aliaspolicy_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/aliaspolicy"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	profanity := filepath.Join(t.TempDir(), "profanity.txt")
+	require.NoError(t, os.WriteFile(profanity, []byte("# offensive words\nbadword\nSnark  # inline comment\n\n"), 0o644))
+
+	p, err := aliaspolicy.New(aliaspolicy.Config{
+		MinLength:     3,
+		MaxLength:     10,
+		Reserved:      []string{"admin", "API"},
+		ProfanityFile: profanity,
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name  string
+		alias string
+		rule  string // empty when accepted
+	}{
+		{name: "Plain", alias: "promo_2024"},
+		{name: "Shortest", alias: "abc"},
+		{name: "Too short", alias: "ab", rule: aliaspolicy.RuleLength},
+		{name: "Too long", alias: "abcdefghijk", rule: aliaspolicy.RuleLength},
+		{name: "Hyphen", alias: "my-link", rule: aliaspolicy.RuleCharset},
+		{name: "Slash", alias: "a/b/c", rule: aliaspolicy.RuleCharset},
+		{name: "Unicode", alias: "привет", rule: aliaspolicy.RuleCharset},
+		{name: "Reserved", alias: "admin", rule: aliaspolicy.RuleReserved},
+		{name: "Reserved in another case", alias: "Api", rule: aliaspolicy.RuleReserved},
+		{name: "Containing a reserved word", alias: "admin_faq"},
+		{name: "Profanity", alias: "BADWORD", rule: aliaspolicy.RuleProfanity},
+		{name: "Profanity as a word", alias: "my_snark", rule: aliaspolicy.RuleProfanity},
+		{name: "Profanity in camel case", alias: "mySnark1", rule: aliaspolicy.RuleProfanity},
+		{name: "Profanity with separators", alias: "b_a_d_word", rule: aliaspolicy.RuleProfanity},
+		{name: "Profanity in digits", alias: "b4dw0rd", rule: aliaspolicy.RuleProfanity},
+		{name: "Profanity inside a word", alias: "snarky"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := p.Check(tc.alias)
+			if tc.rule == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var aliasErr *aliaspolicy.Error
+			require.ErrorAs(t, err, &aliasErr)
+			assert.Equal(t, tc.rule, aliasErr.Rule)
+			assert.True(t, errors.Is(err, aliaspolicy.ErrRejected))
+			assert.NotContains(t, strings.ToLower(err.Error()), "snark")
+		})
+	}
+}
+
+func TestPolicy_Valid(t *testing.T) {
+	p, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 5, Reserved: []string{"admin"}})
+	require.NoError(t, err)
+
+	// existing aliases stay valid when the rules for new ones change
+	assert.True(t, p.Valid("admin"))
+	assert.True(t, p.Valid("ab"))
+	assert.False(t, p.Valid("a b"))
+	assert.False(t, p.Valid(""))
+}
+
+func TestPolicy_Unicode(t *testing.T) {
+	p, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 3, Reserved: []string{"админ"}, Unicode: true})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name  string
+		alias string
+		rule  string // empty when accepted
+	}{
+		{name: "Latin", alias: "promo_2024"},
+		{name: "Cyrillic", alias: "привет"},
+		{name: "Hyphen", alias: "my-link"},
+		{name: "Japanese with latin", alias: "東京tokyo"},
+		{name: "Combining marks", alias: "नमस्ते"},
+		{name: "Space", alias: "my link", rule: aliaspolicy.RuleCharset},
+		{name: "Other digits", alias: "promo٣", rule: aliaspolicy.RuleCharset},
+		{name: "Minus sign", alias: "my\u2212link", rule: aliaspolicy.RuleConfusable},
+		{name: "En dash", alias: "my\u2013link", rule: aliaspolicy.RuleConfusable},
+		{name: "Soft hyphen", alias: "my\u00adlink", rule: aliaspolicy.RuleConfusable},
+		{name: "Leading hyphen", alias: "-link", rule: aliaspolicy.RuleConfusable},
+		{name: "Double hyphen", alias: "my--link", rule: aliaspolicy.RuleConfusable},
+		{name: "Mixed scripts", alias: "pаypal", rule: aliaspolicy.RuleConfusable},
+		{name: "Cyrillic look-alike", alias: "аррӏе", rule: aliaspolicy.RuleConfusable},
+		{name: "Fullwidth", alias: "ｐｒｏｍｏ", rule: aliaspolicy.RuleConfusable},
+		{name: "Reserved", alias: "Админ", rule: aliaspolicy.RuleReserved},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := p.Check(p.Normalize(tc.alias))
+			if tc.rule == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var aliasErr *aliaspolicy.Error
+			require.ErrorAs(t, err, &aliasErr)
+			assert.Equal(t, tc.rule, aliasErr.Rule)
+		})
+	}
+}
+
+func TestPolicy_Normalize(t *testing.T) {
+	decomposed := "cafe\u0301"
+
+	p, err := aliaspolicy.New(aliaspolicy.Config{Unicode: true})
+	require.NoError(t, err)
+	assert.Equal(t, "Caf\u00e9", p.Normalize("C"+decomposed[1:]))
+	assert.Equal(t, "Promo", p.Normalize("Promo"))
+
+	p, err = aliaspolicy.New(aliaspolicy.Config{CaseInsensitive: true, Unicode: true})
+	require.NoError(t, err)
+	assert.Equal(t, "caf\u00e9", p.Normalize(decomposed))
+	assert.Equal(t, "привет", p.Normalize("ПРИВЕТ"))
+}
+
+func TestPolicy_NormalizeParam(t *testing.T) {
+	p, err := aliaspolicy.New(aliaspolicy.Config{CaseInsensitive: true})
+	require.NoError(t, err)
+
+	var got string
+	r := chi.NewRouter()
+	r.With(p.NormalizeParam).Get("/{alias}", func(_ http.ResponseWriter, r *http.Request) {
+		got = chi.URLParam(r, "alias")
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/Promo", nil))
+	assert.Equal(t, "promo", got)
+}
+
+func TestRoutes(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) {}
+
+	r := chi.NewRouter()
+	r.Get("/", noop)
+	r.Get("/healthz", noop)
+	r.Handle("/static/*", http.HandlerFunc(noop))
+	r.Route("/url", func(r chi.Router) {
+		r.Get("/", noop)
+		r.Get("/{alias}", noop)
+	})
+	r.Get("/{alias}", noop)
+	r.Get("/{alias}/*", noop)
+
+	routes, err := aliaspolicy.Routes(r)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"healthz", "static", "url"}, routes)
+
+	p, err := aliaspolicy.New(aliaspolicy.Config{})
+	require.NoError(t, err)
+	p.Reserve(routes...)
+
+	require.ErrorIs(t, p.Check("Static"), aliaspolicy.ErrRejected)
+	require.NoError(t, p.Check("statics"))
+}
+
+func TestNew(t *testing.T) {
+	_, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 5, MaxLength: 4})
+	require.Error(t, err)
+
+	_, err = aliaspolicy.New(aliaspolicy.Config{ProfanityFile: filepath.Join(t.TempDir(), "missing.txt")})
+	require.Error(t, err)
+}
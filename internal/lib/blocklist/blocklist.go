@@ -0,0 +1,414 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/storage"
+
+	"url-shortener/internal/lib/logger/sl"
+
+	"golang.org/x/net/idna"
+)
+
+// Feed formats.
+const (
+	FormatHosts   = "hosts"   // hosts file lines like "0.0.0.0 evil.com"
+	FormatDomains = "domains" // a domain per line
+	FormatURLhaus = "urlhaus" // CSV export of URLhaus, the hosts of the url column are blocked
+)
+
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Feed is a local file listing domains to block.
+type Feed struct {
+	Name   string // shown in warnings and reports, like "phishing"
+	Path   string
+	Format string
+}
+
+// Match tells why a URL is blocked.
+type Match struct {
+	Domain string `json:"domain"` // the listed domain, the host itself or one of its parents
+	Feed   string `json:"feed"`
+}
+
+// List is an in-memory set of the domains of all feeds. It is safe for concurrent use.
+// Without feeds it blocks nothing.
+type List struct {
+	feeds   []Feed
+	domains atomic.Pointer[map[string]string] // domain to feed name
+
+	reloadMu sync.Mutex
+	loaded   map[string]fileState // of the files the current set was built from
+
+	hitsMu sync.Mutex
+	hits   map[string]*Hit // by alias
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// maxHits bounds the report, the least recent hits are dropped first.
+const maxHits = 1000
+
+// New loads the feeds.
+func New(feeds []Feed) (*List, error) {
+	const op = "lib.blocklist.New"
+
+	for _, f := range feeds {
+		if f.Format != FormatHosts && f.Format != FormatDomains && f.Format != FormatURLhaus {
+			return nil, fmt.Errorf("%s: %s: %w: %q", op, f.Path, ErrUnknownFormat, f.Format)
+		}
+	}
+
+	l := &List{feeds: feeds, hits: make(map[string]*Hit)}
+	if _, err := l.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return l, nil
+}
+
+// Reload rebuilds the set if any feed file changed since the last load.
+// The previous set stays in use when a feed cannot be read.
+func (l *List) Reload() (bool, error) {
+	const op = "lib.blocklist.Reload"
+
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+
+	states := make(map[string]fileState, len(l.feeds))
+	changed := l.domains.Load() == nil
+	for _, f := range l.feeds {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		states[f.Path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		if states[f.Path] != l.loaded[f.Path] {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	domains := make(map[string]string)
+	for _, f := range l.feeds {
+		if err := loadFeed(f, domains); err != nil {
+			return false, fmt.Errorf("%s: %s: %w", op, f.Path, err)
+		}
+	}
+
+	l.domains.Store(&domains)
+	l.loaded = states
+
+	return true, nil
+}
+
+func loadFeed(f Feed, domains map[string]string) error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hosts, err := Parse(file, f.Format)
+	if err != nil {
+		return err
+	}
+	for _, h := range hosts {
+		// the first feed listing a domain names it
+		if _, ok := domains[h]; !ok {
+			domains[h] = f.Name
+		}
+	}
+
+	return nil
+}
+
+// Watch reloads the list every interval until ctx is canceled.
+// A non-positive interval disables reloading, Watch returns right away then.
+func (l *List) Watch(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := l.Reload()
+		if err != nil {
+			log.Error("failed to reload blocklist", sl.Err(err))
+
+			continue
+		}
+		if changed {
+			log.Info("blocklist reloaded", slog.Int("domains", l.Len()))
+		}
+	}
+}
+
+// Len returns the number of blocked domains.
+func (l *List) Len() int {
+	return len(*l.domains.Load())
+}
+
+// Lookup reports whether the host of rawURL is blocked.
+func (l *List) Lookup(rawURL string) (Match, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Match{}, false
+	}
+
+	host, err := hosts.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		return Match{}, false
+	}
+
+	return l.LookupHost(host)
+}
+
+// LookupHost reports whether host in ASCII form or one of its parent domains is blocked.
+func (l *List) LookupHost(host string) (Match, bool) {
+	domains := *l.domains.Load()
+	if len(domains) == 0 {
+		return Match{}, false
+	}
+
+	host = strings.ToLower(host)
+	if _, err := netip.ParseAddr(host); err == nil {
+		feed, ok := domains[host]
+
+		return Match{Domain: host, Feed: feed}, ok
+	}
+
+	for d := host; d != ""; {
+		if feed, ok := domains[d]; ok {
+			return Match{Domain: d, Feed: feed}, true
+		}
+
+		_, d, _ = strings.Cut(d, ".")
+	}
+
+	return Match{}, false
+}
+
+// LookupLink reports whether any destination of link is blocked.
+func (l *List) LookupLink(link storage.Link) (Match, bool) {
+	if m, ok := l.Lookup(link.URL); ok {
+		return m, true
+	}
+	for _, r := range link.Rules {
+		if m, ok := l.Lookup(r.URL); ok {
+			return m, true
+		}
+	}
+	for _, v := range link.Variants {
+		if m, ok := l.Lookup(v.URL); ok {
+			return m, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// hosts normalizes domains to their ASCII form. Feeds list hosts with underscores too.
+var hosts = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// Parse reads the hosts listed in r in the given format, normalized to their ASCII form.
+// Lines that are not valid entries are skipped, feeds are full of them.
+func Parse(r io.Reader, format string) ([]string, error) {
+	switch format {
+	case FormatHosts:
+		return parseLines(r, hostsEntry)
+	case FormatDomains:
+		return parseLines(r, domainsEntry)
+	case FormatURLhaus:
+		return parseURLhaus(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// localNames appear in hosts files for the machine itself.
+var localNames = map[string]bool{
+	"localhost": true, "localhost.localdomain": true, "local": true, "broadcasthost": true,
+	"ip6-localhost": true, "ip6-loopback": true, "0.0.0.0": true,
+}
+
+func hostsEntry(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil
+	}
+	if _, err := netip.ParseAddr(fields[0]); err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, f := range fields[1:] {
+		if !localNames[strings.ToLower(f)] {
+			names = append(names, f)
+		}
+	}
+
+	return names
+}
+
+func domainsEntry(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) != 1 {
+		return nil
+	}
+
+	return []string{strings.TrimPrefix(fields[0], "*.")}
+}
+
+func parseLines(r io.Reader, entry func(line string) []string) ([]string, error) {
+	var result []string
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		for _, name := range entry(line) {
+			if host, ok := normalize(name); ok {
+				result = append(result, host)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func parseURLhaus(r io.Reader) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	var result []string
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// id, dateadded, url, ... in the full export, a bare URL in the text one
+		raw := rec[0]
+		if len(rec) >= 3 {
+			raw = rec[2]
+		}
+
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if host, ok := normalize(u.Hostname()); ok {
+			result = append(result, host)
+		}
+	}
+
+	return result, nil
+}
+
+func normalize(name string) (string, bool) {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "", false
+	}
+	if addr, err := netip.ParseAddr(name); err == nil {
+		return addr.String(), true
+	}
+
+	host, err := hosts.ToASCII(name)
+	if err != nil || !strings.Contains(host, ".") {
+		return "", false
+	}
+
+	return host, true
+}
+
+// Hit is a link whose destination was blocked when it was followed.
+type Hit struct {
+	Alias string `json:"alias"`
+	URL   string `json:"url"`
+	Match
+	Count   int64     `json:"count"`
+	LastHit time.Time `json:"last_hit"`
+}
+
+// Record adds a blocked visit of the link with alias to the report.
+func (l *List) Record(alias, url string, m Match) {
+	l.hitsMu.Lock()
+	defer l.hitsMu.Unlock()
+
+	hit, ok := l.hits[alias]
+	if !ok {
+		if len(l.hits) >= maxHits {
+			l.dropOldest()
+		}
+		hit = &Hit{Alias: alias}
+		l.hits[alias] = hit
+	}
+
+	hit.URL = url
+	hit.Match = m
+	hit.Count++
+	hit.LastHit = time.Now().UTC()
+}
+
+func (l *List) dropOldest() {
+	var oldest *Hit
+	for _, h := range l.hits {
+		if oldest == nil || h.LastHit.Before(oldest.LastHit) {
+			oldest = h
+		}
+	}
+	if oldest != nil {
+		delete(l.hits, oldest.Alias)
+	}
+}
+
+// Hits returns the blocked visits recorded since the start, the most recent first.
+func (l *List) Hits() []Hit {
+	l.hitsMu.Lock()
+	defer l.hitsMu.Unlock()
+
+	hits := make([]Hit, 0, len(l.hits))
+	for _, h := range l.hits {
+		hits = append(hits, *h)
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].LastHit.After(hits[j].LastHit) })
+
+	return hits
+}
@@ -0,0 +1,188 @@
+package blocklist_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		input  string
+		want   []string
+	}{
+		{
+			name:   "Hosts",
+			format: blocklist.FormatHosts,
+			input: `# comment
+127.0.0.1 localhost
+0.0.0.0 evil.com www.evil.com # inline comment
+::1 ip6-localhost
+0.0.0.0 EVIL.net.
+not-an-ip phish.com
+`,
+			want: []string{"evil.com", "www.evil.com", "evil.net"},
+		},
+		{
+			name:   "Domains",
+			format: blocklist.FormatDomains,
+			input: `# phishing
+evil.com
+*.phish.org
+bad_host.example.com
+münchen-bank.de
+two words
+localhost
+`,
+			want: []string{"evil.com", "phish.org", "bad_host.example.com", "xn--mnchen-bank-thb.de"},
+		},
+		{
+			name:   "URLhaus",
+			format: blocklist.FormatURLhaus,
+			input: `################################################################
+# abuse.ch URLhaus Database Dump (CSV)                          #
+# id,dateadded,url,url_status,last_online,threat,tags,urlhaus_link,reporter
+"1","2024-01-01 00:00:00","http://malware.example/payload.exe","online","2024-01-01 00:00:00","malware_download","exe","https://urlhaus.abuse.ch/url/1/","someone"
+"2","2024-01-01 00:00:00","http://192.0.2.7:8080/bins/x","online","","malware_download","elf","https://urlhaus.abuse.ch/url/2/","someone"
+"3","2024-01-01 00:00:00","not a url","online","","","","",""
+`,
+			want: []string{"malware.example", "192.0.2.7"},
+		},
+		{
+			name:   "URLhaus text",
+			format: blocklist.FormatURLhaus,
+			input:  "# comment\nhttps://drop.example/x.zip\n",
+			want:   []string{"drop.example"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := blocklist.Parse(strings.NewReader(tc.input), tc.format)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := blocklist.Parse(strings.NewReader(""), "adblock")
+	require.ErrorIs(t, err, blocklist.ErrUnknownFormat)
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	phishing := filepath.Join(dir, "phishing.txt")
+	malware := filepath.Join(dir, "malware.hosts")
+	require.NoError(t, os.WriteFile(phishing, []byte("evil.com\n"), 0o644))
+	require.NoError(t, os.WriteFile(malware, []byte("0.0.0.0 malware.net\n0.0.0.0 evil.com\n"), 0o644))
+
+	l, err := blocklist.New([]blocklist.Feed{
+		{Name: "phishing", Path: phishing, Format: blocklist.FormatDomains},
+		{Name: "malware", Path: malware, Format: blocklist.FormatHosts},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, l.Len())
+
+	m, ok := l.Lookup("https://login.EVIL.com./account")
+	require.True(t, ok)
+	assert.Equal(t, blocklist.Match{Domain: "evil.com", Feed: "phishing"}, m)
+
+	m, ok = l.Lookup("http://malware.net/x")
+	require.True(t, ok)
+	assert.Equal(t, "malware", m.Feed)
+
+	_, ok = l.Lookup("https://notevil.com/")
+	assert.False(t, ok)
+	_, ok = l.Lookup("mailto:me@evil.com")
+	assert.False(t, ok)
+
+	_, ok = l.LookupLink(storage.Link{
+		URL:      "https://example.com",
+		Variants: []storage.Variant{{URL: "https://example.com/a"}, {URL: "https://evil.com/b"}},
+	})
+	assert.True(t, ok)
+
+	// unchanged files are not read again
+	changed, err := l.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, os.WriteFile(phishing, []byte("phish.org\n"), 0o644))
+	require.NoError(t, os.Chtimes(phishing, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	changed, err = l.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	m, ok = l.Lookup("https://evil.com/")
+	require.True(t, ok)
+	assert.Equal(t, "malware", m.Feed)
+	_, ok = l.Lookup("https://phish.org/")
+	assert.True(t, ok)
+
+	// a broken feed keeps the previous set
+	require.NoError(t, os.Remove(malware))
+	_, err = l.Reload()
+	require.Error(t, err)
+	_, ok = l.Lookup("https://malware.net/")
+	assert.True(t, ok)
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	_, err := blocklist.New([]blocklist.Feed{{Path: "feed.txt", Format: "adblock"}})
+	require.ErrorIs(t, err, blocklist.ErrUnknownFormat)
+}
+
+func TestList_WatchDisabled(t *testing.T) {
+	list, err := blocklist.New(nil)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		list.Watch(context.Background(), slogdiscard.NewDiscardLogger(), 0)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch with a zero interval did not return")
+	}
+}
+
+func TestList_Hits(t *testing.T) {
+	l, err := blocklist.New(nil)
+	require.NoError(t, err)
+
+	_, ok := l.Lookup("https://evil.com/")
+	require.False(t, ok)
+
+	m := blocklist.Match{Domain: "evil.com", Feed: "phishing"}
+	l.Record("abcd", "https://evil.com/a", m)
+	time.Sleep(time.Millisecond)
+	l.Record("efgh", "https://evil.com/b", m)
+	l.Record("efgh", "https://evil.com/b", m)
+
+	hits := l.Hits()
+	require.Len(t, hits, 2)
+	assert.Equal(t, "efgh", hits[0].Alias)
+	assert.Equal(t, int64(2), hits[0].Count)
+	assert.Equal(t, m, hits[0].Match)
+	assert.Equal(t, "abcd", hits[1].Alias)
+	assert.Equal(t, int64(1), hits[1].Count)
+}
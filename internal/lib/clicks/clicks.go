@@ -0,0 +1,139 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+	"url-shortener/internal/storage"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+var ErrQueueFull = errors.New("click queue is full")
+
+// Store takes clicks from links with a click limit and adds counted redirects to links.
+type Store interface {
+	ConsumeClick(ctx context.Context, alias string) (int64, error)
+	CountClicks(ctx context.Context, clicks []storage.Clicks) error
+}
+
+// Options configures a Batcher.
+type Options struct {
+	FlushInterval time.Duration // how long counted clicks wait before they are written
+	QueueSize     int           // clicks waiting to be collected, more are dropped
+	BatchSize     int           // links and variants collected before they are written early
+}
+
+type key struct {
+	alias     string
+	variantID int64
+}
+
+// Batcher counts redirects in memory and writes them to the store in batches, so that
+// redirects do not wait for a write transaction. Clicks of links with a limit are still
+// consumed by the store directly, the limit must hold across instances.
+type Batcher struct {
+	log   *slog.Logger
+	store Store
+	queue chan key
+	opts  Options
+}
+
+func New(log *slog.Logger, store Store, opts Options) *Batcher {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	opts.BatchSize = max(opts.BatchSize, 1)
+
+	return &Batcher{
+		log:   log.With(slog.String("op", "lib.clicks.Batcher")),
+		store: store,
+		queue: make(chan key, max(opts.QueueSize, 1)),
+		opts:  opts,
+	}
+}
+
+// ConsumeClick takes a click from a link with a click limit in the store.
+func (b *Batcher) ConsumeClick(ctx context.Context, alias string) (int64, error) {
+	return b.store.ConsumeClick(ctx, alias)
+}
+
+// CountClick queues a redirect of the link with the given alias and, unless variantID is zero,
+// of its variant with that ID. It never blocks: the click is dropped when the queue is full.
+func (b *Batcher) CountClick(_ context.Context, alias string, variantID int64) error {
+	select {
+	case b.queue <- key{alias: alias, variantID: variantID}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run collects queued clicks and writes them every FlushInterval until ctx is canceled.
+// Clicks still queued then are written before it returns.
+func (b *Batcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[key]int64)
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.drain(pending)
+			b.flush(context.WithoutCancel(ctx), pending)
+
+			return
+		case k := <-b.queue:
+			pending[k]++
+			if len(pending) >= b.opts.BatchSize {
+				b.flush(ctx, pending)
+			}
+		case <-ticker.C:
+			b.flush(ctx, pending)
+		}
+	}
+}
+
+func (b *Batcher) drain(pending map[key]int64) {
+	for {
+		select {
+		case k := <-b.queue:
+			pending[k]++
+		default:
+			return
+		}
+	}
+}
+
+// flush writes pending clicks and forgets them, also when the write fails:
+// clicks must not pile up in memory while the store is unavailable.
+func (b *Batcher) flush(ctx context.Context, pending map[key]int64) {
+	if len(pending) == 0 {
+		return
+	}
+
+	batch := make([]storage.Clicks, 0, len(pending))
+	for k, n := range pending {
+		batch = append(batch, storage.Clicks{Alias: k.alias, VariantID: k.variantID, Count: n})
+	}
+	clear(pending)
+
+	// a stable order keeps concurrent writers from locking rows in different orders
+	sort.Slice(batch, func(i, j int) bool {
+		if batch[i].Alias != batch[j].Alias {
+			return batch[i].Alias < batch[j].Alias
+		}
+		return batch[i].VariantID < batch[j].VariantID
+	})
+
+	if err := b.store.CountClicks(ctx, batch); err != nil {
+		b.log.Error("failed to count clicks", slog.Int("links", len(batch)), sl.Err(err))
+
+		return
+	}
+
+	b.log.Debug("clicks counted", slog.Int("links", len(batch)))
+}
@@ -0,0 +1,132 @@
+package clicks_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/clicks"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]storage.Clicks
+	err     error
+}
+
+func (s *fakeStore) ConsumeClick(_ context.Context, alias string) (int64, error) {
+	if alias == "none" {
+		return 0, storage.ErrExhausted
+	}
+
+	return 1, nil
+}
+
+func (s *fakeStore) CountClicks(_ context.Context, batch []storage.Clicks) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batches = append(s.batches, batch)
+
+	return s.err
+}
+
+func (s *fakeStore) written() [][]storage.Clicks {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.batches
+}
+
+func run(t *testing.T, b *clicks.Batcher) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("batcher did not stop")
+		}
+	}
+}
+
+func TestBatcher_Shutdown(t *testing.T) {
+	store := &fakeStore{}
+	b := clicks.New(slogdiscard.NewDiscardLogger(), store, clicks.Options{FlushInterval: time.Hour, QueueSize: 100, BatchSize: 100})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.CountClick(context.Background(), "abcd", 0))
+	}
+	require.NoError(t, b.CountClick(context.Background(), "abcd", 2))
+	require.NoError(t, b.CountClick(context.Background(), "docs", 0))
+
+	stop := run(t, b)
+	stop()
+
+	// clicks still queued are written on shutdown, grouped by link and variant
+	assert.Equal(t, [][]storage.Clicks{{
+		{Alias: "abcd", Count: 3},
+		{Alias: "abcd", VariantID: 2, Count: 1},
+		{Alias: "docs", Count: 1},
+	}}, store.written())
+}
+
+func TestBatcher_Interval(t *testing.T) {
+	store := &fakeStore{}
+	b := clicks.New(slogdiscard.NewDiscardLogger(), store, clicks.Options{FlushInterval: 10 * time.Millisecond, QueueSize: 100, BatchSize: 100})
+
+	stop := run(t, b)
+	defer stop()
+
+	require.NoError(t, b.CountClick(context.Background(), "abcd", 0))
+	require.NoError(t, b.CountClick(context.Background(), "abcd", 0))
+
+	require.Eventually(t, func() bool {
+		return len(store.written()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []storage.Clicks{{Alias: "abcd", Count: 2}}, store.written()[0])
+}
+
+func TestBatcher_BatchSize(t *testing.T) {
+	store := &fakeStore{}
+	b := clicks.New(slogdiscard.NewDiscardLogger(), store, clicks.Options{FlushInterval: time.Hour, QueueSize: 100, BatchSize: 2})
+
+	stop := run(t, b)
+	defer stop()
+
+	require.NoError(t, b.CountClick(context.Background(), "abcd", 0))
+	require.NoError(t, b.CountClick(context.Background(), "docs", 0))
+
+	require.Eventually(t, func() bool {
+		return len(store.written()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestBatcher_QueueFull(t *testing.T) {
+	store := &fakeStore{err: errors.New("storage is down")}
+	b := clicks.New(slogdiscard.NewDiscardLogger(), store, clicks.Options{QueueSize: 1})
+
+	require.NoError(t, b.CountClick(context.Background(), "abcd", 0))
+	require.ErrorIs(t, b.CountClick(context.Background(), "abcd", 0), clicks.ErrQueueFull)
+
+	// failed writes are not retried
+	stop := run(t, b)
+	stop()
+	assert.Len(t, store.written(), 1)
+
+	_, err := b.ConsumeClick(context.Background(), "none")
+	require.ErrorIs(t, err, storage.ErrExhausted)
+}
@@ -0,0 +1,91 @@
+package confusable
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts are told apart by the checks, letters of any other script are "other".
+var scripts = map[string]*unicode.RangeTable{
+	"Latin":    unicode.Latin,
+	"Greek":    unicode.Greek,
+	"Cyrillic": unicode.Cyrillic,
+	"Armenian": unicode.Armenian,
+	"Han":      unicode.Han,
+	"Hiragana": unicode.Hiragana,
+	"Katakana": unicode.Katakana,
+	"Hangul":   unicode.Hangul,
+}
+
+// mixes are the combinations of scripts written together, like Japanese or Korean mixed with Latin.
+var mixes = []map[string]bool{
+	{"Latin": true, "Han": true, "Hiragana": true, "Katakana": true},
+	{"Latin": true, "Han": true, "Hangul": true},
+}
+
+// cyrillicLookalikes are the Cyrillic letters that look like Latin ones, a word made of them only
+// passes for a Latin one: "аррӏе" and "apple".
+const cyrillicLookalikes = "аеорсухіјѕԁһӏԛԝвкмнтьгпѵѡъ"
+
+// Scripts returns the scripts of the letters of s.
+func Scripts(s string) map[string]bool {
+	found := make(map[string]bool)
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		name := "other"
+		for n, table := range scripts {
+			if unicode.Is(table, r) {
+				name = n
+
+				break
+			}
+		}
+		found[name] = true
+	}
+
+	return found
+}
+
+// Mixed reports whether s mixes letters of scripts that are not written together, like "pаypal"
+// with a Cyrillic "а".
+func Mixed(s string) bool {
+	found := Scripts(s)
+	if len(found) < 2 {
+		return false
+	}
+
+	for _, mix := range mixes {
+		ok := true
+		for name := range found {
+			if !mix[name] {
+				ok = false
+
+				break
+			}
+		}
+		if ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LatinLookalike reports whether s is written in Cyrillic letters looking like Latin ones only.
+func LatinLookalike(s string) bool {
+	found := Scripts(s)
+	if len(found) != 1 || !found["Cyrillic"] {
+		return false
+	}
+
+	for _, r := range s {
+		if unicode.IsLetter(r) && !strings.ContainsRune(cyrillicLookalikes, r) {
+			return false
+		}
+	}
+
+	return true
+}
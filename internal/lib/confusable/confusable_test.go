@@ -0,0 +1,27 @@
+package confusable_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"url-shortener/internal/lib/confusable"
+)
+
+func TestMixed(t *testing.T) {
+	assert.False(t, confusable.Mixed("paypal"))
+	assert.False(t, confusable.Mixed("привет"))
+	assert.False(t, confusable.Mixed("東京tokyoとうきょう"))
+	assert.False(t, confusable.Mixed("서울seoul"))
+	assert.False(t, confusable.Mixed("a1_-"))
+	assert.True(t, confusable.Mixed("pаypal"))
+	assert.True(t, confusable.Mixed("αβcd"))
+	assert.True(t, confusable.Mixed("東京서울とうきょう"))
+}
+
+func TestLatinLookalike(t *testing.T) {
+	assert.True(t, confusable.LatinLookalike("аррӏе"))
+	assert.False(t, confusable.LatinLookalike("apple"))
+	assert.False(t, confusable.LatinLookalike("привет"))
+	assert.False(t, confusable.LatinLookalike("123"))
+}
@@ -0,0 +1,140 @@
+package forward
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Query modes define how the query of the incoming request is merged into the target.
+const (
+	QueryDrop    = ""        // the incoming query is dropped
+	QueryTarget  = "target"  // merged, the target wins for keys present in both
+	QueryRequest = "request" // merged, the incoming request wins for keys present in both
+	QueryAppend  = "append"  // merged, the values of both are kept
+)
+
+// ErrInvalidSuffix is returned for path suffixes trying to leave the target path.
+var ErrInvalidSuffix = errors.New("invalid path suffix")
+
+// Target builds the URL to redirect to. suffix is an escaped path appended to the
+// target path, query is the raw query of the incoming request. The parts of the target
+// that are not changed keep their original encoding and order.
+func Target(target, suffix, query, mode string) (string, error) {
+	const op = "lib.forward.Target"
+
+	if strings.Trim(suffix, "/") == "" && (query == "" || mode == QueryDrop) {
+		return target, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if strings.Trim(suffix, "/") != "" {
+		if err := appendPath(u, suffix); err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if query != "" && mode != QueryDrop {
+		u.RawQuery = mergeQuery(u.RawQuery, query, mode)
+	}
+
+	return u.String(), nil
+}
+
+func appendPath(u *url.URL, suffix string) error {
+	suffix = strings.TrimPrefix(suffix, "/")
+
+	for _, segment := range strings.Split(suffix, "/") {
+		s, err := url.PathUnescape(segment)
+		if err != nil || s == "." || s == ".." {
+			return ErrInvalidSuffix
+		}
+	}
+
+	escaped := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + suffix
+
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return ErrInvalidSuffix
+	}
+	u.Path = path
+	u.RawPath = escaped
+
+	return nil
+}
+
+// mergeQuery merges the incoming query into the target one according to mode.
+func mergeQuery(target, incoming, mode string) string {
+	// a malformed incoming query is passed on as far as it could be parsed
+	in, _ := url.ParseQuery(incoming)
+	if len(in) == 0 {
+		return target
+	}
+
+	switch mode {
+	case QueryTarget:
+		for _, key := range queryKeys(target) {
+			delete(in, key)
+		}
+	case QueryRequest:
+		target = dropKeys(target, in)
+	}
+
+	return joinQuery(target, in.Encode())
+}
+
+// queryKeys returns the unescaped keys of a raw query.
+func queryKeys(raw string) []string {
+	var keys []string
+	for _, pair := range strings.Split(raw, "&") {
+		if key, ok := queryKey(pair); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// dropKeys removes the pairs with keys present in drop from a raw query.
+func dropKeys(raw string, drop url.Values) string {
+	var kept []string
+	for _, pair := range strings.Split(raw, "&") {
+		if key, ok := queryKey(pair); ok {
+			if _, found := drop[key]; found {
+				continue
+			}
+		}
+		if pair != "" {
+			kept = append(kept, pair)
+		}
+	}
+
+	return strings.Join(kept, "&")
+}
+
+func queryKey(pair string) (string, bool) {
+	if pair == "" {
+		return "", false
+	}
+
+	key, _, _ := strings.Cut(pair, "=")
+	key, err := url.QueryUnescape(key)
+
+	return key, err == nil
+}
+
+func joinQuery(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	default:
+		return a + "&" + b
+	}
+}
@@ -0,0 +1,146 @@
+package forward_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/forward"
+)
+
+func TestTarget(t *testing.T) {
+	cases := []struct {
+		name    string
+		target  string
+		suffix  string
+		query   string
+		mode    string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "Verbatim",
+			target: "https://example.com/a?b=1#c",
+			query:  "utm_source=x",
+			want:   "https://example.com/a?b=1#c",
+		},
+		{
+			name:   "Target without query",
+			target: "https://example.com/a",
+			query:  "utm_source=x&utm_medium=y",
+			mode:   forward.QueryTarget,
+			want:   "https://example.com/a?utm_medium=y&utm_source=x",
+		},
+		{
+			name:   "Fragment is kept",
+			target: "https://example.com/a#section",
+			query:  "utm_source=x",
+			mode:   forward.QueryAppend,
+			want:   "https://example.com/a?utm_source=x#section",
+		},
+		{
+			name:   "Target wins",
+			target: "https://example.com/?b=2&a=1",
+			query:  "a=9&c=3",
+			mode:   forward.QueryTarget,
+			want:   "https://example.com/?b=2&a=1&c=3",
+		},
+		{
+			name:   "Request wins",
+			target: "https://example.com/?b=2&a=1&a=0",
+			query:  "a=9&a=8&c=3",
+			mode:   forward.QueryRequest,
+			want:   "https://example.com/?b=2&a=9&a=8&c=3",
+		},
+		{
+			name:   "Append keeps duplicates",
+			target: "https://example.com/?a=1",
+			query:  "a=2",
+			mode:   forward.QueryAppend,
+			want:   "https://example.com/?a=1&a=2",
+		},
+		{
+			name:   "Escaped keys match",
+			target: "https://example.com/?a%20b=1",
+			query:  "a+b=2",
+			mode:   forward.QueryRequest,
+			want:   "https://example.com/?a+b=2",
+		},
+		{
+			name:   "Encoding is normalized for incoming values only",
+			target: "https://example.com/?q=%7Bx%7D",
+			query:  "r=a b&s=%26",
+			mode:   forward.QueryAppend,
+			want:   "https://example.com/?q=%7Bx%7D&r=a+b&s=%26",
+		},
+		{
+			name:   "Malformed incoming query",
+			target: "https://example.com/",
+			query:  "a=%zz&b=1",
+			mode:   forward.QueryAppend,
+			want:   "https://example.com/?b=1",
+		},
+		{
+			name:   "Empty incoming values",
+			target: "https://example.com/",
+			query:  "flag&&",
+			mode:   forward.QueryAppend,
+			want:   "https://example.com/?flag=",
+		},
+		{
+			name:   "Path suffix",
+			target: "https://example.com/docs/",
+			suffix: "/guide/intro",
+			want:   "https://example.com/docs/guide/intro",
+		},
+		{
+			name:   "Path suffix keeps escaping",
+			target: "https://example.com/docs?v=1#top",
+			suffix: "/a%2Fb/c%20d",
+			want:   "https://example.com/docs/a%2Fb/c%20d?v=1#top",
+		},
+		{
+			name:   "Path suffix and query",
+			target: "https://example.com",
+			suffix: "/x",
+			query:  "a=1",
+			mode:   forward.QueryTarget,
+			want:   "https://example.com/x?a=1",
+		},
+		{
+			name:   "Trailing slash only",
+			target: "https://example.com/docs",
+			suffix: "/",
+			want:   "https://example.com/docs",
+		},
+		{
+			name:    "Dot segments",
+			target:  "https://example.com/docs/",
+			suffix:  "/a/../../admin",
+			wantErr: forward.ErrInvalidSuffix,
+		},
+		{
+			name:    "Escaped dot segments",
+			target:  "https://example.com/docs/",
+			suffix:  "/%2e%2e/admin",
+			wantErr: forward.ErrInvalidSuffix,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := forward.Target(tc.target, tc.suffix, tc.query, tc.mode)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DB maps IP addresses to countries using ranges loaded from a CSV file
+// in the "start_ip,end_ip,country_code" format of the free db-ip and IP2Location lite databases.
+type DB struct {
+	ranges []ipRange // sorted by start, not overlapping
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// Open loads the database from the CSV file at path.
+func Open(path string) (*DB, error) {
+	const op = "lib.geoip.Open"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	db, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, path, err)
+	}
+
+	return db, nil
+}
+
+// Load reads the database in CSV format from r.
+func Load(r io.Reader) (*DB, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	var db DB
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 3 {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: expected start, end and country", line)
+		}
+
+		start, err := netip.ParseAddr(strings.TrimSpace(rec[0]))
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(rec[1]))
+		if err != nil {
+			line, _ := cr.FieldPos(1)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		db.ranges = append(db.ranges, ipRange{
+			start:   start.Unmap(),
+			end:     end.Unmap(),
+			country: strings.ToUpper(strings.TrimSpace(rec[2])),
+		})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start.Less(db.ranges[j].start) })
+
+	return &db, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country addr belongs to,
+// or an empty string when it is not in the database.
+func (db *DB) Country(addr netip.Addr) string {
+	addr = addr.Unmap()
+
+	// the last range starting at or before addr
+	i := sort.Search(len(db.ranges), func(i int) bool { return addr.Less(db.ranges[i].start) }) - 1
+	if i < 0 {
+		return ""
+	}
+
+	r := db.ranges[i]
+	// IPv4 and IPv6 addresses never compare as equal, a range only holds addresses of its own family
+	if r.start.BitLen() != addr.BitLen() || r.end.Less(addr) {
+		return ""
+	}
+	// "ZZ" and "-" mark reserved ranges in the free databases
+	if r.country == "ZZ" || r.country == "-" {
+		return ""
+	}
+
+	return r.country
+}
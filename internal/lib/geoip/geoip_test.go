@@ -0,0 +1,48 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/geoip"
+)
+
+const database = `# start,end,country
+"1.0.0.0","1.0.0.255","AU"
+5.255.255.0,5.255.255.255,ru
+10.0.0.0,10.255.255.255,ZZ
+2a00:1450::,2a00:1450:ffff:ffff:ffff:ffff:ffff:ffff,IE
+`
+
+func TestDB_Country(t *testing.T) {
+	db, err := geoip.Load(strings.NewReader(database))
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"1.0.0.0":              "AU",
+		"1.0.0.255":            "AU",
+		"1.0.1.0":              "",
+		"0.255.255.255":        "",
+		"5.255.255.7":          "RU",
+		"::ffff:5.255.255.7":   "RU",
+		"10.1.2.3":             "",
+		"2a00:1450:4001::200e": "IE",
+		"2a00:1451::1":         "",
+	}
+
+	for ip, want := range cases {
+		assert.Equal(t, want, db.Country(netip.MustParseAddr(ip)), ip)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := geoip.Load(strings.NewReader("1.0.0.0,AU\n"))
+	require.Error(t, err)
+
+	_, err = geoip.Load(strings.NewReader("1.0.0.0,not-an-ip,AU\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,311 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"url-shortener/internal/storage"
+
+	"url-shortener/internal/lib/logger/sl"
+	"url-shortener/internal/lib/pagemeta"
+)
+
+var (
+	ErrBadStatus      = errors.New("unexpected status")
+	ErrUnsupportedURL = errors.New("only http and https URLs are checked")
+)
+
+// Store lists the links to check and keeps the results.
+type Store interface {
+	ListLinks(ctx context.Context, filter storage.LinkFilter) ([]storage.Link, error)
+	SaveHealth(ctx context.Context, alias string, health storage.LinkHealth) error
+}
+
+// Options configures a Checker.
+type Options struct {
+	Interval      time.Duration // between the starts of two rounds, only the first round runs when not positive
+	Concurrency   int           // checks running at once
+	HostInterval  time.Duration // between two requests to the same host
+	Timeout       time.Duration // of a single check including redirects
+	MaxRedirects  int
+	FallbackAfter int // consecutive failures before visitors are sent to the fallback URL, never when 0
+	UserAgent     string
+	AllowPrivate  bool // check destinations on loopback and private networks, only for tests
+}
+
+// Checker periodically requests the destinations of all links and records whether they still work.
+// Like the page fetcher, it refuses to connect to non-public addresses.
+type Checker struct {
+	log    *slog.Logger
+	store  Store
+	client *http.Client
+	opts   Options
+}
+
+// pageSize is the number of links a round reads from the store at once.
+const pageSize = 100
+
+func New(log *slog.Logger, store Store, opts Options) *Checker {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = pagemeta.Guard
+	}
+
+	maxRedirects := opts.MaxRedirects
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   opts.Timeout,
+			ResponseHeaderTimeout: opts.Timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+
+	opts.Concurrency = max(opts.Concurrency, 1)
+
+	return &Checker{
+		log:    log.With(slog.String("op", "lib.linkcheck.Checker")),
+		store:  store,
+		client: client,
+		opts:   opts,
+	}
+}
+
+// Run checks all links right away and then every Interval until ctx is canceled.
+func (c *Checker) Run(ctx context.Context) {
+	if c.opts.Interval <= 0 {
+		if err := c.Round(ctx); err != nil && ctx.Err() == nil {
+			c.log.Error("link check round failed", sl.Err(err))
+		}
+		<-ctx.Done()
+
+		return
+	}
+
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Round(ctx); err != nil && ctx.Err() == nil {
+			c.log.Error("link check round failed", sl.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Round checks the destinations of all links once. Expired links and links
+// to anything but web pages are skipped.
+func (c *Checker) Round(ctx context.Context) error {
+	const op = "lib.linkcheck.Round"
+
+	start := time.Now()
+	limiter := newHostLimiter(c.opts.HostInterval)
+	links := make(chan storage.Link)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for link := range links {
+				c.checkLink(ctx, limiter, link)
+			}
+		}()
+	}
+
+	err := c.listLinks(ctx, start, links)
+	close(links)
+	wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	c.log.Info("link check round finished", slog.Duration("duration", time.Since(start)))
+
+	return nil
+}
+
+func (c *Checker) listLinks(ctx context.Context, now time.Time, links chan<- storage.Link) error {
+	for offset := 0; ; offset += pageSize {
+		page, err := c.store.ListLinks(ctx, storage.LinkFilter{Limit: pageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+
+		for _, link := range page {
+			if link.Expired(now) || !webURL(link.URL) {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case links <- link:
+			}
+		}
+
+		if len(page) < pageSize {
+			return nil
+		}
+	}
+}
+
+func (c *Checker) checkLink(ctx context.Context, limiter *hostLimiter, link storage.Link) {
+	log := c.log.With(slog.String("alias", link.Alias))
+
+	u, _ := url.Parse(link.URL)
+	if err := limiter.wait(ctx, u.Hostname()); err != nil {
+		return
+	}
+
+	status, latency, err := c.Check(ctx, link.URL)
+	if ctx.Err() != nil {
+		// shutting down, the destination is not to blame
+		return
+	}
+
+	health := storage.LinkHealth{
+		Status:    status,
+		LatencyMS: latency.Milliseconds(),
+		CheckedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		health.Error = err.Error()
+		health.Failures = link.Health.Failures + 1
+	}
+	health.Fallback = link.FallbackURL != "" && c.opts.FallbackAfter > 0 && health.Failures >= c.opts.FallbackAfter
+
+	switch {
+	case health.Fallback && !link.Health.Fallback:
+		log.Warn("destination is broken, switching to the fallback url", slog.Int("failures", health.Failures), sl.Err(err))
+	case !health.Fallback && link.Health.Fallback:
+		log.Info("destination works again, leaving the fallback url")
+	case err != nil:
+		log.Info("destination check failed", slog.Int("failures", health.Failures), sl.Err(err))
+	}
+
+	err = c.store.SaveHealth(ctx, link.Alias, health)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		// deleted in the meantime
+		return
+	}
+	if err != nil {
+		log.Error("failed to save link health", sl.Err(err))
+	}
+}
+
+// Check requests target with HEAD, falling back to GET for servers that do not answer HEAD properly.
+// A status of 400 and above is a failure, except 429: the server is there, just busy.
+func (c *Checker) Check(ctx context.Context, target string) (int, time.Duration, error) {
+	const op = "lib.linkcheck.Check"
+
+	if !webURL(target) {
+		return 0, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	status, err := c.request(ctx, http.MethodHead, target)
+	if err == nil && status >= 400 {
+		status, err = c.request(ctx, http.MethodGet, target)
+	}
+
+	latency := time.Since(start)
+
+	if err != nil {
+		return 0, latency, fmt.Errorf("%s: %w", op, err)
+	}
+	if status >= 400 && status != http.StatusTooManyRequests {
+		return status, latency, fmt.Errorf("%s: %w %d", op, ErrBadStatus, status)
+	}
+
+	return status, latency, nil
+}
+
+func (c *Checker) request(ctx context.Context, method, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// the body is not needed, only the status
+	res.Body.Close()
+
+	return res.StatusCode, nil
+}
+
+func webURL(target string) bool {
+	u, err := url.Parse(target)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// hostLimiter spaces the requests to each host by interval.
+type hostLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time // when the next request to a host may start
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait blocks until a request to host may start.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	at := l.next[host]
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,245 @@
+package linkcheck_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/linkcheck"
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/storage"
+)
+
+type fakeStore struct {
+	links []storage.Link
+
+	mu     sync.Mutex
+	health map[string]storage.LinkHealth
+}
+
+func (s *fakeStore) ListLinks(_ context.Context, filter storage.LinkFilter) ([]storage.Link, error) {
+	if filter.Offset >= len(s.links) {
+		return nil, nil
+	}
+	links := s.links[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(links) {
+		links = links[:filter.Limit]
+	}
+
+	return links, nil
+}
+
+func (s *fakeStore) SaveHealth(_ context.Context, alias string, health storage.LinkHealth) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.health == nil {
+		s.health = make(map[string]storage.LinkHealth)
+	}
+	s.health[alias] = health
+
+	return nil
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/busy", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newChecker(store linkcheck.Store, opts linkcheck.Options) *linkcheck.Checker {
+	opts.AllowPrivate = true
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+
+	return linkcheck.New(slogdiscard.NewDiscardLogger(), store, opts)
+}
+
+func TestChecker_Check(t *testing.T) {
+	srv := newServer(t)
+	c := newChecker(nil, linkcheck.Options{Timeout: 100 * time.Millisecond, MaxRedirects: 2})
+
+	cases := []struct {
+		name   string
+		url    string
+		status int
+		err    error
+	}{
+		{name: "OK", url: srv.URL + "/ok", status: http.StatusOK},
+		{name: "HEAD not allowed", url: srv.URL + "/get-only", status: http.StatusOK},
+		{name: "Redirect", url: srv.URL + "/moved", status: http.StatusOK},
+		{name: "Busy", url: srv.URL + "/busy", status: http.StatusTooManyRequests},
+		{name: "Not found", url: srv.URL + "/none", status: http.StatusNotFound, err: linkcheck.ErrBadStatus},
+		{name: "Timeout", url: srv.URL + "/slow"},
+		{name: "Not a web page", url: "mailto:me@example.com", err: linkcheck.ErrUnsupportedURL},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			status, _, err := c.Check(context.Background(), tc.url)
+			assert.Equal(t, tc.status, status)
+			switch {
+			case tc.err != nil:
+				require.ErrorIs(t, err, tc.err)
+			case tc.status == 0:
+				require.Error(t, err)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestChecker_Guard(t *testing.T) {
+	srv := newServer(t)
+	c := linkcheck.New(slogdiscard.NewDiscardLogger(), nil, linkcheck.Options{Timeout: time.Second})
+
+	_, _, err := c.Check(context.Background(), srv.URL+"/ok")
+	require.Error(t, err)
+}
+
+func TestChecker_Round(t *testing.T) {
+	srv := newServer(t)
+
+	store := &fakeStore{links: []storage.Link{
+		{Alias: "ok", URL: srv.URL + "/ok", Health: storage.LinkHealth{Failures: 1}},
+		{Alias: "first", URL: srv.URL + "/none", FallbackURL: srv.URL + "/ok"},
+		{Alias: "broken", URL: srv.URL + "/none", FallbackURL: srv.URL + "/ok", Health: storage.LinkHealth{Failures: 1}},
+		{Alias: "no-fallback", URL: srv.URL + "/none", Health: storage.LinkHealth{Failures: 5}},
+		{Alias: "recovered", URL: srv.URL + "/ok", FallbackURL: srv.URL + "/ok", Health: storage.LinkHealth{Failures: 4, Fallback: true}},
+		{Alias: "expired", URL: srv.URL + "/none", NotAfter: time.Now().Add(-time.Hour)},
+		{Alias: "mail", URL: "mailto:me@example.com"},
+	}}
+
+	c := newChecker(store, linkcheck.Options{Concurrency: 3, FallbackAfter: 2})
+	require.NoError(t, c.Round(context.Background()))
+
+	var checked []string
+	for alias := range store.health {
+		checked = append(checked, alias)
+	}
+	sort.Strings(checked)
+	require.Equal(t, []string{"broken", "first", "no-fallback", "ok", "recovered"}, checked)
+
+	ok := store.health["ok"]
+	assert.Equal(t, http.StatusOK, ok.Status)
+	assert.Zero(t, ok.Failures)
+	assert.Empty(t, ok.Error)
+	assert.False(t, ok.CheckedAt.IsZero())
+
+	first := store.health["first"]
+	assert.Equal(t, http.StatusNotFound, first.Status)
+	assert.Equal(t, 1, first.Failures)
+	assert.NotEmpty(t, first.Error)
+	assert.False(t, first.Fallback)
+
+	assert.Equal(t, 2, store.health["broken"].Failures)
+	assert.True(t, store.health["broken"].Fallback)
+
+	assert.Equal(t, 6, store.health["no-fallback"].Failures)
+	assert.False(t, store.health["no-fallback"].Fallback)
+
+	assert.Zero(t, store.health["recovered"].Failures)
+	assert.False(t, store.health["recovered"].Fallback)
+}
+
+func TestChecker_HostInterval(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	store := &fakeStore{}
+	for _, alias := range []string{"a", "b", "c"} {
+		store.links = append(store.links, storage.Link{Alias: alias, URL: srv.URL + "/" + alias})
+	}
+
+	const interval = 50 * time.Millisecond
+	c := newChecker(store, linkcheck.Options{Concurrency: 3, HostInterval: interval})
+	require.NoError(t, c.Round(context.Background()))
+
+	require.Len(t, times, 3)
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		// the scheduled starts are exactly interval apart, allow for timer jitter
+		assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), interval-10*time.Millisecond)
+	}
+}
+
+func TestChecker_Run(t *testing.T) {
+	srv := newServer(t)
+	store := &fakeStore{links: []storage.Link{{Alias: "ok", URL: srv.URL + "/ok"}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		newChecker(store, linkcheck.Options{Interval: time.Hour}).Run(ctx)
+		close(done)
+	}()
+
+	// the first round starts right away
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.health) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestChecker_RunWithoutInterval(t *testing.T) {
+	srv := newServer(t)
+	store := &fakeStore{links: []storage.Link{{Alias: "ok", URL: srv.URL + "/ok"}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		newChecker(store, linkcheck.Options{}).Run(ctx)
+		close(done)
+	}()
+
+	// a single round runs, then the checker waits to be stopped
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.health) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -1,29 +1,32 @@
 package slogpretty
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
 type (
 	Handler struct {
 		SlogOpts
-		logger        *log.Logger
+		mu            *sync.Mutex // shared by all handlers derived from the same NewHandler call
+		out           io.Writer
+		useColor      bool
 		timeLayout    string // by default, do not display the time locally
-		attrs         []Attr
-		groups        []string
+		goas          []groupOrAttrs
 		fieldsFormat  FieldsFormat // Json, JsonIndent, Yaml
 		useLevelEmoji bool         // the flag instructs to use emoji in the logging level text
 	}
@@ -35,9 +38,15 @@ type (
 	marshalFunc  func(any) ([]byte, error)
 	Level        = slog.Level
 	levelInfo    struct {
-		text      string
-		emoji     string
-		colorFunc func(format string, a ...interface{}) string
+		text  string
+		emoji string
+		color color.Attribute
+	}
+	// groupOrAttrs holds either a group name or a list of attrs added with WithAttrs,
+	// in the order they were applied to the handler.
+	groupOrAttrs struct {
+		group string
+		attrs []Attr
 	}
 )
 
@@ -57,19 +66,23 @@ var (
 	}
 	levelsInfo = map[Level]levelInfo{
 		slog.LevelDebug: {
-			"DEBUG", "👀", color.MagentaString},
+			"DEBUG", "👀", color.FgMagenta},
 		slog.LevelInfo: {
-			"INFO ", "✅", color.BlueString},
+			"INFO ", "✅", color.FgBlue},
 		slog.LevelWarn: {
-			"WARN ", "🔥", color.YellowString},
+			"WARN ", "🔥", color.FgYellow},
 		slog.LevelError: {
-			"ERROR", "❌", color.RedString},
+			"ERROR", "❌", color.FgRed},
 	}
 )
 
-func NewHandler() Handler {
+// NewHandler creates a pretty handler writing to w. Colors are enabled only when
+// w is a terminal and the NO_COLOR environment variable is not set.
+func NewHandler(w io.Writer) Handler {
 	return Handler{
-		logger:       log.New(os.Stderr, "", 0),
+		mu:           &sync.Mutex{},
+		out:          w,
+		useColor:     colorSupported(w),
 		fieldsFormat: ffJson,
 		SlogOpts:     SlogOpts{Level: slog.LevelDebug},
 	}
@@ -94,7 +107,15 @@ func (f FieldsFormat) Marshal(v any) ([]byte, error) {
 }
 
 func (h Handler) WithOutput(output io.Writer) Handler {
-	h.logger = log.New(output, "", 0)
+	h.mu = &sync.Mutex{}
+	h.out = output
+	h.useColor = colorSupported(output)
+	return h
+}
+
+// WithColor forces colored output on or off regardless of the writer.
+func (h Handler) WithColor(v bool) Handler {
+	h.useColor = v
 	return h
 }
 
@@ -134,12 +155,12 @@ func (h Handler) WithLevelEmoji(v bool) Handler {
 }
 
 func (h Handler) Handle(_ context.Context, r Record) error {
-	var outputParts []interface{}
-	if h.timeLayout != "" {
-		outputParts = append(outputParts, color.WhiteString(r.Time.Format(h.timeLayout)))
+	var outputParts []string
+	if h.timeLayout != "" && !r.Time.IsZero() {
+		outputParts = append(outputParts, h.paint(color.FgWhite, r.Time.Format(h.timeLayout)))
 	}
 
-	outputParts = append(outputParts, h.recordLevel(r), color.CyanString(r.Message))
+	outputParts = append(outputParts, h.recordLevel(r), h.paint(color.FgCyan, r.Message))
 
 	strAttrs, err := h.recordAttrs(r)
 	if err != nil {
@@ -149,54 +170,156 @@ func (h Handler) Handle(_ context.Context, r Record) error {
 		outputParts = append(outputParts, strAttrs)
 	}
 
-	if h.SlogOpts.AddSource {
-		outputParts = append(outputParts, color.GreenString(recordFormatSource(r)))
+	if h.SlogOpts.AddSource && r.PC != 0 {
+		outputParts = append(outputParts, h.paint(color.FgGreen, recordFormatSource(r)))
 	}
 
-	h.logger.Println(outputParts...)
+	var buf bytes.Buffer
+	for i, part := range outputParts {
+		if i > 0 && !strings.HasPrefix(part, "\n") {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(part)
+	}
+	buf.WriteByte('\n')
 
-	return nil
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.out.Write(buf.Bytes())
+
+	return err
 }
 
 func (h Handler) Enabled(_ context.Context, l Level) bool {
-	return l.Level() >= h.SlogOpts.Level.Level()
+	minLevel := slog.LevelInfo
+	if h.SlogOpts.Level != nil {
+		minLevel = h.SlogOpts.Level.Level()
+	}
+	return l.Level() >= minLevel
 }
 
 func (h Handler) WithAttrs(attrs []Attr) SlogHandler {
-	h.attrs = append(h.attrs, attrs...)
-	return h
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
 }
 
 func (h Handler) WithGroup(name string) SlogHandler {
-	h.groups = append(h.groups, name)
+	if name == "" {
+		return h
+	}
+	return h.withGroupOrAttrs(groupOrAttrs{group: name})
+}
+
+func (h Handler) withGroupOrAttrs(goa groupOrAttrs) Handler {
+	// copy the slice so that handlers derived from the same parent do not share the backing array
+	goas := make([]groupOrAttrs, len(h.goas), len(h.goas)+1)
+	copy(goas, h.goas)
+	h.goas = append(goas, goa)
 	return h
 }
 
+// recordAttrs renders the handler attrs and the record attrs, nesting every
+// attr under the groups that were opened before it was added.
 func (h Handler) recordAttrs(r Record) (string, error) {
-	xs := attrsValues(append(recordAttrs(r), h.attrs...)...)
-	if len(xs) == 0 {
-		return "", nil
-	}
-	for i := len(h.groups) - 1; i >= 0; i-- {
-		xs = map[string]interface{}{
-			h.groups[i]: xs,
+	root := make(map[string]interface{})
+	cur := root
+	var groups []string
+
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			next := make(map[string]interface{})
+			cur[goa.group] = next
+			cur = next
+			groups = append(groups, goa.group)
+			continue
 		}
+		h.addAttrs(cur, groups, goa.attrs...)
+	}
+	h.addAttrs(cur, groups, recordAttrs(r)...)
+
+	pruneEmptyGroups(root)
+	if len(root) == 0 {
+		return "", nil
 	}
-	s, err := h.fieldsFormat.Marshal(xs)
+
+	s, err := h.fieldsFormat.Marshal(root)
 	if err != nil {
 		return "", err
 	}
-	return color.WhiteString(string(s)), nil
+	fields := strings.TrimSpace(string(s))
+	// multi-line formats (json-indent, yaml) start on their own line to keep nesting readable
+	if strings.Contains(fields, "\n") {
+		return "\n" + h.paint(color.FgWhite, fields), nil
+	}
+	return h.paint(color.FgWhite, fields), nil
+}
+
+func (h Handler) addAttrs(fields map[string]interface{}, groups []string, attrs ...Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+
+		if a.Value.Kind() == slog.KindGroup {
+			group := a.Value.Group()
+			if len(group) == 0 {
+				continue
+			}
+			// an inline group with an empty key is merged into the current level
+			if a.Key == "" {
+				h.addAttrs(fields, groups, group...)
+				continue
+			}
+			sub, ok := fields[a.Key].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				fields[a.Key] = sub
+			}
+			h.addAttrs(sub, append(groups[:len(groups):len(groups)], a.Key), group...)
+			continue
+		}
+
+		if h.SlogOpts.ReplaceAttr != nil {
+			a = h.SlogOpts.ReplaceAttr(groups, a)
+			a.Value = a.Value.Resolve()
+		}
+		if a.Equal(Attr{}) {
+			continue
+		}
+		fields[a.Key] = attrValue(a.Value)
+	}
+}
+
+func attrValue(v slog.Value) interface{} {
+	if err, ok := v.Any().(error); ok {
+		return err.Error()
+	}
+	return v.Any()
+}
+
+// pruneEmptyGroups removes groups that ended up without any attrs,
+// as required by the slog.Handler contract.
+func pruneEmptyGroups(fields map[string]interface{}) {
+	for k, v := range fields {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pruneEmptyGroups(sub)
+		if len(sub) == 0 {
+			delete(fields, k)
+		}
+	}
 }
 
 func (h Handler) recordLevel(r Record) string {
-	l := levelsInfo[r.Level.Level()]
+	l, ok := levelsInfo[r.Level.Level()]
 	level := l.text
 	if level == "" {
 		level = r.Level.String()
 	}
-	if l.colorFunc != nil {
-		level = l.colorFunc(level)
+	if ok {
+		level = h.paint(l.color, level)
 	}
 	if h.useLevelEmoji && l.emoji != "" {
 		level = l.emoji + " " + level
@@ -204,6 +327,28 @@ func (h Handler) recordLevel(r Record) string {
 	return level
 }
 
+// paint colors s unless colors are disabled for this handler.
+func (h Handler) paint(attr color.Attribute, s string) string {
+	if !h.useColor {
+		return s
+	}
+	c := color.New(attr)
+	c.EnableColor()
+	return c.Sprint(s)
+}
+
+// colorSupported reports whether w is a terminal and NO_COLOR is not set.
+func colorSupported(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
 // formats a Source for the log event.
 func recordFormatSource(r Record) string {
 	fs := runtime.CallersFrames([]uintptr{r.PC})
@@ -219,14 +364,6 @@ func recordFormatSource(r Record) string {
 	return fmt.Sprintf("%s:%d%s", filepath.Base(f.File), f.Line, function)
 }
 
-func (f marshalFunc) formatFields(fields map[string]interface{}) (string, error) {
-	b, err := f(fields)
-	if err != nil {
-		return "", err
-	}
-	return color.WhiteString(strings.TrimSpace(string(b))), nil
-}
-
 func recordAttrs(r Record) []Attr {
 	xs := make([]Attr, 0, r.NumAttrs())
 	r.Attrs(func(a Attr) bool {
@@ -235,15 +372,3 @@ func recordAttrs(r Record) []Attr {
 	})
 	return xs
 }
-
-func attrsValues(attrs ...Attr) map[string]interface{} {
-	fields := make(map[string]interface{}, len(attrs))
-	for _, a := range attrs {
-		if a.Value.Kind() == slog.KindGroup {
-			fields[a.Key] = attrsValues(a.Value.Group()...)
-		} else {
-			fields[a.Key] = a.Value.Any()
-		}
-	}
-	return fields
-}
@@ -0,0 +1,112 @@
+package slogpretty_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/logger/handlers/slogpretty"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestHandler_Golden(t *testing.T) {
+	cases := []struct {
+		name   string
+		format slogpretty.FieldsFormat
+	}{
+		{name: "json", format: "json"},
+		{name: "json-indent", format: "json-indent"},
+		{name: "yaml", format: "yaml"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			h := slogpretty.NewHandler(&buf).
+				WithFieldsFormat(tc.format).
+				WithTimeLayout("15:04:05").
+				WithLevelEmoji(true)
+
+			writeRecords(t, h)
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+			}
+
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+
+			assert.Equal(t, string(want), buf.String())
+		})
+	}
+}
+
+func TestHandler_Color(t *testing.T) {
+	var buf bytes.Buffer
+
+	// bytes.Buffer is not a terminal, so colors must be disabled by default
+	log := slog.New(slogpretty.NewHandler(&buf))
+	log.Info("plain")
+	assert.NotContains(t, buf.String(), "\x1b[")
+
+	buf.Reset()
+
+	log = slog.New(slogpretty.NewHandler(&buf).WithColor(true))
+	log.Info("colored")
+	assert.Contains(t, buf.String(), "\x1b[")
+}
+
+func TestHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+
+	log := slog.New(slogpretty.NewHandler(&buf).WithLevel(slog.LevelWarn))
+	log.Info("skipped")
+	log.Warn("written")
+
+	assert.NotContains(t, buf.String(), "skipped")
+	assert.Contains(t, buf.String(), "written")
+}
+
+func writeRecords(t *testing.T, h slogpretty.Handler) {
+	t.Helper()
+
+	ts := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	write := func(h slog.Handler, level slog.Level, msg string, attrs ...slog.Attr) {
+		r := slog.NewRecord(ts, level, msg, 0)
+		r.AddAttrs(attrs...)
+		require.NoError(t, h.Handle(context.Background(), r))
+	}
+
+	write(h, slog.LevelInfo, "no attrs")
+	write(h, slog.LevelDebug, "flat attrs",
+		slog.String("alias", "abcd"),
+		slog.Int("status", 302),
+	)
+
+	// attrs added before a group must stay at the top level
+	withGroup := h.WithAttrs([]slog.Attr{slog.String("component", "storage")}).
+		WithGroup("request").
+		WithAttrs([]slog.Attr{slog.String("method", "GET")}).
+		WithGroup("empty")
+	write(withGroup, slog.LevelWarn, "nested groups",
+		slog.Group("inner", slog.Bool("cached", true)),
+	)
+	write(withGroup, slog.LevelError, "empty group is omitted")
+
+	write(h, slog.LevelError, "error attr",
+		slog.Any("error", errors.New("boom")),
+		slog.Group("", slog.String("inlined", "yes")),
+	)
+}
@@ -0,0 +1,273 @@
+package pagemeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+	"url-shortener/internal/storage"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrForbiddenAddress = errors.New("address is not allowed")
+	ErrUnsupportedURL   = errors.New("only http and https URLs are fetched")
+	ErrNotHTML          = errors.New("response is not HTML")
+)
+
+// maxFieldLength bounds every stored field, in bytes.
+const maxFieldLength = 1000
+
+// Options configures a Fetcher.
+type Options struct {
+	Timeout      time.Duration // for the whole fetch including redirects and the body
+	MaxBytes     int64         // the rest of the page is ignored
+	MaxRedirects int
+	UserAgent    string
+	AllowPrivate bool // fetch pages on loopback and private networks, only for tests
+}
+
+// Fetcher reads the title and Open Graph tags of web pages. Unless AllowPrivate is set,
+// it refuses to connect to loopback, private and other non-public addresses, checking
+// the address actually dialed, so that neither redirects nor DNS can point it inside.
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = Guard
+	}
+
+	maxRedirects := opts.MaxRedirects
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			// no proxy: Guard has to see the address of the page itself
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   opts.Timeout,
+			ResponseHeaderTimeout: opts.Timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+
+	return &Fetcher{client: client, maxBytes: opts.MaxBytes, userAgent: opts.UserAgent}
+}
+
+// Fetch downloads target and returns what its head tells about the page.
+func (f *Fetcher) Fetch(ctx context.Context, target string) (storage.PageMeta, error) {
+	const op = "lib.pagemeta.Fetch"
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return storage.PageMeta{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return storage.PageMeta{}, fmt.Errorf("%s: %w", op, ErrUnsupportedURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return storage.PageMeta{}, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+	if f.userAgent != "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return storage.PageMeta{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return storage.PageMeta{}, fmt.Errorf("%s: unexpected status %d", op, res.StatusCode)
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return storage.PageMeta{}, fmt.Errorf("%s: %w: %q", op, ErrNotHTML, contentType)
+	}
+
+	body, err := charset.NewReader(io.LimitReader(res.Body, f.maxBytes), contentType)
+	if err != nil {
+		return storage.PageMeta{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := Parse(body, res.Request.URL)
+	page.FetchedAt = time.Now().UTC()
+
+	return page, nil
+}
+
+// Parse reads the title and Open Graph tags from the head of an HTML document.
+// Relative image URLs are resolved against base.
+func Parse(r io.Reader, base *url.URL) storage.PageMeta {
+	var page storage.PageMeta
+
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// the end of the document or of what was read of it
+			return page
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = page.Title == ""
+			case "meta":
+				if hasAttr {
+					readMeta(z, &page, base)
+				}
+			case "body":
+				return page
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return page
+			}
+		case html.TextToken:
+			if inTitle {
+				page.Title = clean(page.Title + string(z.Text()))
+			}
+		}
+	}
+}
+
+func readMeta(z *html.Tokenizer, page *storage.PageMeta, base *url.URL) {
+	var property, content string
+	for {
+		key, val, more := z.TagAttr()
+		switch string(key) {
+		case "property", "name":
+			if property == "" {
+				property = strings.ToLower(string(val))
+			}
+		case "content":
+			content = string(val)
+		}
+		if !more {
+			break
+		}
+	}
+
+	switch property {
+	case "og:title":
+		page.OGTitle = clean(content)
+	case "og:description":
+		page.OGDescription = clean(content)
+	case "og:image", "og:image:url", "og:image:secure_url":
+		if page.OGImage == "" {
+			page.OGImage = imageURL(content, base)
+		}
+	}
+}
+
+// imageURL resolves ref against base, images not served over HTTP are dropped.
+func imageURL(ref string, base *url.URL) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || len(u.String()) > maxFieldLength {
+		return ""
+	}
+
+	return u.String()
+}
+
+// clean collapses whitespace and cuts s to maxFieldLength on a rune boundary.
+func clean(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= maxFieldLength {
+		return s
+	}
+
+	s = s[:maxFieldLength]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+
+	return s
+}
+
+// Guard is a net.Dialer Control function rejecting non-public addresses.
+func Guard(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+
+	return nil
+}
+
+// nonPublic are the ranges netip has no predicate for.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach any IPv4 address
+}
+
+// Public reports whether ip is a globally routable unicast address.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
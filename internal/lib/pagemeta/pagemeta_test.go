@@ -0,0 +1,217 @@
+package pagemeta_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/logger/handlers/slogdiscard"
+	"url-shortener/internal/lib/pagemeta"
+	"url-shortener/internal/storage"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+  <title>  The Go
+    Programming Language </title>
+  <meta property="og:title" content="Go">
+  <meta name="og:description" content="Build simple, secure, scalable systems &amp; more">
+  <meta property="og:image" content="/images/logo.png">
+  <meta property="og:image" content="https://example.com/second.png">
+</head>
+<body>
+  <title>Not the title</title>
+  <meta property="og:title" content="Not the title either">
+</body>
+</html>`
+
+func newFetcher(opts pagemeta.Options) *pagemeta.Fetcher {
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = 1 << 20
+	}
+	opts.MaxRedirects = 3
+
+	return pagemeta.NewFetcher(opts)
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://go.dev/doc/")
+
+	got := pagemeta.Parse(strings.NewReader(page), base)
+	require.Equal(t, storage.PageMeta{
+		Title:         "The Go Programming Language",
+		OGTitle:       "Go",
+		OGDescription: "Build simple, secure, scalable systems & more",
+		OGImage:       "https://go.dev/images/logo.png",
+	}, got)
+
+	// a cut off document still yields what was read
+	got = pagemeta.Parse(strings.NewReader(page[:strings.Index(page, "<meta name")]), base)
+	require.Equal(t, storage.PageMeta{Title: "The Go Programming Language", OGTitle: "Go"}, got)
+
+	got = pagemeta.Parse(strings.NewReader(`<meta property="og:image" content="javascript:alert(1)">`), base)
+	require.Empty(t, got.OGImage)
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		_, _ = w.Write([]byte("<title>Caf\xe9</title>"))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<title>" + strings.Repeat("a", 100) + "</title><meta property=\"og:title\" content=\"late\">"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	f := newFetcher(pagemeta.Options{AllowPrivate: true, MaxBytes: 512})
+
+	got, err := f.Fetch(context.Background(), srv.URL+"/moved")
+	require.NoError(t, err)
+	assert.Equal(t, "The Go Programming Language", got.Title)
+	assert.Equal(t, srv.URL+"/images/logo.png", got.OGImage, "resolved against the final URL")
+	assert.False(t, got.FetchedAt.IsZero())
+
+	got, err = f.Fetch(context.Background(), srv.URL+"/latin1")
+	require.NoError(t, err)
+	assert.Equal(t, "Café", got.Title)
+
+	_, err = f.Fetch(context.Background(), srv.URL+"/image")
+	require.ErrorIs(t, err, pagemeta.ErrNotHTML)
+
+	_, err = f.Fetch(context.Background(), srv.URL+"/missing")
+	require.Error(t, err)
+
+	_, err = f.Fetch(context.Background(), "ftp://example.com/")
+	require.ErrorIs(t, err, pagemeta.ErrUnsupportedURL)
+
+	got, err = newFetcher(pagemeta.Options{AllowPrivate: true, MaxBytes: 64}).Fetch(context.Background(), srv.URL+"/huge")
+	require.NoError(t, err)
+	assert.Empty(t, got.OGTitle, "the rest of the page is ignored")
+
+	_, err = newFetcher(pagemeta.Options{AllowPrivate: true, Timeout: 100 * time.Millisecond}).Fetch(context.Background(), srv.URL+"/slow")
+	require.Error(t, err)
+}
+
+func TestFetcher_Guard(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(page))
+	}))
+	t.Cleanup(srv.Close)
+
+	f := newFetcher(pagemeta.Options{})
+
+	_, err := f.Fetch(context.Background(), srv.URL)
+	require.ErrorIs(t, err, pagemeta.ErrForbiddenAddress)
+
+	// localhost resolves to a loopback address
+	_, err = f.Fetch(context.Background(), strings.Replace(srv.URL, "127.0.0.1", "localhost", 1))
+	require.ErrorIs(t, err, pagemeta.ErrForbiddenAddress)
+}
+
+func TestPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"::ffff:93.184.216.34": true,
+		"100.128.0.1":          true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"255.255.255.255":      false,
+		"224.0.0.1":            false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"64:ff9b::a9fe:a9fe":   false,
+	}
+
+	for addr, want := range cases {
+		assert.Equal(t, want, pagemeta.Public(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+type fakeSaver struct {
+	mu    sync.Mutex
+	pages map[string]storage.PageMeta
+}
+
+func (f *fakeSaver) SavePageMeta(_ context.Context, alias string, page storage.PageMeta) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pages[alias] = page
+	return nil
+}
+
+func (f *fakeSaver) get(alias string) (storage.PageMeta, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	page, ok := f.pages[alias]
+	return page, ok
+}
+
+func TestWorker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<title>" + strings.TrimPrefix(r.URL.Path, "/") + "</title>"))
+	}))
+	t.Cleanup(srv.Close)
+
+	saver := &fakeSaver{pages: map[string]storage.PageMeta{}}
+	w := pagemeta.NewWorker(slogdiscard.NewDiscardLogger(), newFetcher(pagemeta.Options{AllowPrivate: true}), saver, 2, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	w.Enqueue("a", srv.URL+"/first")
+	w.Enqueue("b", srv.URL+"/second")
+
+	require.Eventually(t, func() bool {
+		a, okA := saver.get("a")
+		b, okB := saver.get("b")
+		return okA && okB && a.Title == "first" && b.Title == "second"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
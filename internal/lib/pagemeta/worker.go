@@ -0,0 +1,97 @@
+package pagemeta
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"url-shortener/internal/storage"
+
+	"url-shortener/internal/lib/logger/sl"
+)
+
+// PageSaver stores fetched pages with their links.
+type PageSaver interface {
+	SavePageMeta(ctx context.Context, alias string, page storage.PageMeta) error
+}
+
+type job struct {
+	alias string
+	url   string
+}
+
+// Worker fetches the pages of new links in the background, a fixed number at a time.
+type Worker struct {
+	log         *slog.Logger
+	fetcher     *Fetcher
+	saver       PageSaver
+	queue       chan job
+	concurrency int
+}
+
+// NewWorker creates a Worker keeping up to queueSize links waiting.
+func NewWorker(log *slog.Logger, fetcher *Fetcher, saver PageSaver, concurrency, queueSize int) *Worker {
+	return &Worker{
+		log:         log.With(slog.String("op", "lib.pagemeta.Worker")),
+		fetcher:     fetcher,
+		saver:       saver,
+		queue:       make(chan job, queueSize),
+		concurrency: max(concurrency, 1),
+	}
+}
+
+// Enqueue schedules fetching the destination of a link. Links are dropped
+// when the queue is full, losing the preview is better than slowing down saving.
+func (w *Worker) Enqueue(alias, url string) {
+	select {
+	case w.queue <- job{alias: alias, url: url}:
+	default:
+		w.log.Warn("page fetch queue is full", slog.String("alias", alias))
+	}
+}
+
+// Run processes the queue until ctx is canceled.
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-w.queue:
+					w.process(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) process(ctx context.Context, j job) {
+	log := w.log.With(slog.String("alias", j.alias))
+
+	page, err := w.fetcher.Fetch(ctx, j.url)
+	if err != nil {
+		log.Info("failed to fetch page", sl.Err(err))
+
+		return
+	}
+
+	err = w.saver.SavePageMeta(ctx, j.alias, page)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		log.Info("link was deleted before its page was fetched")
+
+		return
+	}
+	if err != nil {
+		log.Error("failed to save page", sl.Err(err))
+
+		return
+	}
+
+	log.Debug("page fetched", slog.String("title", page.Title))
+}
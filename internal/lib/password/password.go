@@ -0,0 +1,27 @@
+package password
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxBytes is the longest password bcrypt accepts, in bytes rather than characters.
+const MaxBytes = 72
+
+// Hash returns the bcrypt hash of password.
+func Hash(password string) (string, error) {
+	const op = "lib.password.Hash"
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return string(hash), nil
+}
+
+// Verify reports whether password matches hash. A malformed hash never matches.
+func Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
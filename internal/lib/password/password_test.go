@@ -0,0 +1,20 @@
+package password_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/password"
+)
+
+func TestHashVerify(t *testing.T) {
+	hash, err := password.Hash("secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, "secret", hash)
+
+	assert.True(t, password.Verify(hash, "secret"))
+	assert.False(t, password.Verify(hash, "Secret"))
+	assert.False(t, password.Verify("not a hash", "secret"))
+}
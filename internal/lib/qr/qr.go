@@ -0,0 +1,184 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// quietZone is the margin around the code required by the specification, in modules.
+const quietZone = 4
+
+var (
+	ErrInvalidLevel = errors.New("invalid error correction level")
+	ErrInvalidColor = errors.New("invalid color")
+	ErrTooSmall     = errors.New("size is too small for the content")
+)
+
+// Default colors.
+var (
+	Black = color.NRGBA{A: 0xff}
+	White = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,     // 7% of the code can be restored
+	"M": qrcode.Medium,  // 15%
+	"Q": qrcode.High,    // 25%
+	"H": qrcode.Highest, // 30%
+}
+
+// ValidLevel reports whether level is one of L, M, Q or H.
+func ValidLevel(level string) bool {
+	_, ok := levels[level]
+
+	return ok
+}
+
+// Options configures the rendered code.
+type Options struct {
+	Size       int    // width and height of the image in pixels
+	Level      string // error correction level: L, M, Q or H
+	Foreground color.NRGBA
+	Background color.NRGBA
+	QuietZone  bool // surround the code with the margin scanners rely on
+}
+
+// PNG renders content as a PNG image of exactly o.Size pixels. Modules are scaled
+// to whole pixels, the remaining space is filled with the background.
+func PNG(content string, o Options) ([]byte, error) {
+	const op = "lib.qr.PNG"
+
+	modules, err := bitmap(content, o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n := len(modules)
+	scale := o.Size / n
+	if scale == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrTooSmall)
+	}
+	offset := (o.Size - n*scale) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size), color.Palette{o.Background, o.Foreground})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG renders content as an SVG image of o.Size pixels with one unit per module.
+func SVG(content string, o Options) ([]byte, error) {
+	const op = "lib.qr.SVG"
+
+	modules, err := bitmap(content, o)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n := len(modules)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		o.Size, o.Size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d"%s/>`, n, n, fill(o.Background))
+	fmt.Fprintf(&buf, `<path%s d="`, fill(o.Foreground))
+	// one rectangle per horizontal run of dark modules
+	for y, row := range modules {
+		for x := 0; x < n; x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < n && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
+
+// ParseColor parses a hex color: RGB, RRGGBB or RRGGBBAA, with or without a leading #.
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+func bitmap(content string, o Options) ([][]bool, error) {
+	level, ok := levels[o.Level]
+	if !ok {
+		return nil, ErrInvalidLevel
+	}
+
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+
+	modules := code.Bitmap()
+	if !o.QuietZone {
+		return modules, nil
+	}
+
+	n := len(modules) + 2*quietZone
+	padded := make([][]bool, n)
+	for y := range padded {
+		padded[y] = make([]bool, n)
+		if y >= quietZone && y < n-quietZone {
+			copy(padded[y][quietZone:], modules[y-quietZone])
+		}
+	}
+
+	return padded, nil
+}
+
+func fill(c color.NRGBA) string {
+	attr := fmt.Sprintf(` fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		attr += fmt.Sprintf(` fill-opacity="%.3g"`, float64(c.A)/0xff)
+	}
+
+	return attr
+}
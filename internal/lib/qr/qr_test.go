@@ -0,0 +1,89 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/qr"
+)
+
+func options() qr.Options {
+	return qr.Options{
+		Size:       200,
+		Level:      "M",
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		QuietZone:  true,
+	}
+}
+
+func TestPNG(t *testing.T) {
+	data, err := qr.PNG("https://sho.rt/abcd", options())
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, 200, img.Bounds().Dx())
+	require.Equal(t, 200, img.Bounds().Dy())
+
+	// the corner is quiet zone, the finder pattern starts right after it
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.EqualValues(t, 0xffff, r)
+
+	o := options()
+	o.QuietZone = false
+	data, err = qr.PNG("https://sho.rt/abcd", o)
+	require.NoError(t, err)
+
+	img, err = png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	// without the quiet zone the finder pattern starts at the corner
+	r, _, _, _ = img.At(4, 4).RGBA()
+	assert.Zero(t, r)
+
+	o.Size = 10
+	_, err = qr.PNG("https://sho.rt/abcd", o)
+	require.ErrorIs(t, err, qr.ErrTooSmall)
+}
+
+func TestSVG(t *testing.T) {
+	o := options()
+	o.Background.A = 0
+
+	data, err := qr.SVG("https://sho.rt/abcd", o)
+	require.NoError(t, err)
+
+	svg := string(data)
+	assert.Contains(t, svg, `width="200" height="200" viewBox="0 0 33 33"`)
+	assert.Contains(t, svg, `fill="#ffffff" fill-opacity="0"`)
+	// the top left finder pattern starts after the quiet zone
+	assert.Contains(t, svg, `d="M4 4h7v1h-7z`)
+
+	o.Level = "X"
+	_, err = qr.SVG("https://sho.rt/abcd", o)
+	require.ErrorIs(t, err, qr.ErrInvalidLevel)
+}
+
+func TestParseColor(t *testing.T) {
+	cases := map[string]color.NRGBA{
+		"000":       {A: 0xff},
+		"#ff8000":   {R: 0xff, G: 0x80, A: 0xff},
+		"ffffff80":  {R: 0xff, G: 0xff, B: 0xff, A: 0x80},
+		"#FFFFFF00": {R: 0xff, G: 0xff, B: 0xff},
+	}
+	for s, want := range cases {
+		got, err := qr.ParseColor(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"", "red", "12345", "#gggggg"} {
+		_, err := qr.ParseColor(s)
+		assert.ErrorIs(t, err, qr.ErrInvalidColor, s)
+	}
+}
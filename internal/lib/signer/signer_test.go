@@ -0,0 +1,43 @@
+package signer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"url-shortener/internal/lib/signer"
+)
+
+func TestSigner(t *testing.T) {
+	s := signer.New([]byte("secret"))
+	now := time.Now()
+	token := s.Sign("abcd", now.Add(time.Minute))
+
+	cases := []struct {
+		name    string
+		signer  *signer.Signer
+		token   string
+		subject string
+		now     time.Time
+		valid   bool
+	}{
+		{name: "Valid", signer: s, token: token, subject: "abcd", now: now, valid: true},
+		{name: "Expired", signer: s, token: token, subject: "abcd", now: now.Add(time.Hour)},
+		{name: "Other subject", signer: s, token: token, subject: "abce", now: now},
+		{name: "Other secret", signer: signer.New([]byte("other")), token: token, subject: "abcd", now: now},
+		{name: "Tampered expiry", signer: s, token: "9" + token, subject: "abcd", now: now},
+		{name: "Malformed", signer: s, token: "garbage", subject: "abcd", now: now},
+		{name: "Empty", signer: s, token: "", subject: "abcd", now: now},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.valid, tc.signer.Verify(tc.token, tc.subject, tc.now))
+		})
+	}
+}
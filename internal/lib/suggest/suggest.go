@@ -0,0 +1,200 @@
+package suggest
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"url-shortener/internal/lib/aliaspolicy"
+)
+
+//go:embed synonyms.txt
+var synonymsFile string
+
+// synonyms maps a lower-cased word to the words that may replace it.
+var synonyms = parseSynonyms(synonymsFile)
+
+// Store tells which aliases are taken.
+type Store interface {
+	ExistingAliases(ctx context.Context, aliases []string) ([]string, error)
+}
+
+// Suggester offers available aliases similar to a taken one.
+type Suggester struct {
+	store   Store
+	aliases *aliaspolicy.Policy
+	count   int
+}
+
+// New creates a Suggester offering up to count aliases that aliases allows, none when count is 0.
+func New(store Store, aliases *aliaspolicy.Policy, count int) *Suggester {
+	return &Suggester{store: store, aliases: aliases, count: count}
+}
+
+// Suggest returns available aliases derived from alias. The candidates are checked
+// against the store in a single query.
+func (s *Suggester) Suggest(ctx context.Context, alias string) ([]string, error) {
+	const op = "lib.suggest.Suggest"
+
+	if s.count <= 0 {
+		return nil, nil
+	}
+
+	alias = s.aliases.Normalize(alias)
+
+	seen := map[string]bool{alias: true}
+	var candidates []string
+	for _, c := range Candidates(alias) {
+		c = s.aliases.Normalize(c)
+		if seen[c] || s.aliases.Check(c) != nil {
+			continue
+		}
+		seen[c] = true
+		candidates = append(candidates, c)
+	}
+
+	existing, err := s.store.ExistingAliases(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		taken[e] = true
+	}
+
+	var result []string
+	for _, c := range candidates {
+		if taken[c] {
+			continue
+		}
+		result = append(result, c)
+		if len(result) == s.count {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// maxSuffix is the largest number appended to an alias.
+const maxSuffix = 9
+
+// Candidates returns aliases derived from alias, most alike first: its words replaced with
+// synonyms, joined with other separators and followed by numbers. The kinds are interleaved
+// so that the first few candidates are varied. The candidates are not checked by any policy.
+func Candidates(alias string) []string {
+	if alias == "" {
+		return nil
+	}
+
+	words, sep := split(alias)
+
+	var replaced []string
+	for i, w := range words {
+		for _, syn := range synonyms[strings.ToLower(w)] {
+			next := append([]string(nil), words...)
+			next[i] = matchCase(syn, w)
+			replaced = append(replaced, strings.Join(next, sep))
+		}
+	}
+
+	var joined []string
+	if len(words) > 1 {
+		for _, other := range []string{"_", "-", ""} {
+			if other != sep {
+				joined = append(joined, strings.Join(words, other))
+			}
+		}
+	}
+
+	var numbered []string
+	for n := 2; n <= maxSuffix; n++ {
+		suffix := strconv.Itoa(n)
+		numbered = append(numbered, alias+suffix, alias+"_"+suffix, alias+"-"+suffix)
+	}
+
+	return interleave(replaced, joined, numbered)
+}
+
+// split returns the words of alias and the separator between them: "spring_sale" has the words
+// "spring" and "sale" joined with "_", "springSale" the words "spring" and "Sale" joined with "".
+func split(alias string) ([]string, string) {
+	for _, sep := range []string{"_", "-"} {
+		if strings.Contains(alias, sep) {
+			var words []string
+			for _, w := range strings.Split(alias, sep) {
+				if w != "" {
+					words = append(words, w)
+				}
+			}
+			return words, sep
+		}
+	}
+
+	var words []string
+	start := 0
+	var prev rune
+	for i, r := range alias {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(prev) {
+			words = append(words, alias[start:i])
+			start = i
+		}
+		prev = r
+	}
+
+	return append(words, alias[start:]), ""
+}
+
+// matchCase writes word the way like is written: upper-cased, capitalized or as is.
+func matchCase(word, like string) string {
+	switch first, _ := utf8.DecodeRuneInString(like); {
+	case like == strings.ToUpper(like) && like != strings.ToLower(like):
+		return strings.ToUpper(word)
+	case unicode.IsUpper(first):
+		r, size := utf8.DecodeRuneInString(word)
+		return string(unicode.ToUpper(r)) + word[size:]
+	}
+
+	return word
+}
+
+func interleave(lists ...[]string) []string {
+	var result []string
+	for i := 0; ; i++ {
+		added := false
+		for _, l := range lists {
+			if i < len(l) {
+				result = append(result, l[i])
+				added = true
+			}
+		}
+		if !added {
+			return result
+		}
+	}
+}
+
+// parseSynonyms reads a group of words per line, every word of a group may replace the others.
+// Empty lines and text after # are skipped.
+func parseSynonyms(s string) map[string][]string {
+	result := make(map[string][]string)
+
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		group := strings.Fields(strings.ToLower(line))
+		for _, w := range group {
+			for _, other := range group {
+				if other != w {
+					result[w] = append(result[w], other)
+				}
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,68 @@
+package suggest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/aliaspolicy"
+	"url-shortener/internal/lib/suggest"
+)
+
+type store struct {
+	taken   map[string]bool
+	queries int
+	err     error
+}
+
+func (s *store) ExistingAliases(_ context.Context, aliases []string) ([]string, error) {
+	s.queries++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var existing []string
+	for _, a := range aliases {
+		if s.taken[a] {
+			existing = append(existing, a)
+		}
+	}
+
+	return existing, nil
+}
+
+func TestCandidates(t *testing.T) {
+	candidates := suggest.Candidates("spring_promo")
+	assert.Equal(t, []string{"spring_deal", "spring-promo", "spring_promo2"}, candidates[:3])
+	assert.Contains(t, candidates, "springpromo")
+	assert.Contains(t, candidates, "spring_promo_9")
+	assert.NotContains(t, candidates, "spring_promo")
+
+	assert.Contains(t, suggest.Candidates("SpringPromo"), "SpringOffer")
+	assert.Contains(t, suggest.Candidates("PROMO"), "SALE")
+	assert.Equal(t, []string{"xyz2", "xyz_2", "xyz-2"}, suggest.Candidates("xyz")[:3])
+	assert.Empty(t, suggest.Candidates(""))
+}
+
+func TestSuggester_Suggest(t *testing.T) {
+	aliases, err := aliaspolicy.New(aliaspolicy.Config{MinLength: 3, MaxLength: 8, Reserved: []string{"sale"}})
+	require.NoError(t, err)
+
+	s := &store{taken: map[string]bool{"promo": true, "deal": true, "promo2": true}}
+	suggestions, err := suggest.New(s, aliases, 3).Suggest(context.Background(), "promo")
+	require.NoError(t, err)
+	// "sale" is reserved and "promo-2" is not a valid alias without Unicode mode
+	assert.Equal(t, []string{"offer", "promo_2", "discount"}, suggestions)
+	assert.Equal(t, 1, s.queries)
+
+	s.err = errors.New("storage is down")
+	_, err = suggest.New(s, aliases, 3).Suggest(context.Background(), "promo")
+	require.ErrorIs(t, err, s.err)
+
+	suggestions, err = suggest.New(s, aliases, 0).Suggest(context.Background(), "promo")
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+}
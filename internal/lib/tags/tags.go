@@ -0,0 +1,28 @@
+package tags
+
+import (
+	"sort"
+	"strings"
+)
+
+// Normalize trims and lowercases tags, drops empty and repeated ones and sorts the rest,
+// so that "Go" and " go" are the same tag.
+func Normalize(tags []string) []string {
+	seen := make(map[string]struct{}, len(tags))
+
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+
+	return out
+}
@@ -0,0 +1,45 @@
+package tags_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/tags"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{
+			name: "Nil",
+		},
+		{
+			name: "Only blanks",
+			tags: []string{"", "  "},
+		},
+		{
+			name: "Case and spaces",
+			tags: []string{" Go ", "go", "Marketing", "GO"},
+			want: []string{"go", "marketing"},
+		},
+		{
+			name: "Unicode",
+			tags: []string{"Рассылка", "рассылка", "b", "a"},
+			want: []string{"a", "b", "рассылка"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, tags.Normalize(tc.tags))
+		})
+	}
+}
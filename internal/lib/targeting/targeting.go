@@ -0,0 +1,168 @@
+package targeting
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"url-shortener/internal/storage"
+)
+
+// Platforms detected from the User-Agent header.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+)
+
+// Platform groups accepted in rules in addition to the platforms themselves.
+const (
+	PlatformMobile  = "mobile"  // ios or android
+	PlatformDesktop = "desktop" // windows, macos or linux
+)
+
+// Visitor describes who follows a link.
+type Visitor struct {
+	Platform  string   // one of the Platform constants, empty when unknown
+	Languages []string // accepted language tags, most preferred first
+	Country   string   // ISO 3166-1 alpha-2 code, empty when unknown
+}
+
+// Platform detects the platform from a User-Agent header.
+func Platform(userAgent string) string {
+	switch {
+	// Android user agents also mention Linux, so it goes first
+	case strings.Contains(userAgent, "Android"):
+		return PlatformAndroid
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return PlatformIOS
+	case strings.Contains(userAgent, "Windows"):
+		return PlatformWindows
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return PlatformMacOS
+	case strings.Contains(userAgent, "Linux"):
+		return PlatformLinux
+	}
+
+	return ""
+}
+
+// crawlers are parts of the user agents of the bots fetching link previews for chat apps and social networks.
+var crawlers = []string{
+	"slackbot", "slack-imgproxy", "twitterbot", "facebookexternalhit", "facebot", "linkedinbot", "discordbot",
+	"telegrambot", "whatsapp", "skypeuripreview", "pinterest", "redditbot", "embedly", "vkshare", "viber",
+	"mastodon", "iframely", "google-pagerenderer", "bitlybot", "tumblr", "snapchat",
+}
+
+// Crawler reports whether a User-Agent header belongs to a bot building link previews.
+func Crawler(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, c := range crawlers {
+		if strings.Contains(userAgent, c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Languages parses an Accept-Language header into language tags ordered by preference.
+// The wildcard and languages with q=0 are dropped.
+func Languages(acceptLanguage string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		langs = append(langs, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+
+	return tags
+}
+
+// Match returns the first rule matching v. A rule matches when all of its non-empty
+// conditions do. Language conditions are compared against a single language: the most
+// preferred one of v that any otherwise matching rule accepts, so that the order of
+// the visitor's preferences wins over the order of language rules.
+func Match(rules []storage.Rule, v Visitor) (storage.Rule, bool) {
+	lang := preferredLanguage(rules, v)
+
+	for _, rule := range rules {
+		if !matchesDevice(rule, v) {
+			continue
+		}
+		if rule.Language != "" && (lang == "" || !matchesLanguage(rule.Language, lang)) {
+			continue
+		}
+		return rule, true
+	}
+
+	return storage.Rule{}, false
+}
+
+func preferredLanguage(rules []storage.Rule, v Visitor) string {
+	for _, lang := range v.Languages {
+		for _, rule := range rules {
+			if rule.Language != "" && matchesDevice(rule, v) && matchesLanguage(rule.Language, lang) {
+				return lang
+			}
+		}
+	}
+
+	return ""
+}
+
+// matchesDevice checks the platform and country conditions of rule.
+func matchesDevice(rule storage.Rule, v Visitor) bool {
+	if rule.Country != "" && !strings.EqualFold(rule.Country, v.Country) {
+		return false
+	}
+
+	switch rule.Platform {
+	case "":
+		return true
+	case PlatformMobile:
+		return v.Platform == PlatformIOS || v.Platform == PlatformAndroid
+	case PlatformDesktop:
+		return v.Platform == PlatformWindows || v.Platform == PlatformMacOS || v.Platform == PlatformLinux
+	}
+
+	return rule.Platform == v.Platform
+}
+
+// matchesLanguage reports whether the rule language covers tag: "de" covers "de" and "de-AT".
+func matchesLanguage(ruleLang, tag string) bool {
+	if len(tag) > len(ruleLang) && tag[len(ruleLang)] == '-' {
+		tag = tag[:len(ruleLang)]
+	}
+
+	return strings.EqualFold(ruleLang, tag)
+}
@@ -0,0 +1,106 @@
+package targeting_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"url-shortener/internal/lib/targeting"
+	"url-shortener/internal/storage"
+)
+
+func TestPlatform(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15": targeting.PlatformIOS,
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36":                 targeting.PlatformAndroid,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36":                targeting.PlatformWindows,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15":        targeting.PlatformMacOS,
+		"Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0":      targeting.PlatformLinux,
+		"curl/8.4.0": "",
+	}
+
+	for ua, want := range cases {
+		assert.Equal(t, want, targeting.Platform(ua), ua)
+	}
+}
+
+func TestCrawler(t *testing.T) {
+	cases := map[string]bool{
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)": true,
+		"Twitterbot/1.0": true,
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)":             true,
+		"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)":                     true,
+		"TelegramBot (like TwitterBot)":                                                         true,
+		"WhatsApp/2.23.20.0 A":                                                                  true,
+		"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)": true,
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15":           false,
+		"Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0":                false,
+		"": false,
+	}
+
+	for ua, want := range cases {
+		assert.Equal(t, want, targeting.Crawler(ua), ua)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	assert.Equal(t, []string{"de-AT", "de", "en"}, targeting.Languages("en;q=0.5, de-AT, *;q=0.1, de;q=0.8, fr;q=0"))
+	assert.Empty(t, targeting.Languages(""))
+}
+
+func TestMatch(t *testing.T) {
+	rules := []storage.Rule{
+		{ID: 1, Platform: targeting.PlatformIOS, URL: "https://apps.apple.com/app"},
+		{ID: 2, Platform: targeting.PlatformAndroid, URL: "https://play.google.com/app"},
+		{ID: 3, Language: "en", URL: "https://example.com/en"},
+		{ID: 4, Language: "de", URL: "https://example.com/de"},
+		{ID: 5, Country: "FR", Platform: targeting.PlatformDesktop, URL: "https://example.fr"},
+	}
+
+	cases := []struct {
+		name    string
+		visitor targeting.Visitor
+		want    int64
+	}{
+		{
+			name:    "Platform first",
+			visitor: targeting.Visitor{Platform: targeting.PlatformIOS, Languages: []string{"de"}},
+			want:    1,
+		},
+		{
+			name:    "Preferred language wins over rule order",
+			visitor: targeting.Visitor{Languages: []string{"de-AT", "en"}},
+			want:    4,
+		},
+		{
+			name:    "Less preferred language",
+			visitor: targeting.Visitor{Languages: []string{"fr", "en"}},
+			want:    3,
+		},
+		{
+			name:    "Country",
+			visitor: targeting.Visitor{Platform: targeting.PlatformMacOS, Country: "fr"},
+			want:    5,
+		},
+		{
+			name:    "Country on another platform",
+			visitor: targeting.Visitor{Country: "FR"},
+		},
+		{
+			name:    "Nothing matches",
+			visitor: targeting.Visitor{Platform: targeting.PlatformLinux, Languages: []string{"ru"}},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rule, ok := targeting.Match(rules, tc.visitor)
+			assert.Equal(t, tc.want != 0, ok)
+			assert.Equal(t, tc.want, rule.ID)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle counts failures per key in a fixed window and rejects
+// further attempts once the limit is reached, until the window ends.
+type Throttle struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	failures int
+	reset    time.Time
+}
+
+// New creates a Throttle allowing limit failures per window.
+// A non-positive limit disables throttling.
+func New(limit int, window time.Duration) *Throttle {
+	return &Throttle{
+		limit:   limit,
+		window:  window,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Allow reports whether another attempt for key is allowed at now.
+// When it is not, it also returns how long to wait.
+func (t *Throttle) Allow(key string, now time.Time) (bool, time.Duration) {
+	if t.limit <= 0 {
+		return true, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.allow(key, now)
+}
+
+// Attempt is Allow followed by Fail when the attempt is allowed, done at once: concurrent
+// attempts cannot all pass before the first failure is recorded. The caller calls Reset
+// when the attempt succeeds.
+func (t *Throttle) Attempt(key string, now time.Time) (bool, time.Duration) {
+	if t.limit <= 0 {
+		return true, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ok, wait := t.allow(key, now)
+	if ok {
+		t.fail(key, now)
+	}
+
+	return ok, wait
+}
+
+func (t *Throttle) allow(key string, now time.Time) (bool, time.Duration) {
+	e, ok := t.entries[key]
+	if !ok || !now.Before(e.reset) {
+		return true, 0
+	}
+	if e.failures < t.limit {
+		return true, 0
+	}
+
+	return false, e.reset.Sub(now)
+}
+
+// Fail records a failed attempt for key at now.
+func (t *Throttle) Fail(key string, now time.Time) {
+	if t.limit <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.fail(key, now)
+}
+
+func (t *Throttle) fail(key string, now time.Time) {
+	e, ok := t.entries[key]
+	if !ok || !now.Before(e.reset) {
+		t.cleanup(now)
+		e = &entry{reset: now.Add(t.window)}
+		t.entries[key] = e
+	}
+	e.failures++
+}
+
+// Reset forgets the failures recorded for key.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// cleanup drops expired windows, so that keys nobody retries do not pile up.
+// It runs only when a new window is opened, which bounds its cost by the failure rate.
+func (t *Throttle) cleanup(now time.Time) {
+	for key, e := range t.entries {
+		if !now.Before(e.reset) {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package throttle_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"url-shortener/internal/lib/throttle"
+)
+
+func TestThrottle(t *testing.T) {
+	th := throttle.New(2, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		ok, _ := th.Allow("abcd", now)
+		assert.True(t, ok)
+		th.Fail("abcd", now)
+	}
+
+	ok, wait := th.Allow("abcd", now.Add(10*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 50*time.Second, wait)
+
+	ok, _ = th.Allow("other", now)
+	assert.True(t, ok, "keys are throttled independently")
+
+	ok, _ = th.Allow("abcd", now.Add(time.Minute))
+	assert.True(t, ok, "the window has ended")
+
+	th.Fail("abcd", now)
+	th.Fail("abcd", now)
+	th.Reset("abcd")
+
+	ok, _ = th.Allow("abcd", now)
+	assert.True(t, ok)
+}
+
+func TestThrottle_Attempt(t *testing.T) {
+	th := throttle.New(3, time.Minute)
+	now := time.Now()
+
+	// concurrent attempts are counted before their outcome is known
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := th.Attempt("abcd", now); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 3, allowed.Load())
+
+	ok, _ := th.Allow("abcd", now)
+	assert.False(t, ok)
+
+	// a successful attempt forgets the others
+	th.Reset("abcd")
+	ok, _ = th.Attempt("abcd", now)
+	assert.True(t, ok)
+	th.Reset("abcd")
+	ok, _ = th.Allow("abcd", now)
+	assert.True(t, ok)
+}
+
+func TestThrottle_Disabled(t *testing.T) {
+	th := throttle.New(0, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		th.Fail("abcd", now)
+	}
+
+	ok, _ := th.Allow("abcd", now)
+	assert.True(t, ok)
+}
@@ -0,0 +1,231 @@
+package urlpolicy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/confusable"
+
+	"golang.org/x/net/idna"
+)
+
+// Rules a URL can be rejected by.
+const (
+	RuleLength    = "length"
+	RuleSyntax    = "syntax"
+	RuleScheme    = "scheme"
+	RuleHomograph = "homograph"
+	RuleLoop      = "loop"
+	RuleDenied    = "deny"
+	RuleAllowed   = "allow"
+	RuleBlocked   = "blocklist"
+)
+
+var ErrRejected = errors.New("url rejected")
+
+// Error tells which rule rejected a URL.
+type Error struct {
+	Rule   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRejected, e.Reason)
+}
+
+func (e *Error) Unwrap() error {
+	return ErrRejected
+}
+
+// Config of a Policy. Domain patterns are a host like "example.com", matching it exactly,
+// "*.example.com", matching its subdomains, or "*", matching any host.
+type Config struct {
+	Schemes         []string // http and https when empty
+	Allow           []string // when set, only hosts matching one of them are accepted
+	Deny            []string
+	ShortDomains    []string        // hosts serving the short links, links to them would redirect in a loop
+	MaxLength       int             // in bytes, unlimited when 0
+	AllowHomographs bool            // accept hosts mixing look-alike letters of different scripts
+	Blocklist       *blocklist.List // phishing and malware domains, nil when not used
+}
+
+// Policy decides which destinations links may lead to.
+type Policy struct {
+	schemes         map[string]bool
+	allow           []pattern
+	deny            []pattern
+	short           []pattern
+	maxLength       int
+	allowHomographs bool
+	blocklist       *blocklist.List
+}
+
+// hosts compares hosts in their ASCII form. Underscores are not valid in domain names
+// but occur in real hosts, so they are let through.
+var hosts = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false), idna.StrictDomainName(false))
+
+func New(cfg Config) (*Policy, error) {
+	const op = "lib.urlpolicy.New"
+
+	p := &Policy{
+		schemes:         make(map[string]bool),
+		maxLength:       cfg.MaxLength,
+		allowHomographs: cfg.AllowHomographs,
+		blocklist:       cfg.Blocklist,
+	}
+
+	schemes := cfg.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, s := range schemes {
+		p.schemes[strings.ToLower(s)] = true
+	}
+
+	var err error
+	if p.allow, err = parsePatterns(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("%s: allow: %w", op, err)
+	}
+	if p.deny, err = parsePatterns(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("%s: deny: %w", op, err)
+	}
+	if p.short, err = parsePatterns(cfg.ShortDomains); err != nil {
+		return nil, fmt.Errorf("%s: short domains: %w", op, err)
+	}
+
+	return p, nil
+}
+
+// Check returns an *Error if rawURL breaks the policy.
+func (p *Policy) Check(rawURL string) error {
+	if p.maxLength > 0 && len(rawURL) > p.maxLength {
+		return &Error{Rule: RuleLength, Reason: fmt.Sprintf("url is longer than %d bytes", p.maxLength)}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return &Error{Rule: RuleSyntax, Reason: "url is not valid"}
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemes[scheme] {
+		return &Error{Rule: RuleScheme, Reason: fmt.Sprintf("scheme %q is not allowed", scheme)}
+	}
+
+	if u.Host == "" {
+		if scheme == "http" || scheme == "https" {
+			return &Error{Rule: RuleSyntax, Reason: "url has no host"}
+		}
+		// like mailto:, nothing to match the domain rules against
+		return nil
+	}
+
+	host, err := hosts.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil || host == "" {
+		return &Error{Rule: RuleSyntax, Reason: "host is not valid"}
+	}
+
+	if !p.allowHomographs && homograph(host) {
+		return &Error{Rule: RuleHomograph, Reason: fmt.Sprintf("host %q mixes look-alike letters of different scripts", u.Hostname())}
+	}
+
+	if match(p.short, host) {
+		return &Error{Rule: RuleLoop, Reason: "url leads to a short link"}
+	}
+
+	if match(p.deny, host) {
+		return &Error{Rule: RuleDenied, Reason: fmt.Sprintf("domain %q is denied", host)}
+	}
+
+	if len(p.allow) > 0 && !match(p.allow, host) {
+		return &Error{Rule: RuleAllowed, Reason: fmt.Sprintf("domain %q is not allowed", host)}
+	}
+
+	if p.blocklist != nil {
+		if m, ok := p.blocklist.LookupHost(host); ok {
+			return &Error{Rule: RuleBlocked, Reason: fmt.Sprintf("domain %q is listed in the %s blocklist", m.Domain, m.Feed)}
+		}
+	}
+
+	return nil
+}
+
+// pattern is a domain pattern in ASCII form.
+type pattern struct {
+	host     string // empty for "*"
+	wildcard bool   // matches the subdomains of host
+}
+
+func parsePatterns(raw []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(raw))
+	for _, r := range raw {
+		r = strings.TrimSpace(r)
+		if r == "*" {
+			patterns = append(patterns, pattern{wildcard: true})
+
+			continue
+		}
+
+		var pt pattern
+		if rest, ok := strings.CutPrefix(r, "*."); ok {
+			pt.wildcard = true
+			r = rest
+		}
+		r = strings.TrimSuffix(r, ".")
+		if r == "" || strings.Contains(r, "*") {
+			return nil, fmt.Errorf("invalid domain pattern %q", r)
+		}
+
+		host, err := hosts.ToASCII(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain pattern %q: %w", r, err)
+		}
+		pt.host = host
+
+		patterns = append(patterns, pt)
+	}
+
+	return patterns, nil
+}
+
+func match(patterns []pattern, host string) bool {
+	for _, p := range patterns {
+		switch {
+		case p.host == "":
+			return true
+		case p.wildcard:
+			if strings.HasSuffix(host, "."+p.host) {
+				return true
+			}
+		case host == p.host:
+			return true
+		}
+	}
+
+	return false
+}
+
+// homograph reports whether a label of the ASCII host mixes scripts in a confusing way
+// or is written in Cyrillic look-alikes of Latin letters under a non-Cyrillic top-level domain.
+func homograph(host string) bool {
+	unicodeHost, err := hosts.ToUnicode(host)
+	if err != nil {
+		return true
+	}
+
+	labels := strings.Split(unicodeHost, ".")
+	cyrillicTLD := confusable.Scripts(labels[len(labels)-1])["Cyrillic"]
+
+	for _, label := range labels {
+		if confusable.Mixed(label) {
+			return true
+		}
+		if !cyrillicTLD && confusable.LatinLookalike(label) {
+			return true
+		}
+	}
+
+	return false
+}
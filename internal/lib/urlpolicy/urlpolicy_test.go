@@ -0,0 +1,116 @@
+package urlpolicy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/blocklist"
+	"url-shortener/internal/lib/urlpolicy"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p, err := urlpolicy.New(urlpolicy.Config{
+		Schemes:      []string{"https", "http", "mailto"},
+		Deny:         []string{"evil.com", "*.tracker.net"},
+		ShortDomains: []string{"sho.rt", "*.sho.rt"},
+		MaxLength:    100,
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+		url  string
+		rule string // empty when accepted
+	}{
+		{name: "Plain", url: "https://example.com/path?q=1"},
+		{name: "Mailto", url: "mailto:me@example.com"},
+		{name: "Underscore", url: "https://my_host.example.com/"},
+		{name: "IDN", url: "https://münchen.de/"},
+		{name: "Cyrillic domain", url: "https://пример.рф/"},
+		{name: "Japanese", url: "https://日本語とカタカナ.jp/"},
+		{name: "Too long", url: "https://example.com/" + strings.Repeat("a", 100), rule: urlpolicy.RuleLength},
+		{name: "Javascript", url: "javascript:alert(1)", rule: urlpolicy.RuleScheme},
+		{name: "Data", url: "data:text/html,<script>alert(1)</script>", rule: urlpolicy.RuleScheme},
+		{name: "File", url: "FILE:///etc/passwd", rule: urlpolicy.RuleScheme},
+		{name: "No host", url: "https:///path", rule: urlpolicy.RuleSyntax},
+		{name: "Relative", url: "/path", rule: urlpolicy.RuleSyntax},
+		{name: "Loop", url: "https://SHO.RT./abcd", rule: urlpolicy.RuleLoop},
+		{name: "Loop through a subdomain", url: "http://www.sho.rt/abcd", rule: urlpolicy.RuleLoop},
+		{name: "Denied", url: "https://evil.com/", rule: urlpolicy.RuleDenied},
+		{name: "Denied subdomain", url: "https://a.b.tracker.net/", rule: urlpolicy.RuleDenied},
+		{name: "Wildcard does not match the domain itself", url: "https://tracker.net/"},
+		{name: "Not a subdomain", url: "https://notevil.com/"},
+		{name: "Mixed scripts", url: "https://pаypal.com/", rule: urlpolicy.RuleHomograph},
+		{name: "Cyrillic look-alike", url: "https://xn--80ak6aa92e.com/", rule: urlpolicy.RuleHomograph},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := p.Check(tc.url)
+			if tc.rule == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var policyErr *urlpolicy.Error
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, tc.rule, policyErr.Rule)
+			assert.True(t, errors.Is(err, urlpolicy.ErrRejected))
+		})
+	}
+}
+
+func TestPolicy_Allow(t *testing.T) {
+	p, err := urlpolicy.New(urlpolicy.Config{Allow: []string{"example.com", "*.example.com"}})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check("https://example.com/"))
+	require.NoError(t, p.Check("https://www.example.com/"))
+	require.ErrorIs(t, p.Check("https://example.org/"), urlpolicy.ErrRejected)
+	// http and https only by default
+	require.ErrorIs(t, p.Check("ftp://example.com/"), urlpolicy.ErrRejected)
+}
+
+func TestPolicy_AllowHomographs(t *testing.T) {
+	p, err := urlpolicy.New(urlpolicy.Config{AllowHomographs: true})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check("https://pаypal.com/"))
+}
+
+func TestPolicy_Blocklist(t *testing.T) {
+	feed := filepath.Join(t.TempDir(), "phishing.txt")
+	require.NoError(t, os.WriteFile(feed, []byte("evil.com\n"), 0o644))
+
+	list, err := blocklist.New([]blocklist.Feed{{Name: "phishing", Path: feed, Format: blocklist.FormatDomains}})
+	require.NoError(t, err)
+
+	p, err := urlpolicy.New(urlpolicy.Config{Blocklist: list})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check("https://example.com/"))
+
+	err = p.Check("https://www.evil.com/login")
+	var policyErr *urlpolicy.Error
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, urlpolicy.RuleBlocked, policyErr.Rule)
+	assert.Equal(t, `url rejected: domain "evil.com" is listed in the phishing blocklist`, err.Error())
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"", "*.", "a.*.com", "*example.com"} {
+		_, err := urlpolicy.New(urlpolicy.Config{Deny: []string{pattern}})
+		assert.Error(t, err, pattern)
+	}
+}
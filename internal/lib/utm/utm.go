@@ -0,0 +1,70 @@
+package utm
+
+import (
+	"fmt"
+	"net/url"
+
+	"url-shortener/internal/lib/forward"
+)
+
+// Params are the UTM tags added to a target URL. Empty fields are not added.
+type Params struct {
+	Source   string `json:"source,omitempty" validate:"omitempty,max=255"`
+	Medium   string `json:"medium,omitempty" validate:"omitempty,max=255"`
+	Campaign string `json:"campaign,omitempty" validate:"omitempty,max=255"`
+	Term     string `json:"term,omitempty" validate:"omitempty,max=255"`
+	Content  string `json:"content,omitempty" validate:"omitempty,max=255"`
+}
+
+// Merge returns p with the non-empty fields of override replacing its own.
+func (p Params) Merge(override Params) Params {
+	for _, f := range []struct{ dst, src *string }{
+		{&p.Source, &override.Source},
+		{&p.Medium, &override.Medium},
+		{&p.Campaign, &override.Campaign},
+		{&p.Term, &override.Term},
+		{&p.Content, &override.Content},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+
+	return p
+}
+
+// Values returns the non-empty tags as query values.
+func (p Params) Values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"utm_source":   p.Source,
+		"utm_medium":   p.Medium,
+		"utm_campaign": p.Campaign,
+		"utm_term":     p.Term,
+		"utm_content":  p.Content,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	return values
+}
+
+// Apply adds the tags to target. A tag already present in target is replaced,
+// the rest of target keeps its original encoding.
+func Apply(target string, p Params) (string, error) {
+	const op = "lib.utm.Apply"
+
+	values := p.Values()
+	if len(values) == 0 {
+		return target, nil
+	}
+
+	u, err := forward.Target(target, "", values.Encode(), forward.QueryRequest)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return u, nil
+}
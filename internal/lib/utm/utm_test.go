@@ -0,0 +1,57 @@
+package utm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/utm"
+)
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name   string
+		target string
+		params utm.Params
+		want   string
+	}{
+		{
+			name:   "No tags",
+			target: "https://example.com/a?b=1",
+			want:   "https://example.com/a?b=1",
+		},
+		{
+			name:   "Encoded",
+			target: "https://example.com/",
+			params: utm.Params{Source: "news letter", Campaign: "spring&sale"},
+			want:   "https://example.com/?utm_campaign=spring%26sale&utm_source=news+letter",
+		},
+		{
+			name:   "Existing tag is replaced",
+			target: "https://example.com/?utm_source=old&id=%2F1#top",
+			params: utm.Params{Source: "new", Medium: "email"},
+			want:   "https://example.com/?id=%2F1&utm_medium=email&utm_source=new#top",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := utm.Apply(tc.target, tc.params)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParams_Merge(t *testing.T) {
+	preset := utm.Params{Source: "newsletter", Medium: "email", Campaign: "spring"}
+
+	got := preset.Merge(utm.Params{Campaign: "summer", Content: "banner"})
+
+	assert.Equal(t, utm.Params{Source: "newsletter", Medium: "email", Campaign: "summer", Content: "banner"}, got)
+}
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"url-shortener/internal/storage"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// URLGetter is the storage the cache reads through to.
+type URLGetter interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// Options configures the Cache.
+type Options struct {
+	Size        int           // maximum number of cached aliases, including negative entries
+	TTL         time.Duration // how long a found link is cached
+	NegativeTTL time.Duration // how long an unknown alias is cached, zero disables negative caching
+}
+
+// Cache is an in-process LRU cache in front of URLGetter.
+// Concurrent misses for the same alias are collapsed into a single storage lookup.
+type Cache struct {
+	next URLGetter
+	opts Options
+
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List // front is the most recently used entry
+	version uint64     // incremented on every invalidation
+
+	group singleflight.Group
+}
+
+type entry struct {
+	alias   string
+	link    storage.Link
+	found   bool
+	expires time.Time
+}
+
+type result struct {
+	link  storage.Link
+	found bool
+}
+
+// New creates a Cache reading through to next.
+func New(next URLGetter, opts Options) *Cache {
+	return &Cache{
+		next:  next,
+		opts:  opts,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// GetLink returns the link for alias from the cache or loads it from the underlying storage.
+func (c *Cache) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.cache.GetLink"
+
+	if e, ok := c.get(alias); ok {
+		if !e.found {
+			return storage.Link{}, storage.ErrURLNotFound
+		}
+		return e.link, nil
+	}
+
+	ch := c.group.DoChan(alias, func() (interface{}, error) {
+		return c.load(ctx, alias)
+	})
+
+	select {
+	case <-ctx.Done():
+		return storage.Link{}, fmt.Errorf("%s: %w", op, ctx.Err())
+	case res := <-ch:
+		if res.Err != nil {
+			return storage.Link{}, res.Err
+		}
+		r := res.Val.(result)
+		if !r.found {
+			return storage.Link{}, storage.ErrURLNotFound
+		}
+		return r.link, nil
+	}
+}
+
+// Invalidate drops the cached entry for alias, so that the next lookup reads the storage.
+// It is meant to be registered as a storage.ChangeHook.
+func (c *Cache) Invalidate(alias string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	if el, ok := c.items[alias]; ok {
+		c.removeElement(el)
+	}
+	// callers arriving after the change must not join a lookup started before it
+	c.group.Forget(alias)
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *Cache) load(ctx context.Context, alias string) (result, error) {
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+
+	// the lookup is shared by all waiting callers, so it must not be canceled
+	// when the caller that started it goes away; storage timeouts still apply
+	link, err := c.next.GetLink(context.WithoutCancel(ctx), alias)
+
+	var r result
+	switch {
+	case errors.Is(err, storage.ErrURLNotFound):
+		if c.opts.NegativeTTL <= 0 {
+			return r, nil
+		}
+		c.set(version, entry{alias: alias, expires: time.Now().Add(c.opts.NegativeTTL)})
+	case err != nil:
+		return r, err
+	default:
+		r = result{link: link, found: true}
+		c.set(version, entry{alias: alias, link: link, found: true, expires: time.Now().Add(c.opts.TTL)})
+	}
+
+	return r, nil
+}
+
+func (c *Cache) get(alias string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[alias]
+	if !ok {
+		return entry{}, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.removeElement(el)
+		return entry{}, false
+	}
+
+	c.lru.MoveToFront(el)
+
+	return *e, true
+}
+
+// set stores e unless the cache was invalidated after the lookup that produced it had started.
+func (c *Cache) set(version uint64, e entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.opts.Size <= 0 || version != c.version {
+		return
+	}
+
+	if el, ok := c.items[e.alias]; ok {
+		el.Value = &e
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.items[e.alias] = c.lru.PushFront(&e)
+
+	for c.lru.Len() > c.opts.Size {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*entry).alias)
+}
@@ -0,0 +1,232 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/cache"
+)
+
+type fakeGetter struct {
+	mu    sync.Mutex
+	urls  map[string]string
+	err   error
+	calls atomic.Int32
+	wait  chan struct{} // blocks lookups until closed when set
+}
+
+func newFakeGetter(urls map[string]string) *fakeGetter {
+	return &fakeGetter{urls: urls}
+}
+
+func (f *fakeGetter) GetLink(_ context.Context, alias string) (storage.Link, error) {
+	f.calls.Add(1)
+	if f.wait != nil {
+		<-f.wait
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return storage.Link{}, f.err
+	}
+	u, ok := f.urls[alias]
+	if !ok {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+	return storage.Link{Alias: alias, URL: u}, nil
+}
+
+func (f *fakeGetter) set(alias, u string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.urls[alias] = u
+}
+
+func TestCache_Hit(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"abcd": "https://example.com"})
+	c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		link, err := c.GetLink(context.Background(), "abcd")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com", link.URL)
+	}
+
+	require.EqualValues(t, 1, getter.calls.Load())
+}
+
+func TestCache_TTL(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"abcd": "https://example.com"})
+	c := cache.New(getter, cache.Options{Size: 10, TTL: 10 * time.Millisecond})
+
+	_, err := c.GetLink(context.Background(), "abcd")
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.GetLink(context.Background(), "abcd")
+	require.NoError(t, err)
+
+	require.EqualValues(t, 2, getter.calls.Load())
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	cases := []struct {
+		name        string
+		negativeTTL time.Duration
+		calls       int32
+	}{
+		{name: "Enabled", negativeTTL: time.Minute, calls: 1},
+		{name: "Disabled", negativeTTL: 0, calls: 3},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			getter := newFakeGetter(map[string]string{})
+			c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: tc.negativeTTL})
+
+			for i := 0; i < 3; i++ {
+				_, err := c.GetLink(context.Background(), "none")
+				require.ErrorIs(t, err, storage.ErrURLNotFound)
+			}
+
+			require.Equal(t, tc.calls, getter.calls.Load())
+		})
+	}
+}
+
+func TestCache_ErrorsAreNotCached(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"abcd": "https://example.com"})
+	getter.err = errors.New("disk I/O error")
+	c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+
+	_, err := c.GetLink(context.Background(), "abcd")
+	require.Error(t, err)
+
+	getter.err = nil
+
+	link, err := c.GetLink(context.Background(), "abcd")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", link.URL)
+}
+
+func TestCache_LRUEviction(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"a": "https://a.com", "b": "https://b.com", "c": "https://c.com"})
+	c := cache.New(getter, cache.Options{Size: 2, TTL: time.Minute})
+	ctx := context.Background()
+
+	for _, alias := range []string{"a", "b", "a", "c"} {
+		_, err := c.GetLink(ctx, alias)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, c.Len())
+	require.EqualValues(t, 3, getter.calls.Load())
+
+	// "b" was the least recently used entry, so it must have been evicted
+	_, err := c.GetLink(ctx, "a")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, getter.calls.Load())
+
+	_, err = c.GetLink(ctx, "b")
+	require.NoError(t, err)
+	require.EqualValues(t, 4, getter.calls.Load())
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	getter := newFakeGetter(map[string]string{})
+	c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+	ctx := context.Background()
+
+	_, err := c.GetLink(ctx, "abcd")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	getter.set("abcd", "https://example.com")
+	c.Invalidate("abcd")
+
+	link, err := c.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", link.URL)
+
+	getter.set("abcd", "https://example.org")
+	c.Invalidate("abcd")
+
+	link, err = c.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.org", link.URL)
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"abcd": "https://example.com"})
+	getter.wait = make(chan struct{})
+	c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute})
+
+	const callers = 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			link, err := c.GetLink(context.Background(), "abcd")
+			require.NoError(t, err)
+			require.Equal(t, "https://example.com", link.URL)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return getter.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(getter.wait)
+	wg.Wait()
+
+	require.EqualValues(t, 1, getter.calls.Load())
+}
+
+func TestCache_InvalidateDuringLoad(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"abcd": "https://example.com"})
+	getter.wait = make(chan struct{})
+	c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.GetLink(context.Background(), "abcd")
+	}()
+
+	require.Eventually(t, func() bool { return getter.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// the value being loaded is stale now and must not be stored
+	c.Invalidate("abcd")
+	close(getter.wait)
+	<-done
+
+	require.Equal(t, 0, c.Len())
+}
+
+func TestCache_CallerCanceled(t *testing.T) {
+	getter := newFakeGetter(map[string]string{"abcd": "https://example.com"})
+	getter.wait = make(chan struct{})
+	defer close(getter.wait)
+
+	c := cache.New(getter, cache.Options{Size: 10, TTL: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetLink(ctx, "abcd")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"url-shortener/internal/storage"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// URLGetter is the storage the cache reads through to.
+type URLGetter interface {
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+}
+
+// CacheOptions configures the Cache.
+type CacheOptions struct {
+	KeyPrefix   string
+	TTL         time.Duration // how long a found link is cached
+	NegativeTTL time.Duration // how long an unknown alias is cached, zero disables negative caching
+	Channel     string        // pub/sub channel used to broadcast invalidations to other replicas
+}
+
+// Cache is a read-through cache shared by all replicas.
+//
+// Every alias has a version key which is incremented on invalidation. A lookup
+// stores its result only if the version did not change while it was loading,
+// so that a concurrent update can never be overwritten by a stale value.
+type Cache struct {
+	client *goredis.Client
+	next   URLGetter
+	opts   CacheOptions
+}
+
+// notFound marks a cached unknown alias; it can never be a valid JSON document.
+const notFound = "\x00"
+
+// setIfVersion stores ARGV[2] in KEYS[1] for ARGV[3] milliseconds
+// if the version in KEYS[2] still equals ARGV[1].
+var setIfVersion = goredis.NewScript(`
+local version = redis.call("GET", KEYS[2]) or "0"
+if version == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// NewCache creates a Cache reading through to next.
+func NewCache(client *goredis.Client, next URLGetter, opts CacheOptions) *Cache {
+	return &Cache{
+		client: client,
+		next:   next,
+		opts:   opts,
+	}
+}
+
+// GetLink returns the link for alias from Redis or loads it from the underlying storage.
+func (c *Cache) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.redis.Cache.GetLink"
+
+	res, err := c.client.MGet(ctx, c.valueKey(alias), c.versionKey(alias)).Result()
+	if err != nil {
+		// Redis being unavailable must not break redirects
+		return c.next.GetLink(ctx, alias)
+	}
+
+	if cached, ok := res[0].(string); ok {
+		if cached == notFound {
+			return storage.Link{}, storage.ErrURLNotFound
+		}
+
+		var link storage.Link
+		if err := json.Unmarshal([]byte(cached), &link); err == nil {
+			return link, nil
+		}
+		// an undecodable value is treated as a miss and overwritten below
+	}
+
+	version := "0"
+	if v, ok := res[1].(string); ok {
+		version = v
+	}
+
+	link, err := c.next.GetLink(ctx, alias)
+	switch {
+	case errors.Is(err, storage.ErrURLNotFound):
+		if c.opts.NegativeTTL > 0 {
+			c.fill(ctx, alias, version, notFound, c.opts.NegativeTTL)
+		}
+		return storage.Link{}, err
+	case err != nil:
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if data, err := json.Marshal(link); err == nil {
+		c.fill(ctx, alias, version, string(data), c.opts.TTL)
+	}
+
+	return link, nil
+}
+
+func (c *Cache) fill(ctx context.Context, alias, version, value string, ttl time.Duration) {
+	keys := []string{c.valueKey(alias), c.versionKey(alias)}
+
+	// a failed fill only costs another storage lookup
+	_ = setIfVersion.Run(ctx, c.client, keys, version, value, ttl.Milliseconds()).Err()
+}
+
+// Invalidate drops the cached value for alias and notifies other replicas.
+func (c *Cache) Invalidate(ctx context.Context, alias string) error {
+	const op = "storage.redis.Cache.Invalidate"
+
+	_, err := c.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Incr(ctx, c.versionKey(alias))
+		// the version only has to outlive the lookups that may still be running
+		pipe.Expire(ctx, c.versionKey(alias), c.versionTTL())
+		pipe.Del(ctx, c.valueKey(alias))
+		pipe.Publish(ctx, c.opts.Channel, alias)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Subscribe calls hook for every alias invalidated by any replica, including this one.
+// It blocks until ctx is canceled.
+func (c *Cache) Subscribe(ctx context.Context, hook storage.ChangeHook) error {
+	const op = "storage.redis.Cache.Subscribe"
+
+	sub := c.client.Subscribe(ctx, c.opts.Channel)
+	defer func() { _ = sub.Close() }()
+
+	// wait for the subscription to be confirmed, so that no invalidation is missed after Subscribe starts
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("%s: subscription closed", op)
+			}
+			hook(msg.Payload)
+		}
+	}
+}
+
+func (c *Cache) versionTTL() time.Duration {
+	return max(c.opts.TTL, c.opts.NegativeTTL) + time.Minute
+}
+
+func (c *Cache) valueKey(alias string) string {
+	return c.opts.KeyPrefix + "cache:" + alias
+}
+
+func (c *Cache) versionKey(alias string) string {
+	return c.opts.KeyPrefix + "cache-version:" + alias
+}
@@ -0,0 +1,164 @@
+package redis_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/storage"
+	redisStorage "url-shortener/internal/storage/redis"
+)
+
+type countingGetter struct {
+	next  redisStorage.URLGetter
+	calls atomic.Int32
+}
+
+func (g *countingGetter) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	g.calls.Add(1)
+	return g.next.GetLink(ctx, alias)
+}
+
+func newCache(t *testing.T) (*redisStorage.Cache, *redisStorage.Storage, *countingGetter) {
+	t.Helper()
+
+	client, _ := newClient(t)
+	prefix := testPrefix()
+
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: prefix})
+	getter := &countingGetter{next: s}
+	c := redisStorage.NewCache(client, getter, redisStorage.CacheOptions{
+		KeyPrefix:   prefix,
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+		Channel:     prefix + "invalidate",
+	})
+
+	return c, s, getter
+}
+
+func TestCache_ReadThrough(t *testing.T) {
+	c, s, getter := newCache(t)
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		link, err := c.GetLink(ctx, "abcd")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com", link.URL)
+	}
+
+	require.EqualValues(t, 1, getter.calls.Load())
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	c, s, getter := newCache(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetLink(ctx, "none")
+		require.ErrorIs(t, err, storage.ErrURLNotFound)
+	}
+	require.EqualValues(t, 1, getter.calls.Load())
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "none", URL: "https://example.com"})
+	require.NoError(t, err)
+	require.NoError(t, c.Invalidate(ctx, "none"))
+
+	link, err := c.GetLink(ctx, "none")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", link.URL)
+}
+
+func TestCache_TTL(t *testing.T) {
+	client, mr := newClient(t)
+	if mr == nil {
+		t.Skip("time can only be fast-forwarded with miniredis")
+	}
+
+	s := redisStorage.New(client, redisStorage.Options{})
+	getter := &countingGetter{next: s}
+	c := redisStorage.NewCache(client, getter, redisStorage.CacheOptions{TTL: time.Minute, Channel: "invalidate"})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com"})
+	require.NoError(t, err)
+
+	_, err = c.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+
+	mr.FastForward(2 * time.Minute)
+
+	_, err = c.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+
+	require.EqualValues(t, 2, getter.calls.Load())
+}
+
+func TestCache_StaleFillIsDiscarded(t *testing.T) {
+	client, _ := newClient(t)
+	prefix := testPrefix()
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: prefix})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com"})
+	require.NoError(t, err)
+
+	var c *redisStorage.Cache
+	// the alias is invalidated while the first lookup is still loading it
+	getter := getterFunc(func(ctx context.Context, alias string) (storage.Link, error) {
+		link, err := s.GetLink(ctx, alias)
+		require.NoError(t, c.Invalidate(ctx, alias))
+		return link, err
+	})
+	c = redisStorage.NewCache(client, getter, redisStorage.CacheOptions{
+		KeyPrefix: prefix,
+		TTL:       time.Minute,
+		Channel:   prefix + "invalidate",
+	})
+
+	_, err = c.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+
+	exists, err := client.Exists(ctx, prefix+"cache:abcd").Result()
+	require.NoError(t, err)
+	require.Zero(t, exists)
+}
+
+func TestCache_Subscribe(t *testing.T) {
+	c, _, _ := newCache(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	invalidated := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Subscribe(ctx, func(alias string) { invalidated <- alias })
+	}()
+
+	// Subscribe confirms the subscription asynchronously, retry until the message is delivered
+	require.Eventually(t, func() bool {
+		require.NoError(t, c.Invalidate(context.Background(), "abcd"))
+		select {
+		case alias := <-invalidated:
+			return alias == "abcd"
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+type getterFunc func(ctx context.Context, alias string) (storage.Link, error)
+
+func (f getterFunc) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	return f(ctx, alias)
+}
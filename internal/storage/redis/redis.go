@@ -0,0 +1,1062 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/storage"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Options configures the connection to Redis.
+type Options struct {
+	Addr         string
+	Username     string
+	Password     string
+	DB           int
+	KeyPrefix    string        // prepended to every key, so that several services can share a database
+	ReadTimeout  time.Duration // deadline for a single read command, zero means no deadline
+	WriteTimeout time.Duration // deadline for a single write command, zero means no deadline
+}
+
+// NewClient connects to Redis and verifies the connection.
+func NewClient(ctx context.Context, opts Options) (*goredis.Client, error) {
+	const op = "storage.redis.NewClient"
+
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     opts.Addr,
+		Username: opts.Username,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return client, nil
+}
+
+// Storage keeps links in Redis. It implements the same methods as sqlite.Storage.
+//
+// Keys:
+//
+//	<prefix>alias:<alias>  -> link encoded as JSON, including its targeting rules
+//	<prefix>url:<url>      -> alias of a plain link to the URL
+//	<prefix>clicks:<alias> -> clicks left for links with a click limit
+//	<prefix>variant-clicks:<alias> -> hash of clicks per variant id
+//	<prefix>total-clicks:<alias> -> redirects of the link so far
+//	<prefix>tag:<tag>      -> set of aliases of the links with the tag
+//	<prefix>seq            -> last issued link id
+//	<prefix>rule-seq       -> last issued rule id
+//	<prefix>utm-presets    -> hash of UTM presets encoded as JSON by id
+//	<prefix>utm-preset-names -> hash of UTM preset ids by name
+//	<prefix>utm-preset-seq -> last issued UTM preset id
+type Storage struct {
+	client       *goredis.Client
+	prefix       string
+	hooks        []storage.ChangeHook
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// savePreset stores the preset ARGV[3] under id ARGV[1] unless the name ARGV[2] is taken.
+var savePreset = goredis.NewScript(`
+if redis.call("HSETNX", KEYS[2], ARGV[2], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// deletePreset removes the preset with id ARGV[1] together with its name.
+// It returns 0 when there is no such preset.
+var deletePreset = goredis.NewScript(`
+local data = redis.call("HGET", KEYS[1], ARGV[1])
+if not data then
+	return 0
+end
+redis.call("HDEL", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], cjson.decode(data)["name"])
+return 1
+`)
+
+// saveLink stores the link ARGV[1] under KEYS[1] unless the alias is taken, together with its
+// URL index KEYS[2] when ARGV[4] is "1", its click counter KEYS[3] set to ARGV[3] when ARGV[3] is positive,
+// and the alias ARGV[2] in the tag sets KEYS[6..]. The redirect counts KEYS[4] and KEYS[5] left by
+// an earlier link with the alias are cleared. It returns 0 when the alias is taken.
+var saveLink = goredis.NewScript(`
+if redis.call("SETNX", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("DEL", KEYS[4], KEYS[5])
+if ARGV[4] == "1" then
+	redis.call("SETNX", KEYS[2], ARGV[2])
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[3], ARGV[3])
+end
+for i = 6, #KEYS do
+	redis.call("SADD", KEYS[i], ARGV[2])
+end
+return 1
+`)
+
+// countClicks adds redirects to links that still exist. KEYS come in triples of the link, its total
+// and its variant counts, ARGV in pairs of the count and the variant id, which is "0" for none.
+var countClicks = goredis.NewScript(`
+for i = 1, #KEYS, 3 do
+	local n = (i - 1) / 3 * 2 + 1
+	if redis.call("EXISTS", KEYS[i]) == 1 then
+		redis.call("INCRBY", KEYS[i + 1], ARGV[n])
+		if ARGV[n + 1] ~= "0" then
+			redis.call("HINCRBY", KEYS[i + 2], ARGV[n + 1], ARGV[n])
+		end
+	end
+end
+return 1
+`)
+
+// deleteIfEquals deletes KEYS[1] only if it still holds ARGV[1].
+var deleteIfEquals = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// consumeClick decrements KEYS[1] if it is positive and returns the new value.
+// It returns -1 when no clicks are left and -2 when the alias in KEYS[2] does not exist.
+var consumeClick = goredis.NewScript(`
+local left = tonumber(redis.call("GET", KEYS[1]) or "0")
+if left > 0 then
+	return redis.call("DECR", KEYS[1])
+end
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return -1
+end
+return -2
+`)
+
+// New creates a Storage on top of client. The client is owned by the caller.
+func New(client *goredis.Client, opts Options) *Storage {
+	return &Storage{
+		client:       client,
+		prefix:       opts.KeyPrefix,
+		readTimeout:  opts.ReadTimeout,
+		writeTimeout: opts.WriteTimeout,
+	}
+}
+
+// Close is a no-op, the client is closed by its owner.
+func (s *Storage) Close() error {
+	return nil
+}
+
+// Ping verifies that Redis is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.redis.Ping"
+
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CheckMigrations always succeeds, the Redis storage has no schema.
+func (s *Storage) CheckMigrations(_ context.Context) error {
+	return nil
+}
+
+// OnChange registers a hook fired after a link is saved or deleted.
+// Hooks must be registered before the storage is used concurrently.
+func (s *Storage) OnChange(hook storage.ChangeHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *Storage) notifyChange(alias string) {
+	for _, hook := range s.hooks {
+		hook(alias)
+	}
+}
+
+// AliasExists checks whether the specified alias exists.
+func (s *Storage) AliasExists(ctx context.Context, alias string) (bool, error) {
+	const op = "storage.redis.AliasExists"
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	n, err := s.client.Exists(ctx, s.aliasKey(alias)).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n > 0, nil
+}
+
+// ExistingAliases returns which of aliases are taken, in one round trip.
+func (s *Storage) ExistingAliases(ctx context.Context, aliases []string) ([]string, error) {
+	const op = "storage.redis.ExistingAliases"
+
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	cmds := make([]*goredis.IntCmd, len(aliases))
+	_, err := s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, alias := range aliases {
+			cmds[i] = pipe.Exists(ctx, s.aliasKey(alias))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var existing []string
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			existing = append(existing, aliases[i])
+		}
+	}
+
+	return existing, nil
+}
+
+// URLExists checks whether a plain link to the specified URL exists.
+func (s *Storage) URLExists(ctx context.Context, urlToCheck string) (bool, error) {
+	const op = "storage.redis.URLExists"
+
+	_, err := s.GetAliasByURL(ctx, urlToCheck)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// GetAliasByURL retrieves the alias of the plain link to a given URL.
+func (s *Storage) GetAliasByURL(ctx context.Context, urlToFind string) (string, error) {
+	const op = "storage.redis.GetAliasByURL"
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	alias, err := s.client.Get(ctx, s.urlKey(urlToFind)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the link may have got settings or metadata after it was indexed
+	data, err := s.client.Get(ctx, s.aliasKey(alias)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	link, err := decodeLink(data, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !link.Plain() {
+		return "", storage.ErrURLNotFound
+	}
+
+	return alias, nil
+}
+
+// SaveLink adds a new link. The alias is reserved atomically with SETNX,
+// so that concurrent saves of the same alias cannot overwrite each other.
+func (s *Storage) SaveLink(ctx context.Context, link storage.Link) (int64, error) {
+	const op = "storage.redis.SaveLink"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	id, err := s.client.Incr(ctx, s.key("seq")).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%s: issue id: %w", op, err)
+	}
+	link.ID = id
+	link.ClicksLeft = link.MaxClicks
+	link.NotBefore = link.NotBefore.UTC()
+	link.NotAfter = link.NotAfter.UTC()
+	// variant ids only have to be unique within their link
+	link.Variants = append([]storage.Variant(nil), link.Variants...)
+	for i := range link.Variants {
+		link.Variants[i].ID = int64(i + 1)
+		link.Variants[i].Clicks = 0
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the alias and its click counter appear at once, a limited link is never seen without clicks left
+	keys := []string{s.aliasKey(link.Alias), s.urlKey(link.URL), s.clicksKey(link.Alias),
+		s.totalClicksKey(link.Alias), s.variantClicksKey(link.Alias)}
+	for _, tag := range link.Tags {
+		keys = append(keys, s.tagKey(tag))
+	}
+	var maxClicks int64
+	if link.Limited() {
+		maxClicks = link.MaxClicks
+	}
+
+	// only plain links are handed out again for the same URL
+	indexURL := "0"
+	if link.Plain() {
+		indexURL = "1"
+	}
+
+	saved, err := saveLink.Run(ctx, s.client, keys, data, link.Alias, maxClicks, indexURL).Int()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if saved == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	s.notifyChange(link.Alias)
+
+	return id, nil
+}
+
+// GetLink retrieves the link with the given alias.
+func (s *Storage) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.redis.GetLink"
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	res, err := s.client.MGet(ctx, s.aliasKey(alias), s.clicksKey(alias), s.totalClicksKey(alias)).Result()
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, ok := res[0].(string)
+	if !ok {
+		return storage.Link{}, storage.ErrURLNotFound
+	}
+
+	link, err := decodeLink(data, res[1], res[2])
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(link.Variants) > 0 {
+		clicks, err := s.client.HGetAll(ctx, s.variantClicksKey(alias)).Result()
+		if err != nil {
+			return storage.Link{}, fmt.Errorf("%s: get variant clicks: %w", op, err)
+		}
+		for i := range link.Variants {
+			link.Variants[i].Clicks, _ = strconv.ParseInt(clicks[strconv.FormatInt(link.Variants[i].ID, 10)], 10, 64)
+		}
+	}
+
+	return link, nil
+}
+
+// decodeLink decodes a stored link document, left and clicks are the values of its counter keys.
+func decodeLink(data string, left, clicks any) (storage.Link, error) {
+	var link storage.Link
+	if err := json.Unmarshal([]byte(data), &link); err != nil {
+		return storage.Link{}, fmt.Errorf("decode link: %w", err)
+	}
+
+	// the stored document keeps the initial counts, the current ones live in their own keys
+	link.ClicksLeft = 0
+	if v, ok := left.(string); ok {
+		link.ClicksLeft, _ = strconv.ParseInt(v, 10, 64)
+	}
+	link.Clicks = 0
+	if v, ok := clicks.(string); ok {
+		link.Clicks, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	return link, nil
+}
+
+// ListLinks returns the links matching filter ordered by ID, without their variant clicks.
+// Links without tags are found by scanning the keyspace.
+func (s *Storage) ListLinks(ctx context.Context, filter storage.LinkFilter) ([]storage.Link, error) {
+	const op = "storage.redis.ListLinks"
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	var aliases []string
+	var err error
+	if len(filter.Tags) > 0 {
+		keys := make([]string, len(filter.Tags))
+		for i, tag := range filter.Tags {
+			keys[i] = s.tagKey(tag)
+		}
+		aliases, err = s.client.SInter(ctx, keys...).Result()
+	} else {
+		aliases, err = s.scanAliases(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := strings.ToLower(filter.Query)
+
+	var links []storage.Link
+	for start := 0; start < len(aliases); start += listBatch {
+		batch := aliases[start:min(start+listBatch, len(aliases))]
+
+		keys := make([]string, 0, 3*len(batch))
+		for _, alias := range batch {
+			keys = append(keys, s.aliasKey(alias), s.clicksKey(alias), s.totalClicksKey(alias))
+		}
+		res, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for i := 0; i < len(res); i += 3 {
+			data, ok := res[i].(string)
+			if !ok {
+				continue // deleted in the meantime
+			}
+			link, err := decodeLink(data, res[i+1], res[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			if filter.Broken && link.Health.Failures == 0 {
+				continue
+			}
+			if query == "" || matchesQuery(link, query) {
+				links = append(links, link)
+			}
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+
+	if filter.Offset >= len(links) {
+		return nil, nil
+	}
+	links = links[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(links) {
+		links = links[:filter.Limit]
+	}
+
+	return links, nil
+}
+
+// listBatch is the number of links ListLinks reads with a single MGET.
+const listBatch = 100
+
+func matchesQuery(link storage.Link, query string) bool {
+	for _, field := range []string{link.Alias, link.URL, link.Title, link.Description} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanAliases returns the aliases of all links.
+func (s *Storage) scanAliases(ctx context.Context) ([]string, error) {
+	prefix := s.aliasKey("")
+
+	var aliases []string
+	iter := s.client.Scan(ctx, 0, globEscaper.Replace(prefix)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		aliases = append(aliases, strings.TrimPrefix(iter.Val(), prefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scan aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// globEscaper escapes the special characters of SCAN patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// UpdateMeta replaces the metadata of the link with the given alias.
+func (s *Storage) UpdateMeta(ctx context.Context, alias string, meta storage.Meta) error {
+	const op = "storage.redis.UpdateMeta"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	err := s.updateLink(ctx, alias, func(link *storage.Link) error {
+		link.Meta = meta
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrURLNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+// SavePageMeta stores what was fetched from the destination of the link with the given alias.
+func (s *Storage) SavePageMeta(ctx context.Context, alias string, page storage.PageMeta) error {
+	const op = "storage.redis.SavePageMeta"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	page.FetchedAt = page.FetchedAt.UTC()
+
+	err := s.updateLink(ctx, alias, func(link *storage.Link) error {
+		link.Page = page
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrURLNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+// SaveHealth stores the result of a destination check of the link with the given alias.
+func (s *Storage) SaveHealth(ctx context.Context, alias string, health storage.LinkHealth) error {
+	const op = "storage.redis.SaveHealth"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	health.CheckedAt = health.CheckedAt.UTC()
+
+	var flipped bool
+	err := s.modifyLink(ctx, alias, func(link *storage.Link) error {
+		flipped = link.Health.Fallback != health.Fallback
+		link.Health = health
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrURLNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	// only the fallback changes where redirects go, cached links may keep stale results otherwise
+	if flipped {
+		s.notifyChange(alias)
+	}
+
+	return nil
+}
+
+// CountClicks adds counted redirects to links and their variants at once.
+// Clicks of links that no longer exist are dropped.
+func (s *Storage) CountClicks(ctx context.Context, clicks []storage.Clicks) error {
+	const op = "storage.redis.CountClicks"
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, 3*len(clicks))
+	args := make([]any, 0, 2*len(clicks))
+	for _, c := range clicks {
+		keys = append(keys, s.aliasKey(c.Alias), s.totalClicksKey(c.Alias), s.variantClicksKey(c.Alias))
+		args = append(args, c.Count, c.VariantID)
+	}
+
+	if err := countClicks.Run(ctx, s.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeClick atomically takes one click from a link with a click limit and
+// returns the number of clicks left after it. It returns storage.ErrExhausted
+// when no clicks are left, which is also the case for links without a limit.
+func (s *Storage) ConsumeClick(ctx context.Context, alias string) (int64, error) {
+	const op = "storage.redis.ConsumeClick"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	left, err := consumeClick.Run(ctx, s.client, []string{s.clicksKey(alias), s.aliasKey(alias)}).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch left {
+	case -1:
+		return 0, storage.ErrExhausted
+	case -2:
+		return 0, storage.ErrURLNotFound
+	}
+
+	return left, nil
+}
+
+// DeleteURL removes a URL and its associated alias.
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
+	const op = "storage.redis.DeleteURL"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	data, err := s.client.GetDel(ctx, s.aliasKey(alias)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var link storage.Link
+	if err := json.Unmarshal(data, &link); err != nil {
+		return fmt.Errorf("%s: decode link: %w", op, err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, s.clicksKey(alias), s.variantClicksKey(alias), s.totalClicksKey(alias))
+		for _, tag := range link.Tags {
+			pipe.SRem(ctx, s.tagKey(tag), alias)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: delete clicks and tags: %w", op, err)
+	}
+
+	// the URL may have been saved again under another alias, keep its index then
+	if err := deleteIfEquals.Run(ctx, s.client, []string{s.urlKey(link.URL)}, alias).Err(); err != nil {
+		return fmt.Errorf("%s: delete url index: %w", op, err)
+	}
+
+	s.notifyChange(alias)
+
+	return nil
+}
+
+// AddRule adds a targeting rule to the link with the given alias and returns it with
+// its ID and position set. A rule without a position is placed after the existing ones.
+func (s *Storage) AddRule(ctx context.Context, alias string, rule storage.Rule) (storage.Rule, error) {
+	const op = "storage.redis.AddRule"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	id, err := s.client.Incr(ctx, s.key("rule-seq")).Result()
+	if err != nil {
+		return storage.Rule{}, fmt.Errorf("%s: issue id: %w", op, err)
+	}
+	rule.ID = id
+
+	err = s.updateLink(ctx, alias, func(link *storage.Link) error {
+		if rule.Position <= 0 {
+			rule.Position = 1
+			if n := len(link.Rules); n > 0 {
+				rule.Position = link.Rules[n-1].Position + 1
+			}
+		}
+		link.Rules = append(link.Rules, rule)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrURLNotFound) {
+			return storage.Rule{}, err
+		}
+		return storage.Rule{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rule, nil
+}
+
+// UpdateRule replaces the rule with rule.ID of the link with the given alias.
+func (s *Storage) UpdateRule(ctx context.Context, alias string, rule storage.Rule) error {
+	const op = "storage.redis.UpdateRule"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	err := s.updateLink(ctx, alias, func(link *storage.Link) error {
+		for i := range link.Rules {
+			if link.Rules[i].ID == rule.ID {
+				link.Rules[i] = rule
+				return nil
+			}
+		}
+		return storage.ErrRuleNotFound
+	})
+	if err != nil && !errors.Is(err, storage.ErrURLNotFound) && !errors.Is(err, storage.ErrRuleNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+// DeleteRule removes the rule with the given ID from the link with the given alias.
+func (s *Storage) DeleteRule(ctx context.Context, alias string, id int64) error {
+	const op = "storage.redis.DeleteRule"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	err := s.updateLink(ctx, alias, func(link *storage.Link) error {
+		for i := range link.Rules {
+			if link.Rules[i].ID == id {
+				link.Rules = append(link.Rules[:i], link.Rules[i+1:]...)
+				return nil
+			}
+		}
+		return storage.ErrRuleNotFound
+	})
+	if err != nil && !errors.Is(err, storage.ErrURLNotFound) && !errors.Is(err, storage.ErrRuleNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return err
+}
+
+// SaveUTMPreset adds a new UTM preset and returns its ID.
+func (s *Storage) SaveUTMPreset(ctx context.Context, preset storage.UTMPreset) (int64, error) {
+	const op = "storage.redis.SaveUTMPreset"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	id, err := s.client.Incr(ctx, s.key("utm-preset-seq")).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%s: issue id: %w", op, err)
+	}
+	preset.ID = id
+
+	data, err := json.Marshal(preset)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	saved, err := savePreset.Run(ctx, s.client, []string{s.key("utm-presets"), s.key("utm-preset-names")},
+		id, preset.Name, data).Int()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if saved == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrPresetExists)
+	}
+
+	return id, nil
+}
+
+// GetUTMPreset retrieves the UTM preset with the given ID.
+func (s *Storage) GetUTMPreset(ctx context.Context, id int64) (storage.UTMPreset, error) {
+	const op = "storage.redis.GetUTMPreset"
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	data, err := s.client.HGet(ctx, s.key("utm-presets"), strconv.FormatInt(id, 10)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return storage.UTMPreset{}, storage.ErrPresetNotFound
+	}
+	if err != nil {
+		return storage.UTMPreset{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var preset storage.UTMPreset
+	if err := json.Unmarshal(data, &preset); err != nil {
+		return storage.UTMPreset{}, fmt.Errorf("%s: decode preset: %w", op, err)
+	}
+
+	return preset, nil
+}
+
+// ListUTMPresets returns all UTM presets ordered by ID.
+func (s *Storage) ListUTMPresets(ctx context.Context) ([]storage.UTMPreset, error) {
+	const op = "storage.redis.ListUTMPresets"
+
+	ctx, cancel := withTimeout(ctx, s.readTimeout)
+	defer cancel()
+
+	all, err := s.client.HVals(ctx, s.key("utm-presets")).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	presets := make([]storage.UTMPreset, 0, len(all))
+	for _, data := range all {
+		var preset storage.UTMPreset
+		if err := json.Unmarshal([]byte(data), &preset); err != nil {
+			return nil, fmt.Errorf("%s: decode preset: %w", op, err)
+		}
+		presets = append(presets, preset)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].ID < presets[j].ID })
+
+	return presets, nil
+}
+
+// DeleteUTMPreset removes the UTM preset with the given ID. Links created with it keep their tags.
+func (s *Storage) DeleteUTMPreset(ctx context.Context, id int64) error {
+	const op = "storage.redis.DeleteUTMPreset"
+
+	ctx, cancel := withTimeout(ctx, s.writeTimeout)
+	defer cancel()
+
+	deleted, err := deletePreset.Run(ctx, s.client, []string{s.key("utm-presets"), s.key("utm-preset-names")}, id).Int()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if deleted == 0 {
+		return storage.ErrPresetNotFound
+	}
+
+	return nil
+}
+
+// maxUpdateAttempts bounds the retries of updateLink when the link keeps changing under it.
+const maxUpdateAttempts = 16
+
+// NormalizeAliases renames the links whose aliases change under normalize and returns how many
+// were renamed. Nothing is renamed when several aliases would become the same.
+func (s *Storage) NormalizeAliases(ctx context.Context, normalize func(alias string) string) (int, error) {
+	const op = "storage.redis.NormalizeAliases"
+
+	aliases, err := s.scanAliases(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	renames, err := storage.AliasRenames(aliases, normalize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for from, to := range renames {
+		if err := s.renameLink(ctx, from, to); err != nil {
+			return 0, fmt.Errorf("%s: rename %q: %w", op, from, err)
+		}
+		s.notifyChange(from)
+	}
+
+	return len(renames), nil
+}
+
+// renameLink moves the link with alias from and its counters to alias to in an optimistic transaction.
+func (s *Storage) renameLink(ctx context.Context, from, to string) error {
+	key, newKey := s.aliasKey(from), s.aliasKey(to)
+	counters := map[string]string{
+		s.clicksKey(from):        s.clicksKey(to),
+		s.variantClicksKey(from): s.variantClicksKey(to),
+		s.totalClicksKey(from):   s.totalClicksKey(to),
+	}
+
+	rename := func(tx *goredis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			// deleted in the meantime
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n, err := tx.Exists(ctx, newKey).Result(); err != nil || n > 0 {
+			if err == nil {
+				err = fmt.Errorf("%w: %s", storage.ErrAliasCollision, to)
+			}
+			return err
+		}
+
+		var link storage.Link
+		if err := json.Unmarshal(data, &link); err != nil {
+			return fmt.Errorf("decode link: %w", err)
+		}
+		link.Alias = to
+		if data, err = json.Marshal(link); err != nil {
+			return err
+		}
+
+		var existing []string
+		for k := range counters {
+			n, err := tx.Exists(ctx, k).Result()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				existing = append(existing, k)
+			}
+		}
+
+		urlAlias, err := tx.Get(ctx, s.urlKey(link.URL)).Result()
+		if err != nil && !errors.Is(err, goredis.Nil) {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Set(ctx, newKey, data, 0)
+			pipe.Del(ctx, key)
+			for _, k := range existing {
+				pipe.Rename(ctx, k, counters[k])
+			}
+			for _, tag := range link.Tags {
+				pipe.SRem(ctx, s.tagKey(tag), from)
+				pipe.SAdd(ctx, s.tagKey(tag), to)
+			}
+			if urlAlias == from {
+				pipe.Set(ctx, s.urlKey(link.URL), to, 0)
+			}
+			return nil
+		})
+		return err
+	}
+
+	watched := []string{key, newKey}
+	for k := range counters {
+		watched = append(watched, k)
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, rename, watched...)
+		if errors.Is(err, goredis.TxFailedErr) {
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("link %q kept changing during rename", from)
+}
+
+// updateLink is modifyLink notifying the hooks.
+func (s *Storage) updateLink(ctx context.Context, alias string, fn func(link *storage.Link) error) error {
+	if err := s.modifyLink(ctx, alias, fn); err != nil {
+		return err
+	}
+
+	s.notifyChange(alias)
+
+	return nil
+}
+
+// modifyLink applies fn to the stored link document in an optimistic transaction and keeps
+// its rules ordered by position, its tags indexed and its URL index pointing at a plain link.
+// fn runs again when the transaction is retried.
+func (s *Storage) modifyLink(ctx context.Context, alias string, fn func(link *storage.Link) error) error {
+	key := s.aliasKey(alias)
+
+	update := func(tx *goredis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			return storage.ErrURLNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var link storage.Link
+		if err := json.Unmarshal(data, &link); err != nil {
+			return fmt.Errorf("decode link: %w", err)
+		}
+		oldTags := link.Tags
+		wasPlain := link.Plain()
+
+		if err := fn(&link); err != nil {
+			return err
+		}
+		sort.SliceStable(link.Rules, func(i, j int) bool {
+			if link.Rules[i].Position != link.Rules[j].Position {
+				return link.Rules[i].Position < link.Rules[j].Position
+			}
+			return link.Rules[i].ID < link.Rules[j].ID
+		})
+
+		if data, err = json.Marshal(link); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			for _, tag := range oldTags {
+				if !slices.Contains(link.Tags, tag) {
+					pipe.SRem(ctx, s.tagKey(tag), alias)
+				}
+			}
+			for _, tag := range link.Tags {
+				pipe.SAdd(ctx, s.tagKey(tag), alias)
+			}
+			// the next plain link saved for the URL takes the index over
+			switch plain := link.Plain(); {
+			case wasPlain && !plain:
+				deleteIfEquals.Eval(ctx, pipe, []string{s.urlKey(link.URL)}, alias)
+			case !wasPlain && plain:
+				pipe.SetNX(ctx, s.urlKey(link.URL), alias, 0)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, update, key)
+		if errors.Is(err, goredis.TxFailedErr) {
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("link %q kept changing during update", alias)
+}
+
+func (s *Storage) key(name string) string {
+	return s.prefix + name
+}
+
+func (s *Storage) aliasKey(alias string) string {
+	return s.prefix + "alias:" + alias
+}
+
+func (s *Storage) urlKey(u string) string {
+	return s.prefix + "url:" + u
+}
+
+func (s *Storage) clicksKey(alias string) string {
+	return s.prefix + "clicks:" + alias
+}
+
+func (s *Storage) variantClicksKey(alias string) string {
+	return s.prefix + "variant-clicks:" + alias
+}
+
+func (s *Storage) totalClicksKey(alias string) string {
+	return s.prefix + "total-clicks:" + alias
+}
+
+func (s *Storage) tagKey(tag string) string {
+	return s.prefix + "tag:" + tag
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
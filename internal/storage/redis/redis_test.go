@@ -0,0 +1,577 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/internal/lib/random"
+	"url-shortener/internal/storage"
+	redisStorage "url-shortener/internal/storage/redis"
+)
+
+// newClient connects to the server from REDIS_TEST_ADDR if it is set, or to an in-memory miniredis.
+// The returned miniredis is nil when a real server is used.
+func newClient(t *testing.T) (*goredis.Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	var mr *miniredis.Miniredis
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		mr = miniredis.RunT(t)
+		addr = mr.Addr()
+	}
+
+	client, err := redisStorage.NewClient(context.Background(), redisStorage.Options{Addr: addr})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client, mr
+}
+
+// testPrefix isolates the keys of a test when a shared server is used.
+func testPrefix() string {
+	return "test:" + random.NewRandomString(8) + ":"
+}
+
+func TestStorage_SaveGetDelete(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	id, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, id)
+
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.org"})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	link, err := s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Equal(t, storage.Link{ID: 1, Alias: "abcd", URL: "https://example.com"}, link)
+
+	exists, err := s.AliasExists(ctx, "abcd")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = s.URLExists(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	alias, err := s.GetAliasByURL(ctx, "https://example.com")
+	require.NoError(t, err)
+	require.Equal(t, "abcd", alias)
+
+	require.NoError(t, s.DeleteURL(ctx, "abcd"))
+
+	_, err = s.GetLink(ctx, "abcd")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = s.GetAliasByURL(ctx, "https://example.com")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	require.NoError(t, s.DeleteURL(ctx, "abcd"), "deleting a missing alias is not an error")
+}
+
+func TestStorage_ConcurrentAliasReservation(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+
+	const writers = 20
+
+	var saved atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := s.SaveLink(context.Background(), storage.Link{Alias: "same", URL: "https://example.com/" + random.NewRandomString(6)})
+			if err == nil {
+				saved.Add(1)
+				return
+			}
+			require.ErrorIs(t, err, storage.ErrURLExists)
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 1, saved.Load())
+}
+
+func TestStorage_OnChange(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	var changed []string
+	s.OnChange(func(alias string) { changed = append(changed, alias) })
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com"})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURL(ctx, "abcd"))
+
+	require.Equal(t, []string{"abcd", "abcd"}, changed)
+}
+
+func TestStorage_ConsumeClick(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "once", URL: "https://example.com", MaxClicks: 1})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "free", URL: "https://example.org"})
+	require.NoError(t, err)
+
+	link, err := s.GetLink(ctx, "once")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, link.ClicksLeft)
+
+	left, err := s.ConsumeClick(ctx, "once")
+	require.NoError(t, err)
+	require.Zero(t, left)
+
+	_, err = s.ConsumeClick(ctx, "once")
+	require.ErrorIs(t, err, storage.ErrExhausted)
+
+	link, err = s.GetLink(ctx, "once")
+	require.NoError(t, err)
+	require.Zero(t, link.ClicksLeft)
+
+	_, err = s.ConsumeClick(ctx, "free")
+	require.ErrorIs(t, err, storage.ErrExhausted)
+
+	_, err = s.ConsumeClick(ctx, "none")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_ConsumeClickWhileSaving(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	// a limited link is never seen without its clicks, it either does not exist yet or has them all
+	for i := 0; i < 50; i++ {
+		alias := "race" + strconv.Itoa(i)
+
+		saved := make(chan error, 1)
+		go func() {
+			_, err := s.SaveLink(ctx, storage.Link{Alias: alias, URL: "https://example.com", MaxClicks: 3})
+			saved <- err
+		}()
+
+		for {
+			left, err := s.ConsumeClick(ctx, alias)
+			if errors.Is(err, storage.ErrURLNotFound) {
+				continue
+			}
+			require.NoError(t, err)
+			require.EqualValues(t, 2, left)
+
+			break
+		}
+		require.NoError(t, <-saved)
+	}
+}
+
+func TestStorage_Rules(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com"})
+	require.NoError(t, err)
+
+	ios, err := s.AddRule(ctx, "abcd", storage.Rule{Platform: "ios", URL: "https://apps.apple.com/app"})
+	require.NoError(t, err)
+	require.Equal(t, 1, ios.Position)
+
+	android, err := s.AddRule(ctx, "abcd", storage.Rule{Platform: "android", URL: "https://play.google.com/app"})
+	require.NoError(t, err)
+	require.Equal(t, 2, android.Position)
+	require.NotEqual(t, ios.ID, android.ID)
+
+	android.Position = 0
+	require.NoError(t, s.UpdateRule(ctx, "abcd", android))
+
+	link, err := s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Equal(t, []storage.Rule{android, ios}, link.Rules)
+
+	require.NoError(t, s.DeleteRule(ctx, "abcd", android.ID))
+	require.ErrorIs(t, s.DeleteRule(ctx, "abcd", android.ID), storage.ErrRuleNotFound)
+	require.ErrorIs(t, s.UpdateRule(ctx, "abcd", android), storage.ErrRuleNotFound)
+
+	_, err = s.AddRule(ctx, "none", storage.Rule{Platform: "ios", URL: "https://apps.apple.com/app"})
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	link, err = s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Equal(t, []storage.Rule{ios}, link.Rules)
+}
+
+func TestStorage_Clicks(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com", CreatedAt: created})
+	require.NoError(t, err)
+
+	require.NoError(t, s.CountClicks(ctx, []storage.Clicks{{Alias: "abcd", Count: 1}, {Alias: "none", Count: 3}}))
+	require.NoError(t, s.CountClicks(ctx, []storage.Clicks{{Alias: "abcd", Count: 1}}))
+
+	link, err := s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.True(t, created.Equal(link.CreatedAt))
+	require.EqualValues(t, 2, link.Clicks)
+}
+
+func TestStorage_ClicksAfterDelete(t *testing.T) {
+	client, _ := newClient(t)
+	prefix := testPrefix()
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: prefix})
+	ctx := context.Background()
+
+	variants := []storage.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b", Weight: 1}}
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com", Variants: variants})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURL(ctx, "abcd"))
+
+	// clicks flushed after the link was deleted are dropped
+	require.NoError(t, s.CountClicks(ctx, []storage.Clicks{{Alias: "abcd", VariantID: 1, Count: 5}}))
+	n, err := client.Exists(ctx, prefix+"total-clicks:abcd", prefix+"variant-clicks:abcd").Result()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// counts left behind by an earlier link do not carry over to a new one
+	require.NoError(t, client.Set(ctx, prefix+"total-clicks:abcd", 7, 0).Err())
+	require.NoError(t, client.HSet(ctx, prefix+"variant-clicks:abcd", "1", 7).Err())
+
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com", Variants: variants})
+	require.NoError(t, err)
+
+	link, err := s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Zero(t, link.Clicks)
+	require.Zero(t, link.Variants[0].Clicks)
+}
+
+func TestStorage_Meta(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "go", URL: "https://go.dev", Meta: storage.Meta{
+		Title: "Go 100% home", Tags: []string{"go", "lang"},
+	}})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "rust", URL: "https://rust-lang.org", Meta: storage.Meta{
+		Description: "Rust", Notes: "for the newsletter", Tags: []string{"lang"},
+	}})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "plain", URL: "https://example.com"})
+	require.NoError(t, err)
+
+	link, err := s.GetLink(ctx, "rust")
+	require.NoError(t, err)
+	require.Equal(t, storage.Meta{Description: "Rust", Notes: "for the newsletter", Tags: []string{"lang"}}, link.Meta)
+
+	aliases := func(filter storage.LinkFilter) []string {
+		links, err := s.ListLinks(ctx, filter)
+		require.NoError(t, err)
+
+		var aliases []string
+		for _, link := range links {
+			aliases = append(aliases, link.Alias)
+		}
+		return aliases
+	}
+
+	require.Equal(t, []string{"go", "rust", "plain"}, aliases(storage.LinkFilter{}))
+	require.Equal(t, []string{"go", "rust"}, aliases(storage.LinkFilter{Tags: []string{"lang"}}))
+	require.Equal(t, []string{"go"}, aliases(storage.LinkFilter{Tags: []string{"lang", "go"}}))
+	require.Empty(t, aliases(storage.LinkFilter{Tags: []string{"go", "none"}}))
+	require.Equal(t, []string{"rust"}, aliases(storage.LinkFilter{Query: "RUST"}))
+	require.Equal(t, []string{"go"}, aliases(storage.LinkFilter{Query: "100%"}))
+	require.Empty(t, aliases(storage.LinkFilter{Query: "0%h"}))
+	require.Equal(t, []string{"rust"}, aliases(storage.LinkFilter{Limit: 1, Offset: 1}))
+
+	require.NoError(t, s.UpdateMeta(ctx, "go", storage.Meta{Title: "Go", Tags: []string{"go"}}))
+	require.ErrorIs(t, s.UpdateMeta(ctx, "none", storage.Meta{Title: "None"}), storage.ErrURLNotFound)
+
+	link, err = s.GetLink(ctx, "go")
+	require.NoError(t, err)
+	require.Equal(t, storage.Meta{Title: "Go", Tags: []string{"go"}}, link.Meta)
+	require.Equal(t, []string{"rust"}, aliases(storage.LinkFilter{Tags: []string{"lang"}}))
+
+	require.NoError(t, s.DeleteURL(ctx, "go"))
+	require.Empty(t, aliases(storage.LinkFilter{Tags: []string{"go"}}))
+}
+
+func TestStorage_PageMeta(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "go", URL: "https://go.dev", Meta: storage.Meta{Title: "Mine"}})
+	require.NoError(t, err)
+
+	page := storage.PageMeta{
+		Title:         "The Go Programming Language",
+		OGTitle:       "Go",
+		OGDescription: "Build simple, secure, scalable systems",
+		OGImage:       "https://go.dev/images/go-logo-white.svg",
+		FetchedAt:     time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+	require.NoError(t, s.SavePageMeta(ctx, "go", page))
+	require.ErrorIs(t, s.SavePageMeta(ctx, "none", page), storage.ErrURLNotFound)
+
+	link, err := s.GetLink(ctx, "go")
+	require.NoError(t, err)
+	require.True(t, page.FetchedAt.Equal(link.Page.FetchedAt))
+	link.Page.FetchedAt = page.FetchedAt
+	require.Equal(t, page, link.Page)
+	require.Equal(t, "Mine", link.Title)
+}
+
+func TestStorage_Health(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "go", URL: "https://go.dev", FallbackURL: "https://go.dev/doc"})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "ok", URL: "https://example.com"})
+	require.NoError(t, err)
+
+	var changed []string
+	s.OnChange(func(alias string) { changed = append(changed, alias) })
+
+	health := storage.LinkHealth{
+		Status:    http.StatusNotFound,
+		LatencyMS: 120,
+		Failures:  3,
+		Fallback:  true,
+		CheckedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+	require.NoError(t, s.SaveHealth(ctx, "go", health))
+	require.NoError(t, s.SaveHealth(ctx, "ok", storage.LinkHealth{Status: http.StatusOK, CheckedAt: health.CheckedAt}))
+	require.ErrorIs(t, s.SaveHealth(ctx, "none", health), storage.ErrURLNotFound)
+
+	link, err := s.GetLink(ctx, "go")
+	require.NoError(t, err)
+	require.Equal(t, "https://go.dev/doc", link.FallbackURL)
+	require.True(t, health.CheckedAt.Equal(link.Health.CheckedAt))
+	link.Health.CheckedAt = health.CheckedAt
+	require.Equal(t, health, link.Health)
+
+	broken, err := s.ListLinks(ctx, storage.LinkFilter{Broken: true})
+	require.NoError(t, err)
+	require.Len(t, broken, 1)
+	require.Equal(t, "go", broken[0].Alias)
+
+	// the hooks only hear about checks switching the fallback on or off
+	require.Equal(t, []string{"go"}, changed)
+	health.Failures = 4
+	require.NoError(t, s.SaveHealth(ctx, "go", health))
+	require.Equal(t, []string{"go"}, changed)
+	require.NoError(t, s.SaveHealth(ctx, "go", storage.LinkHealth{Status: http.StatusOK, CheckedAt: health.CheckedAt}))
+	require.Equal(t, []string{"go", "go"}, changed)
+}
+
+func TestStorage_NormalizeAliases(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "Promo", URL: "https://example.com", MaxClicks: 5, Meta: storage.Meta{Tags: []string{"spring"}}})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "docs", URL: "https://example.org"})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "Home", URL: "https://example.com/home"})
+	require.NoError(t, err)
+	require.NoError(t, s.CountClicks(ctx, []storage.Clicks{{Alias: "Promo", Count: 1}}))
+	_, err = s.ConsumeClick(ctx, "Promo")
+	require.NoError(t, err)
+
+	renamed, err := s.NormalizeAliases(ctx, strings.ToLower)
+	require.NoError(t, err)
+	require.Equal(t, 2, renamed)
+
+	_, err = s.GetLink(ctx, "Promo")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	link, err := s.GetLink(ctx, "promo")
+	require.NoError(t, err)
+	require.Equal(t, "promo", link.Alias)
+	require.EqualValues(t, 1, link.Clicks)
+	require.EqualValues(t, 4, link.ClicksLeft)
+
+	alias, err := s.GetAliasByURL(ctx, "https://example.com/home")
+	require.NoError(t, err)
+	require.Equal(t, "home", alias)
+
+	tagged, err := s.ListLinks(ctx, storage.LinkFilter{Tags: []string{"spring"}})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	require.Equal(t, "promo", tagged[0].Alias)
+
+	// normalized aliases are left alone
+	renamed, err = s.NormalizeAliases(ctx, strings.ToLower)
+	require.NoError(t, err)
+	require.Zero(t, renamed)
+
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "DOCS", URL: "https://example.net"})
+	require.NoError(t, err)
+
+	_, err = s.NormalizeAliases(ctx, strings.ToLower)
+	require.ErrorIs(t, err, storage.ErrAliasCollision)
+	require.ErrorContains(t, err, "DOCS, docs")
+
+	// nothing was renamed
+	_, err = s.GetLink(ctx, "DOCS")
+	require.NoError(t, err)
+}
+
+func TestStorage_PlainLinkByURL(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+	const target = "https://example.com"
+
+	// links with settings are never found by URL
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "secret", URL: target, PasswordHash: "hash"})
+	require.NoError(t, err)
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "once", URL: target, MaxClicks: 1})
+	require.NoError(t, err)
+
+	exists, err := s.URLExists(ctx, target)
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = s.GetAliasByURL(ctx, target)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "plain", URL: target})
+	require.NoError(t, err)
+
+	exists, err = s.URLExists(ctx, target)
+	require.NoError(t, err)
+	require.True(t, exists)
+	alias, err := s.GetAliasByURL(ctx, target)
+	require.NoError(t, err)
+	require.Equal(t, "plain", alias)
+
+	// nor are the ones that got metadata later
+	require.NoError(t, s.UpdateMeta(ctx, "plain", storage.Meta{Notes: "campaign"}))
+	_, err = s.GetAliasByURL(ctx, target)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// the next plain link is handed out for every later save instead
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "again", URL: target})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		alias, err = s.GetAliasByURL(ctx, target)
+		require.NoError(t, err)
+		require.Equal(t, "again", alias)
+	}
+}
+
+func TestStorage_ExistingAliases(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	for _, alias := range []string{"promo", "promo2"} {
+		_, err := s.SaveLink(ctx, storage.Link{Alias: alias, URL: "https://example.com/" + alias})
+		require.NoError(t, err)
+	}
+
+	existing, err := s.ExistingAliases(ctx, []string{"promo", "promo_2", "promo2", "deal"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"promo", "promo2"}, existing)
+
+	existing, err = s.ExistingAliases(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, existing)
+}
+
+func TestStorage_Variants(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	_, err := s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com", Variants: []storage.Variant{
+		{URL: "https://example.com/a", Weight: 1},
+		{URL: "https://example.com/b", Weight: 3},
+	}})
+	require.NoError(t, err)
+
+	link, err := s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Len(t, link.Variants, 2)
+
+	b := link.Variants[1]
+	require.NoError(t, s.CountClicks(ctx, []storage.Clicks{{Alias: "abcd", VariantID: b.ID, Count: 2}}))
+
+	link, err = s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Zero(t, link.Variants[0].Clicks)
+	require.EqualValues(t, 2, link.Variants[1].Clicks)
+	require.Equal(t, 3, link.Variants[1].Weight)
+
+	require.NoError(t, s.DeleteURL(ctx, "abcd"))
+	_, err = s.SaveLink(ctx, storage.Link{Alias: "abcd", URL: "https://example.com", Variants: []storage.Variant{
+		{URL: "https://example.com/a", Weight: 1},
+		{URL: "https://example.com/b", Weight: 1},
+	}})
+	require.NoError(t, err)
+
+	link, err = s.GetLink(ctx, "abcd")
+	require.NoError(t, err)
+	require.Zero(t, link.Variants[1].Clicks)
+}
+
+func TestStorage_UTMPresets(t *testing.T) {
+	client, _ := newClient(t)
+	s := redisStorage.New(client, redisStorage.Options{KeyPrefix: testPrefix()})
+	ctx := context.Background()
+
+	id, err := s.SaveUTMPreset(ctx, storage.UTMPreset{Name: "newsletter", Source: "newsletter", Medium: "email"})
+	require.NoError(t, err)
+
+	_, err = s.SaveUTMPreset(ctx, storage.UTMPreset{Name: "newsletter", Source: "other"})
+	require.ErrorIs(t, err, storage.ErrPresetExists)
+
+	preset, err := s.GetUTMPreset(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, storage.UTMPreset{ID: id, Name: "newsletter", Source: "newsletter", Medium: "email"}, preset)
+
+	presets, err := s.ListUTMPresets(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []storage.UTMPreset{preset}, presets)
+
+	require.NoError(t, s.DeleteUTMPreset(ctx, id))
+	require.ErrorIs(t, s.DeleteUTMPreset(ctx, id), storage.ErrPresetNotFound)
+
+	_, err = s.GetUTMPreset(ctx, id)
+	require.ErrorIs(t, err, storage.ErrPresetNotFound)
+
+	// the name can be used again
+	_, err = s.SaveUTMPreset(ctx, storage.UTMPreset{Name: "newsletter"})
+	require.NoError(t, err)
+}
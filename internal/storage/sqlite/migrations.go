@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"url-shortener/internal/storage"
+)
+
+// migrations are applied in order, each one in its own transaction.
+// The schema version stored in PRAGMA user_version is the number of applied migrations.
+var migrations = []string{
+	`
+	CREATE TABLE IF NOT EXISTS url(
+		id INTEGER PRIMARY KEY,
+		alias TEXT NOT NULL UNIQUE,
+		url TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
+	`,
+	`ALTER TABLE url ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';`,
+	`
+	ALTER TABLE url ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE url ADD COLUMN clicks_left INTEGER NOT NULL DEFAULT 0;
+	`,
+	`
+	ALTER TABLE url ADD COLUMN not_before DATETIME;
+	ALTER TABLE url ADD COLUMN not_after DATETIME;
+	`,
+	`
+	ALTER TABLE url ADD COLUMN query_mode TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN forward_path INTEGER NOT NULL DEFAULT 0;
+	`,
+	`ALTER TABLE url ADD COLUMN redirect_type TEXT NOT NULL DEFAULT '';`,
+	`
+	CREATE TABLE rule(
+		id INTEGER PRIMARY KEY,
+		url_id INTEGER NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		platform TEXT NOT NULL DEFAULT '',
+		language TEXT NOT NULL DEFAULT '',
+		country TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL);
+	CREATE INDEX idx_rule_url ON rule(url_id, position);
+	`,
+	`
+	CREATE TABLE variant(
+		id INTEGER PRIMARY KEY,
+		url_id INTEGER NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		weight INTEGER NOT NULL,
+		clicks INTEGER NOT NULL DEFAULT 0);
+	CREATE INDEX idx_variant_url ON variant(url_id);
+	`,
+	`
+	CREATE TABLE utm_preset(
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		source TEXT NOT NULL DEFAULT '',
+		medium TEXT NOT NULL DEFAULT '',
+		campaign TEXT NOT NULL DEFAULT '',
+		term TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL DEFAULT '');
+	`,
+	`
+	ALTER TABLE url ADD COLUMN created_at DATETIME;
+	ALTER TABLE url ADD COLUMN clicks INTEGER NOT NULL DEFAULT 0;
+	`,
+	`
+	CREATE TABLE url_meta(
+		url_id INTEGER PRIMARY KEY REFERENCES url(id) ON DELETE CASCADE,
+		title TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		notes TEXT NOT NULL DEFAULT '');
+	CREATE TABLE tag(
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE);
+	CREATE TABLE url_tag(
+		url_id INTEGER NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tag(id) ON DELETE CASCADE,
+		PRIMARY KEY(url_id, tag_id)) WITHOUT ROWID;
+	CREATE INDEX idx_url_tag_tag ON url_tag(tag_id);
+	`,
+	`
+	CREATE TABLE page_meta(
+		url_id INTEGER PRIMARY KEY REFERENCES url(id) ON DELETE CASCADE,
+		title TEXT NOT NULL DEFAULT '',
+		og_title TEXT NOT NULL DEFAULT '',
+		og_description TEXT NOT NULL DEFAULT '',
+		og_image TEXT NOT NULL DEFAULT '',
+		fetched_at DATETIME NOT NULL);
+	`,
+	`
+	ALTER TABLE url_meta ADD COLUMN og_title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_meta ADD COLUMN og_description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_meta ADD COLUMN og_image TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE url ADD COLUMN fallback_url TEXT NOT NULL DEFAULT '';
+	CREATE TABLE link_health(
+		url_id INTEGER PRIMARY KEY REFERENCES url(id) ON DELETE CASCADE,
+		status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		failures INTEGER NOT NULL DEFAULT 0,
+		fallback BOOLEAN NOT NULL DEFAULT FALSE,
+		checked_at DATETIME NOT NULL);
+	CREATE INDEX idx_link_health_failures ON link_health(failures);
+	`,
+}
+
+// migrate applies all migrations newer than the current schema version.
+func (s *Storage) migrate() error {
+	const op = "storage.sqlite.migrate"
+
+	version, err := s.schemaVersion(context.Background())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("%s: begin transaction: %w", op, err)
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: apply migration %d: %w", op, i+1, err)
+		}
+
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: set schema version %d: %w", op, i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("%s: commit migration %d: %w", op, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) schemaVersion(ctx context.Context) (int, error) {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// CheckMigrations returns storage.ErrMigrationsPending if the database schema is older than expected.
+func (s *Storage) CheckMigrations(ctx context.Context) error {
+	const op = "storage.sqlite.CheckMigrations"
+
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if version < len(migrations) {
+		return fmt.Errorf("%s: %w: version %d of %d", op, storage.ErrMigrationsPending, version, len(migrations))
+	}
+
+	return nil
+}
@@ -1,69 +1,289 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 	"url-shortener/internal/storage"
 
 	"github.com/mattn/go-sqlite3"
 )
 
 type Storage struct {
-	db *sql.DB
+	db           *sql.DB // writer pool, also used for migrations
+	rdb          *sql.DB // read-only pool used by lookups
+	stmts        statements
+	hooks        []storage.ChangeHook
+	readTimeout  time.Duration
+	writeTimeout time.Duration
 }
 
+// Options configures the Storage.
+type Options struct {
+	ReadTimeout  time.Duration // deadline for a single read query, zero means no deadline
+	WriteTimeout time.Duration // deadline for a single write query, zero means no deadline
+	WAL          bool          // enable write-ahead logging so that readers do not block the writer
+	BusyTimeout  time.Duration // how long a connection waits for a lock before failing with SQLITE_BUSY
+	Synchronous  string        // OFF, NORMAL, FULL or EXTRA; empty keeps the SQLite default
+	MaxReadConns int           // size of the read-only pool, zero means unlimited
+	MaxIdleConns int           // idle connections kept in the read-only pool
+}
+
+// statements are prepared once in New and closed in Close.
+type statements struct {
+	aliasExists   *sql.Stmt
+	urlExists     *sql.Stmt
+	getAliasByURL *sql.Stmt
+	getLink       *sql.Stmt
+	saveLink      *sql.Stmt
+	consumeClick  *sql.Stmt
+	deleteURL     *sql.Stmt
+	listRules     *sql.Stmt
+	addRule       *sql.Stmt
+	updateRule    *sql.Stmt
+	deleteRule    *sql.Stmt
+	listVariants  *sql.Stmt
+	addVariant    *sql.Stmt
+	countClick    *sql.Stmt
+	countVariant  *sql.Stmt
+	listPresets   *sql.Stmt
+	getPreset     *sql.Stmt
+	savePreset    *sql.Stmt
+	deletePreset  *sql.Stmt
+	getURLID      *sql.Stmt
+	saveMeta      *sql.Stmt
+	listTags      *sql.Stmt
+	addTag        *sql.Stmt
+	tagURL        *sql.Stmt
+	untagURL      *sql.Stmt
+	savePageMeta  *sql.Stmt
+	saveHealth    *sql.Stmt
+	getFallback   *sql.Stmt
+}
+
+var synchronousModes = map[string]bool{"": true, "OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+
 // New creates a new instance of the Storage type, initializing the SQLite database.
-func New(storagePath string) (*Storage, error) {
+func New(storagePath string, opts Options) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", storagePath)
+	opts.Synchronous = strings.ToUpper(opts.Synchronous)
+	if !synchronousModes[opts.Synchronous] {
+		return nil, fmt.Errorf("%s: invalid synchronous mode %q", op, opts.Synchronous)
+	}
+
+	db, err := sql.Open("sqlite3", dsn(storagePath, opts, false))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	// SQLite allows a single writer at a time, more connections would only wait on the lock
+	db.SetMaxOpenConns(1)
 
-	stmt, err := db.Prepare(`
-		CREATE TABLE IF NOT EXISTS url(
-			id INTEGER PRIMARY KEY,
-			alias TEXT NOT NULL UNIQUE,
-			url TEXT NOT NULL);
-		CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
-	`)
-	if err != nil {
+	s := &Storage{
+		db:           db,
+		readTimeout:  opts.ReadTimeout,
+		writeTimeout: opts.WriteTimeout,
+	}
+
+	if err := s.migrate(); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec()
+	// the read-only pool is opened after migrations, so that the database file exists
+	rdb, err := sql.Open("sqlite3", dsn(storagePath, opts, true))
 	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rdb.SetMaxOpenConns(opts.MaxReadConns)
+	rdb.SetMaxIdleConns(opts.MaxIdleConns)
+	s.rdb = rdb
+
+	if err := s.prepareStatements(); err != nil {
+		_ = s.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	return s, nil
+}
+
+// dsn builds a connection string understood by github.com/mattn/go-sqlite3.
+func dsn(storagePath string, opts Options, readOnly bool) string {
+	params := url.Values{}
+	// rules are removed together with their link by ON DELETE CASCADE
+	params.Set("_foreign_keys", "1")
+	if opts.WAL {
+		params.Set("_journal_mode", "WAL")
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", opts.Synchronous)
+	}
+	if readOnly {
+		params.Set("mode", "ro")
+	} else {
+		// take the write lock at the start of a transaction instead of failing on upgrade
+		params.Set("_txlock", "immediate")
+	}
+
+	return "file:" + storagePath + "?" + params.Encode()
+}
+
+func (s *Storage) prepareStatements() error {
+	for _, q := range []struct {
+		stmt  **sql.Stmt
+		db    *sql.DB
+		query string
+	}{
+		{&s.stmts.aliasExists, s.rdb, `SELECT COUNT(*) FROM url WHERE alias = ?`},
+		{&s.stmts.urlExists, s.rdb, `SELECT COUNT(*) FROM url WHERE url = ? AND ` + plainLink},
+		{&s.stmts.getAliasByURL, s.rdb, `SELECT alias FROM url WHERE url = ? AND ` + plainLink + ` ORDER BY id LIMIT 1`},
+		{&s.stmts.getLink, s.rdb, `SELECT ` + linkColumns + ` FROM ` + linkTables + ` WHERE alias = ?`},
+		{&s.stmts.saveLink, s.db, `INSERT INTO url(url, alias, password_hash, max_clicks, clicks_left, not_before, not_after, query_mode, forward_path, redirect_type, created_at,
+			fallback_url) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&s.stmts.consumeClick, s.db, `UPDATE url SET clicks_left = clicks_left - 1 WHERE alias = ? AND clicks_left > 0 RETURNING clicks_left`},
+		{&s.stmts.deleteURL, s.db, `DELETE FROM url WHERE alias = ?`},
+		{&s.stmts.listRules, s.rdb, `SELECT id, position, platform, language, country, url FROM rule WHERE url_id = ? ORDER BY position, id`},
+		// a rule without a position goes after the existing ones
+		{&s.stmts.addRule, s.db, `INSERT INTO rule(url_id, position, platform, language, country, url)
+			SELECT id, CASE WHEN ? > 0 THEN ? ELSE COALESCE((SELECT MAX(position) FROM rule WHERE url_id = url.id), 0) + 1 END, ?, ?, ?, ?
+			FROM url WHERE alias = ?
+			RETURNING id, position`},
+		{&s.stmts.updateRule, s.db, `UPDATE rule SET position = ?, platform = ?, language = ?, country = ?, url = ?
+			WHERE id = ? AND url_id = (SELECT id FROM url WHERE alias = ?)`},
+		{&s.stmts.deleteRule, s.db, `DELETE FROM rule WHERE id = ? AND url_id = (SELECT id FROM url WHERE alias = ?)`},
+		{&s.stmts.listVariants, s.rdb, `SELECT id, url, weight, clicks FROM variant WHERE url_id = ? ORDER BY id`},
+		{&s.stmts.addVariant, s.db, `INSERT INTO variant(url_id, url, weight) VALUES(?, ?, ?)`},
+		{&s.stmts.countClick, s.db, `UPDATE url SET clicks = clicks + ? WHERE alias = ?`},
+		{&s.stmts.countVariant, s.db, `UPDATE variant SET clicks = clicks + ? WHERE id = ? AND url_id = (SELECT id FROM url WHERE alias = ?)`},
+		{&s.stmts.listPresets, s.rdb, `SELECT ` + presetColumns + ` FROM utm_preset ORDER BY id`},
+		{&s.stmts.getPreset, s.rdb, `SELECT ` + presetColumns + ` FROM utm_preset WHERE id = ?`},
+		{&s.stmts.savePreset, s.db, `INSERT INTO utm_preset(name, source, medium, campaign, term, content) VALUES(?, ?, ?, ?, ?, ?)`},
+		{&s.stmts.deletePreset, s.db, `DELETE FROM utm_preset WHERE id = ?`},
+		{&s.stmts.getURLID, s.db, `SELECT id FROM url WHERE alias = ?`},
+		{&s.stmts.saveMeta, s.db, `INSERT INTO url_meta(url_id, title, description, notes, og_title, og_description, og_image) VALUES(?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(url_id) DO UPDATE SET title = excluded.title, description = excluded.description, notes = excluded.notes,
+				og_title = excluded.og_title, og_description = excluded.og_description, og_image = excluded.og_image`},
+		{&s.stmts.listTags, s.rdb, `SELECT tag.name FROM url_tag JOIN tag ON tag.id = url_tag.tag_id WHERE url_tag.url_id = ? ORDER BY tag.name`},
+		{&s.stmts.addTag, s.db, `INSERT INTO tag(name) VALUES(?) ON CONFLICT(name) DO NOTHING`},
+		{&s.stmts.tagURL, s.db, `INSERT INTO url_tag(url_id, tag_id) SELECT ?, id FROM tag WHERE name = ?`},
+		{&s.stmts.untagURL, s.db, `DELETE FROM url_tag WHERE url_id = ?`},
+		{&s.stmts.savePageMeta, s.db, `INSERT INTO page_meta(url_id, title, og_title, og_description, og_image, fetched_at)
+			SELECT id, ?, ?, ?, ?, ? FROM url WHERE alias = ?
+			ON CONFLICT(url_id) DO UPDATE SET title = excluded.title, og_title = excluded.og_title,
+				og_description = excluded.og_description, og_image = excluded.og_image, fetched_at = excluded.fetched_at`},
+		{&s.stmts.saveHealth, s.db, `INSERT INTO link_health(url_id, status, error, latency_ms, failures, fallback, checked_at)
+			SELECT id, ?, ?, ?, ?, ?, ? FROM url WHERE alias = ?
+			ON CONFLICT(url_id) DO UPDATE SET status = excluded.status, error = excluded.error, latency_ms = excluded.latency_ms,
+				failures = excluded.failures, fallback = excluded.fallback, checked_at = excluded.checked_at`},
+		{&s.stmts.getFallback, s.db, `SELECT COALESCE(h.fallback, 0) FROM url LEFT JOIN link_health h ON h.url_id = url.id WHERE url.alias = ?`},
+	} {
+		stmt, err := q.db.Prepare(q.query)
+		if err != nil {
+			return fmt.Errorf("prepare %q: %w", q.query, err)
+		}
+		*q.stmt = stmt
+	}
+
+	return nil
+}
+
+// OnChange registers a hook fired after a link is saved or deleted.
+// Hooks must be registered before the storage is used concurrently.
+func (s *Storage) OnChange(hook storage.ChangeHook) {
+	s.hooks = append(s.hooks, hook)
 }
 
-// Close closes the SQLite database connection.
+func (s *Storage) notifyChange(alias string) {
+	for _, hook := range s.hooks {
+		hook(alias)
+	}
+}
+
+// Close closes the prepared statements and the SQLite database connections.
 func (s *Storage) Close() error {
 	const op = "storage.sqlite.Close"
 
-	// Close the database connection
-	if err := s.db.Close(); err != nil {
+	var errs []error
+	for _, stmt := range []*sql.Stmt{
+		s.stmts.aliasExists,
+		s.stmts.urlExists,
+		s.stmts.getAliasByURL,
+		s.stmts.getLink,
+		s.stmts.saveLink,
+		s.stmts.consumeClick,
+		s.stmts.deleteURL,
+		s.stmts.listRules,
+		s.stmts.addRule,
+		s.stmts.updateRule,
+		s.stmts.deleteRule,
+		s.stmts.listVariants,
+		s.stmts.addVariant,
+		s.stmts.countClick,
+		s.stmts.countVariant,
+		s.stmts.listPresets,
+		s.stmts.getPreset,
+		s.stmts.savePreset,
+		s.stmts.deletePreset,
+		s.stmts.getURLID,
+		s.stmts.saveMeta,
+		s.stmts.listTags,
+		s.stmts.addTag,
+		s.stmts.tagURL,
+		s.stmts.untagURL,
+		s.stmts.savePageMeta,
+		s.stmts.saveHealth,
+		s.stmts.getFallback,
+	} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+
+	// Close the database connections
+	if s.rdb != nil {
+		errs = append(errs, s.rdb.Close())
+	}
+	errs = append(errs, s.db.Close())
+
+	if err := errors.Join(errs...); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+// Ping verifies that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.sqlite.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: writer: %w", op, err)
+	}
+	if err := s.rdb.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: reader: %w", op, err)
+	}
+
+	return nil
+}
+
 // AliasExists checks whether the specified alias exists in the database.
-func (s *Storage) AliasExists(alias string) (bool, error) {
+func (s *Storage) AliasExists(ctx context.Context, alias string) (bool, error) {
 	const op = "storage.sqlite.AliasExists"
 
-	stmt, err := s.db.Prepare(`SELECT COUNT(*) FROM url WHERE alias = ?`)
-	if err != nil {
-		return false, fmt.Errorf("%s: prepare statement %w", op, err)
-	}
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
 
 	var count int
-	err = stmt.QueryRow(alias).Scan(&count)
+	err := s.stmts.aliasExists.QueryRowContext(ctx, alias).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("%s: execute statement: %w", op, err)
 	}
@@ -71,17 +291,52 @@ func (s *Storage) AliasExists(alias string) (bool, error) {
 	return count > 0, nil
 }
 
-// URLExists checks whether the specified URL exists in the database.
-func (s *Storage) URLExists(urlToCheck string) (bool, error) {
-	const op = "storage.sqlite.URLExists"
+// ExistingAliases returns which of aliases are taken, in one query.
+func (s *Storage) ExistingAliases(ctx context.Context, aliases []string) ([]string, error) {
+	const op = "storage.sqlite.ExistingAliases"
+
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
 
-	stmt, err := s.db.Prepare(`SELECT COUNT(*) FROM url WHERE url = ?`)
+	args := make([]any, 0, len(aliases))
+	for _, alias := range aliases {
+		args = append(args, alias)
+	}
+
+	rows, err := s.rdb.QueryContext(ctx, `SELECT alias FROM url WHERE alias IN (?`+strings.Repeat(`, ?`, len(aliases)-1)+`)`, args...)
 	if err != nil {
-		return false, fmt.Errorf("%s: prepare statement %w", op, err)
+		return nil, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var existing []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("%s: scan alias: %w", op, err)
+		}
+		existing = append(existing, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	return existing, nil
+}
+
+// URLExists checks whether a plain link to the specified URL exists in the database.
+func (s *Storage) URLExists(ctx context.Context, urlToCheck string) (bool, error) {
+	const op = "storage.sqlite.URLExists"
+
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
 	var count int
-	err = stmt.QueryRow(urlToCheck).Scan(&count)
+	err := s.stmts.urlExists.QueryRowContext(ctx, urlToCheck).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("%s: execute statement: %w", op, err)
 	}
@@ -89,17 +344,15 @@ func (s *Storage) URLExists(urlToCheck string) (bool, error) {
 	return count > 0, nil
 }
 
-// GetAliasByURL retrieves the alias associated with a given URL from the database.
-func (s *Storage) GetAliasByURL(urlToFind string) (string, error) {
+// GetAliasByURL retrieves the alias of the first plain link to a given URL from the database.
+func (s *Storage) GetAliasByURL(ctx context.Context, urlToFind string) (string, error) {
 	const op = "storage.sqlite.GetAliasByURL"
 
-	stmt, err := s.db.Prepare(`SELECT alias FROM url WHERE url = ?`)
-	if err != nil {
-		return "", fmt.Errorf("%s: prepare statement %w", op, err)
-	}
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
 
 	var alias string
-	err = stmt.QueryRow(urlToFind).Scan(&alias)
+	err := s.stmts.getAliasByURL.QueryRowContext(ctx, urlToFind).Scan(&alias)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -112,16 +365,22 @@ func (s *Storage) GetAliasByURL(urlToFind string) (string, error) {
 	return alias, nil
 }
 
-// SaveURL adds a new URL and alias to the database.
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
-	const op = "storage.sqlite.SaveURL"
+// SaveLink adds a new link to the database.
+func (s *Storage) SaveLink(ctx context.Context, link storage.Link) (int64, error) {
+	const op = "storage.sqlite.SaveLink"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
 
-	stmt, err := s.db.Prepare("INSERT INTO url(url, alias) VALUES(?, ?)")
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: begin transaction: %w", op, err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	res, err := stmt.Exec(urlToSave, alias)
+	res, err := tx.StmtContext(ctx, s.stmts.saveLink).ExecContext(ctx,
+		link.URL, link.Alias, link.PasswordHash, link.MaxClicks, link.MaxClicks, nullTime(link.NotBefore), nullTime(link.NotAfter),
+		link.QueryMode, link.ForwardPath, link.RedirectType, nullTime(link.CreatedAt), link.FallbackURL)
 	if err != nil {
 		// TODO: refactoring this
 		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
@@ -135,44 +394,680 @@ func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
 		return 0, fmt.Errorf("%s: failed to get last insert id: %w", op, err)
 	}
 
+	addVariant := tx.StmtContext(ctx, s.stmts.addVariant)
+	for _, v := range link.Variants {
+		if _, err := addVariant.ExecContext(ctx, id, v.URL, v.Weight); err != nil {
+			return 0, fmt.Errorf("%s: add variant: %w", op, err)
+		}
+	}
+
+	if !link.Meta.Empty() {
+		if err := s.saveMeta(ctx, tx, id, link.Meta); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	s.notifyChange(link.Alias)
+
 	return id, nil
 }
 
-// GetURL retrieves the URL associated with a given alias from the database.
-func (s *Storage) GetURL(alias string) (string, error) {
-	const op = "storage.sqlite.GetURL"
+// GetLink retrieves the link with the given alias from the database.
+func (s *Storage) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.sqlite.GetLink"
+
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	link, err := scanLink(s.stmts.getLink.QueryRowContext(ctx, alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, storage.ErrURLNotFound
+		}
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
 
-	stmt, err := s.db.Prepare("SELECT url FROM url WHERE alias = ?")
+	link.Rules, err = s.listRules(ctx, link.ID)
 	if err != nil {
-		return "", fmt.Errorf("%s: prepare statement: %w", op, err)
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var resURL string
+	link.Variants, err = s.listVariants(ctx, link.ID)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
 
-	err = stmt.QueryRow(alias).Scan(&resURL)
+	link.Tags, err = s.listTags(ctx, link.ID)
 	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// ListLinks returns the links matching filter ordered by ID, without their rules and variants.
+func (s *Storage) ListLinks(ctx context.Context, filter storage.LinkFilter) ([]storage.Link, error) {
+	const op = "storage.sqlite.ListLinks"
+
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + linkColumns + ` FROM ` + linkTables + ` WHERE 1 = 1`
+	var args []any
+
+	if len(filter.Tags) > 0 {
+		query += ` AND url.id IN (SELECT url_tag.url_id FROM url_tag JOIN tag ON tag.id = url_tag.tag_id
+			WHERE tag.name IN (?` + strings.Repeat(`, ?`, len(filter.Tags)-1) + `)
+			GROUP BY url_tag.url_id HAVING COUNT(*) = ?)`
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(filter.Tags))
+	}
+
+	if filter.Query != "" {
+		query += ` AND (alias LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\' OR url_meta.title LIKE ? ESCAPE '\' OR url_meta.description LIKE ? ESCAPE '\')`
+		pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+
+	if filter.Broken {
+		query += ` AND link_health.failures > 0`
+	}
+
+	query += ` ORDER BY url.id LIMIT ? OFFSET ?`
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	args = append(args, limit, filter.Offset)
+
+	rows, err := s.rdb.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []storage.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan link: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range links {
+		if links[i].Tags, err = s.listTags(ctx, links[i].ID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return links, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, backslash is the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// UpdateMeta replaces the metadata of the link with the given alias.
+func (s *Storage) UpdateMeta(ctx context.Context, alias string, meta storage.Meta) error {
+	const op = "storage.sqlite.UpdateMeta"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	if err := tx.StmtContext(ctx, s.stmts.getURLID).QueryRowContext(ctx, alias).Scan(&id); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", storage.ErrURLNotFound
+			return storage.ErrURLNotFound
 		}
-		return "", fmt.Errorf("%s: execute statement: %w", op, err)
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if _, err := tx.StmtContext(ctx, s.stmts.untagURL).ExecContext(ctx, id); err != nil {
+		return fmt.Errorf("%s: remove tags: %w", op, err)
+	}
+
+	if err := s.saveMeta(ctx, tx, id, meta); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	s.notifyChange(alias)
+
+	return nil
+}
+
+// SavePageMeta stores what was fetched from the destination of the link with the given alias.
+func (s *Storage) SavePageMeta(ctx context.Context, alias string, page storage.PageMeta) error {
+	const op = "storage.sqlite.SavePageMeta"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	res, err := s.stmts.savePageMeta.ExecContext(ctx,
+		page.Title, page.OGTitle, page.OGDescription, page.OGImage, page.FetchedAt.UTC(), alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrURLNotFound
+	}
+
+	s.notifyChange(alias)
+
+	return nil
+}
+
+// SaveHealth stores the result of a destination check of the link with the given alias.
+func (s *Storage) SaveHealth(ctx context.Context, alias string, health storage.LinkHealth) error {
+	const op = "storage.sqlite.SaveHealth"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var fallback bool
+	err = tx.StmtContext(ctx, s.stmts.getFallback).QueryRowContext(ctx, alias).Scan(&fallback)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrURLNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%s: get fallback: %w", op, err)
+	}
+
+	_, err = tx.StmtContext(ctx, s.stmts.saveHealth).ExecContext(ctx,
+		health.Status, health.Error, health.LatencyMS, health.Failures, health.Fallback, health.CheckedAt.UTC(), alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	// only the fallback changes where redirects go, cached links may keep stale results otherwise
+	if fallback != health.Fallback {
+		s.notifyChange(alias)
+	}
+
+	return nil
+}
+
+// NormalizeAliases renames the links whose aliases change under normalize and returns how many
+// were renamed. Nothing is renamed when several aliases would become the same.
+func (s *Storage) NormalizeAliases(ctx context.Context, normalize func(alias string) string) (int, error) {
+	const op = "storage.sqlite.NormalizeAliases"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT alias FROM url`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: list aliases: %w", op, err)
+	}
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s: scan alias: %w", op, err)
+		}
+		aliases = append(aliases, alias)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: list aliases: %w", op, err)
 	}
 
-	return resURL, nil
+	renames, err := storage.AliasRenames(aliases, normalize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for from, to := range renames {
+		if _, err := tx.ExecContext(ctx, `UPDATE url SET alias = ? WHERE alias = ?`, to, from); err != nil {
+			return 0, fmt.Errorf("%s: rename %q: %w", op, from, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	for from := range renames {
+		s.notifyChange(from)
+	}
+
+	return len(renames), nil
+}
+
+// saveMeta stores meta of the link with the given ID and adds its tags within tx.
+func (s *Storage) saveMeta(ctx context.Context, tx *sql.Tx, id int64, meta storage.Meta) error {
+	if _, err := tx.StmtContext(ctx, s.stmts.saveMeta).ExecContext(ctx, id, meta.Title, meta.Description, meta.Notes,
+		meta.OGTitle, meta.OGDescription, meta.OGImage); err != nil {
+		return fmt.Errorf("save meta: %w", err)
+	}
+
+	addTag := tx.StmtContext(ctx, s.stmts.addTag)
+	tagURL := tx.StmtContext(ctx, s.stmts.tagURL)
+	for _, tag := range meta.Tags {
+		if _, err := addTag.ExecContext(ctx, tag); err != nil {
+			return fmt.Errorf("add tag: %w", err)
+		}
+		if _, err := tagURL.ExecContext(ctx, id, tag); err != nil {
+			return fmt.Errorf("tag url: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) listTags(ctx context.Context, urlID int64) ([]string, error) {
+	rows, err := s.stmts.listTags.QueryContext(ctx, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (s *Storage) listRules(ctx context.Context, urlID int64) ([]storage.Rule, error) {
+	rows, err := s.stmts.listRules.QueryContext(ctx, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("list rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []storage.Rule
+	for rows.Next() {
+		var rule storage.Rule
+		if err := rows.Scan(&rule.ID, &rule.Position, &rule.Platform, &rule.Language, &rule.Country, &rule.URL); err != nil {
+			return nil, fmt.Errorf("scan rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) listVariants(ctx context.Context, urlID int64) ([]storage.Variant, error) {
+	rows, err := s.stmts.listVariants.QueryContext(ctx, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("list variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []storage.Variant
+	for rows.Next() {
+		var v storage.Variant
+		if err := rows.Scan(&v.ID, &v.URL, &v.Weight, &v.Clicks); err != nil {
+			return nil, fmt.Errorf("scan variant: %w", err)
+		}
+		variants = append(variants, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list variants: %w", err)
+	}
+
+	return variants, nil
+}
+
+// CountClicks adds counted redirects to links and their variants in a single transaction.
+// Clicks of links and variants that no longer exist are dropped.
+func (s *Storage) CountClicks(ctx context.Context, clicks []storage.Clicks) error {
+	const op = "storage.sqlite.CountClicks"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	countClick := tx.StmtContext(ctx, s.stmts.countClick)
+	countVariant := tx.StmtContext(ctx, s.stmts.countVariant)
+
+	for _, c := range clicks {
+		if _, err := countClick.ExecContext(ctx, c.Count, c.Alias); err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+
+		if c.VariantID != 0 {
+			if _, err := countVariant.ExecContext(ctx, c.Count, c.VariantID, c.Alias); err != nil {
+				return fmt.Errorf("%s: count variant: %w", op, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddRule adds a targeting rule to the link with the given alias and returns it with
+// its ID and position set. A rule without a position is placed after the existing ones.
+func (s *Storage) AddRule(ctx context.Context, alias string, rule storage.Rule) (storage.Rule, error) {
+	const op = "storage.sqlite.AddRule"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	err := s.stmts.addRule.QueryRowContext(ctx,
+		rule.Position, rule.Position, rule.Platform, rule.Language, rule.Country, rule.URL, alias,
+	).Scan(&rule.ID, &rule.Position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Rule{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return storage.Rule{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	s.notifyChange(alias)
+
+	return rule, nil
+}
+
+// UpdateRule replaces the rule with rule.ID of the link with the given alias.
+func (s *Storage) UpdateRule(ctx context.Context, alias string, rule storage.Rule) error {
+	const op = "storage.sqlite.UpdateRule"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	res, err := s.stmts.updateRule.ExecContext(ctx,
+		rule.Position, rule.Platform, rule.Language, rule.Country, rule.URL, rule.ID, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return s.ruleChanged(op, alias, res)
+}
+
+// DeleteRule removes the rule with the given ID from the link with the given alias.
+func (s *Storage) DeleteRule(ctx context.Context, alias string, id int64) error {
+	const op = "storage.sqlite.DeleteRule"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	res, err := s.stmts.deleteRule.ExecContext(ctx, id, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return s.ruleChanged(op, alias, res)
+}
+
+// ruleChanged notifies the hooks when res touched a rule and reports storage.ErrRuleNotFound otherwise.
+func (s *Storage) ruleChanged(op, alias string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrRuleNotFound
+	}
+
+	s.notifyChange(alias)
+
+	return nil
+}
+
+// ConsumeClick atomically takes one click from a link with a click limit and
+// returns the number of clicks left after it. It returns storage.ErrExhausted
+// when no clicks are left, which is also the case for links without a limit.
+func (s *Storage) ConsumeClick(ctx context.Context, alias string) (int64, error) {
+	const op = "storage.sqlite.ConsumeClick"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	var left int64
+	err := s.stmts.consumeClick.QueryRowContext(ctx, alias).Scan(&left)
+	if err == nil {
+		return left, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	exists, err := s.AliasExists(ctx, alias)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return 0, storage.ErrURLNotFound
+	}
+
+	return 0, storage.ErrExhausted
 }
 
 // DeleteURL removes a URL and its associated alias from the database.
-func (s *Storage) DeleteURL(alias string) error {
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
 	const op = "storage.sqlite.DeleteURL"
 
-	stmt, err := s.db.Prepare("DELETE FROM url WHERE alias = ?")
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	_, err := s.stmts.deleteURL.ExecContext(ctx, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	s.notifyChange(alias)
+
+	return nil
+}
+
+// SaveUTMPreset adds a new UTM preset and returns its ID.
+func (s *Storage) SaveUTMPreset(ctx context.Context, preset storage.UTMPreset) (int64, error) {
+	const op = "storage.sqlite.SaveUTMPreset"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	res, err := s.stmts.savePreset.ExecContext(ctx,
+		preset.Name, preset.Source, preset.Medium, preset.Campaign, preset.Term, preset.Content)
 	if err != nil {
-		return fmt.Errorf("%s: prepare statement: %w", op, err)
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrPresetExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = stmt.Exec(alias)
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get last insert id: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetUTMPreset retrieves the UTM preset with the given ID.
+func (s *Storage) GetUTMPreset(ctx context.Context, id int64) (storage.UTMPreset, error) {
+	const op = "storage.sqlite.GetUTMPreset"
+
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	preset, err := scanPreset(s.stmts.getPreset.QueryRowContext(ctx, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.UTMPreset{}, storage.ErrPresetNotFound
+	}
+	if err != nil {
+		return storage.UTMPreset{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return preset, nil
+}
+
+// ListUTMPresets returns all UTM presets ordered by ID.
+func (s *Storage) ListUTMPresets(ctx context.Context) ([]storage.UTMPreset, error) {
+	const op = "storage.sqlite.ListUTMPresets"
+
+	ctx, cancel := s.readContext(ctx)
+	defer cancel()
+
+	rows, err := s.stmts.listPresets.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	var presets []storage.UTMPreset
+	for rows.Next() {
+		preset, err := scanPreset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan preset: %w", op, err)
+		}
+		presets = append(presets, preset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return presets, nil
+}
+
+// DeleteUTMPreset removes the UTM preset with the given ID. Links created with it keep their tags.
+func (s *Storage) DeleteUTMPreset(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteUTMPreset"
+
+	ctx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	res, err := s.stmts.deletePreset.ExecContext(ctx, id)
 	if err != nil {
 		return fmt.Errorf("%s: execute statement: %w", op, err)
 	}
 
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrPresetNotFound
+	}
+
 	return nil
 }
+
+// presetColumns are the columns read by scanPreset, in order.
+const presetColumns = `id, name, source, medium, campaign, term, content`
+
+func scanPreset(row interface{ Scan(dest ...any) error }) (storage.UTMPreset, error) {
+	var p storage.UTMPreset
+	err := row.Scan(&p.ID, &p.Name, &p.Source, &p.Medium, &p.Campaign, &p.Term, &p.Content)
+
+	return p, err
+}
+
+// plainLink selects the rows of url that storage.Link.Plain would accept.
+const plainLink = `password_hash = '' AND max_clicks = 0 AND not_before IS NULL AND not_after IS NULL AND query_mode = ''
+	AND forward_path = 0 AND redirect_type = '' AND fallback_url = ''
+	AND NOT EXISTS (SELECT 1 FROM rule WHERE rule.url_id = url.id)
+	AND NOT EXISTS (SELECT 1 FROM variant WHERE variant.url_id = url.id)
+	AND NOT EXISTS (SELECT 1 FROM url_tag WHERE url_tag.url_id = url.id)
+	AND NOT EXISTS (SELECT 1 FROM url_meta WHERE url_meta.url_id = url.id AND (title != '' OR description != '' OR notes != ''
+		OR og_title != '' OR og_description != '' OR og_image != ''))`
+
+// linkColumns are the columns read by scanLink, in order.
+const linkColumns = `id, alias, url, password_hash, max_clicks, clicks_left, not_before, not_after, query_mode, forward_path, redirect_type, created_at, clicks,
+	COALESCE(url_meta.title, ''), COALESCE(url_meta.description, ''), COALESCE(url_meta.notes, ''),
+	COALESCE(url_meta.og_title, ''), COALESCE(url_meta.og_description, ''), COALESCE(url_meta.og_image, ''),
+	COALESCE(page_meta.title, ''), COALESCE(page_meta.og_title, ''), COALESCE(page_meta.og_description, ''),
+	COALESCE(page_meta.og_image, ''), page_meta.fetched_at,
+	fallback_url, COALESCE(link_health.status, 0), COALESCE(link_health.error, ''), COALESCE(link_health.latency_ms, 0),
+	COALESCE(link_health.failures, 0), COALESCE(link_health.fallback, FALSE), link_health.checked_at`
+
+// linkTables joins the metadata that links without any lack.
+const linkTables = `url LEFT JOIN url_meta ON url_meta.url_id = url.id LEFT JOIN page_meta ON page_meta.url_id = url.id
+	LEFT JOIN link_health ON link_health.url_id = url.id`
+
+func scanLink(row interface{ Scan(dest ...any) error }) (storage.Link, error) {
+	var link storage.Link
+	var notBefore, notAfter, createdAt, fetchedAt, checkedAt sql.NullTime
+	err := row.Scan(&link.ID, &link.Alias, &link.URL, &link.PasswordHash, &link.MaxClicks, &link.ClicksLeft, &notBefore, &notAfter,
+		&link.QueryMode, &link.ForwardPath, &link.RedirectType, &createdAt, &link.Clicks,
+		&link.Title, &link.Description, &link.Notes, &link.OGTitle, &link.OGDescription, &link.OGImage,
+		&link.Page.Title, &link.Page.OGTitle, &link.Page.OGDescription, &link.Page.OGImage, &fetchedAt,
+		&link.FallbackURL, &link.Health.Status, &link.Health.Error, &link.Health.LatencyMS, &link.Health.Failures, &link.Health.Fallback, &checkedAt)
+	link.NotBefore = notBefore.Time
+	link.NotAfter = notAfter.Time
+	link.CreatedAt = createdAt.Time
+	link.Page.FetchedAt = fetchedAt.Time
+	link.Health.CheckedAt = checkedAt.Time
+
+	return link, err
+}
+
+// nullTime stores a zero time as NULL and any other time in UTC.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// readContext bounds ctx by the configured read deadline.
+func (s *Storage) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.readTimeout)
+}
+
+// writeContext bounds ctx by the configured write deadline.
+func (s *Storage) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, s.writeTimeout)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -1,8 +1,197 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 var (
-	ErrURLNotFound = errors.New("URL not found")
-	ErrURLExists   = errors.New("URL exists")
+	ErrURLNotFound  = errors.New("URL not found")
+	ErrURLExists    = errors.New("URL exists")
+	ErrExhausted    = errors.New("link has no clicks left")
+	ErrRuleNotFound = errors.New("rule not found")
+
+	ErrPresetNotFound = errors.New("UTM preset not found")
+	ErrPresetExists   = errors.New("UTM preset exists")
+
+	ErrMigrationsPending = errors.New("migrations pending")
+
+	ErrAliasCollision = errors.New("aliases collide")
 )
+
+// Link is a short link together with its settings.
+type Link struct {
+	ID           int64     `json:"id"`
+	Alias        string    `json:"alias"`
+	URL          string    `json:"url"`
+	PasswordHash string    `json:"password_hash,omitempty"` // empty for links that are not password-protected
+	MaxClicks    int64     `json:"max_clicks,omitempty"`    // zero for links without a click limit
+	ClicksLeft   int64     `json:"clicks_left,omitempty"`   // may be stale when the link was read through a cache
+	NotBefore    time.Time `json:"not_before"`              // zero when the link is active right after creation
+	NotAfter     time.Time `json:"not_after"`               // zero when the link never expires
+	QueryMode    string    `json:"query_mode,omitempty"`    // how the incoming query is merged into URL, see lib/forward
+	ForwardPath  bool      `json:"forward_path,omitempty"`  // append the path after the alias to URL
+	RedirectType string    `json:"redirect_type,omitempty"` // empty for the server default, see handlers/redirect
+	Rules        []Rule    `json:"rules,omitempty"`         // ordered by Position, see lib/targeting
+	Variants     []Variant `json:"variants,omitempty"`      // traffic is split between them by weight instead of going to URL
+	CreatedAt    time.Time `json:"created_at"`              // zero for links saved before creation times were recorded
+	Clicks       int64     `json:"clicks,omitempty"`        // redirects so far, may be stale when the link was read through a cache
+	Meta
+	Page PageMeta `json:"page"` // zero until the destination was fetched
+
+	FallbackURL string     `json:"fallback_url,omitempty"` // used while the destination is broken
+	Health      LinkHealth `json:"health"`                 // zero until the destination was checked
+}
+
+// LinkHealth is the result of the last periodic check of the destination of a link.
+type LinkHealth struct {
+	Status    int       `json:"status,omitempty"` // HTTP status, zero when there was no response
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	Failures  int       `json:"failures"`           // consecutive failed checks
+	Fallback  bool      `json:"fallback,omitempty"` // visitors are sent to the fallback URL
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// PageMeta is what the destination page tells about itself, fetched in the background after the link was saved.
+type PageMeta struct {
+	Title         string    `json:"title,omitempty"` // the <title> of the page
+	OGTitle       string    `json:"og_title,omitempty"`
+	OGDescription string    `json:"og_description,omitempty"`
+	OGImage       string    `json:"og_image,omitempty"` // absolute URL
+	FetchedAt     time.Time `json:"fetched_at"`
+}
+
+// Meta describes a link for the people managing it, it does not change where the link leads.
+type Meta struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	Tags        []string `json:"tags,omitempty"` // normalized with lib/tags
+
+	// Open Graph tags shown to chat app crawlers instead of the ones of the destination
+	OGTitle       string `json:"og_title,omitempty"`
+	OGDescription string `json:"og_description,omitempty"`
+	OGImage       string `json:"og_image,omitempty"`
+}
+
+// Empty reports whether nothing is set.
+func (m Meta) Empty() bool {
+	return m.Title == "" && m.Description == "" && m.Notes == "" && len(m.Tags) == 0 &&
+		m.OGTitle == "" && m.OGDescription == "" && m.OGImage == ""
+}
+
+// LinkFilter selects the links returned by a listing.
+type LinkFilter struct {
+	Tags   []string // links having all of them
+	Query  string   // substring of the alias, URL, title or description, case-insensitive
+	Broken bool     // links whose last destination check failed
+	Limit  int
+	Offset int
+}
+
+// Variant is one of the destinations of a link splitting its traffic.
+type Variant struct {
+	ID     int64  `json:"id"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`           // share of the traffic relative to the other variants
+	Clicks int64  `json:"clicks,omitempty"` // may be stale when the link was read through a cache
+}
+
+// Clicks is a number of redirects through the link with Alias and, unless VariantID is zero, its variant.
+type Clicks struct {
+	Alias     string
+	VariantID int64
+	Count     int64
+}
+
+// Rule sends visitors matching all of its non-empty conditions to URL instead of the link's URL.
+type Rule struct {
+	ID       int64  `json:"id"`
+	Position int    `json:"position"`           // rules are evaluated in ascending order
+	Platform string `json:"platform,omitempty"` // ios, android, mobile, windows, macos, linux or desktop
+	Language string `json:"language,omitempty"` // language tag, "de" also matches "de-AT"
+	Country  string `json:"country,omitempty"`  // ISO 3166-1 alpha-2 code
+	URL      string `json:"url"`
+}
+
+// Protected reports whether the link can only be followed after entering a password.
+func (l Link) Protected() bool {
+	return l.PasswordHash != ""
+}
+
+// Limited reports whether the link stops working after MaxClicks redirects.
+func (l Link) Limited() bool {
+	return l.MaxClicks > 0
+}
+
+// Plain reports whether the link is a bare redirect to URL without any settings or metadata.
+// Only plain links are handed out again for the same URL.
+func (l Link) Plain() bool {
+	return !l.Protected() && !l.Limited() && !l.Scheduled() && l.QueryMode == "" && !l.ForwardPath && l.RedirectType == "" &&
+		len(l.Rules) == 0 && len(l.Variants) == 0 && l.FallbackURL == "" && l.Meta.Empty()
+}
+
+// Scheduled reports whether the link has an activation window.
+func (l Link) Scheduled() bool {
+	return !l.NotBefore.IsZero() || !l.NotAfter.IsZero()
+}
+
+// Pending reports whether the link is not active yet at now.
+func (l Link) Pending(now time.Time) bool {
+	return !l.NotBefore.IsZero() && now.Before(l.NotBefore)
+}
+
+// Expired reports whether the link is no longer active at now.
+func (l Link) Expired(now time.Time) bool {
+	return !l.NotAfter.IsZero() && !now.Before(l.NotAfter)
+}
+
+// UTMPreset is a named set of UTM tags that links can be created with.
+type UTMPreset struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// ChangeHook is called after the link with the given alias was created, updated or deleted.
+// It is used to invalidate caches in front of the storage.
+type ChangeHook func(alias string)
+
+// AliasRenames maps the aliases that change under normalize to their normalized form. It fails with
+// ErrAliasCollision naming the aliases when several of them would become the same.
+func AliasRenames(aliases []string, normalize func(alias string) string) (map[string]string, error) {
+	groups := make(map[string][]string)
+	for _, a := range aliases {
+		n := normalize(a)
+		groups[n] = append(groups[n], a)
+	}
+
+	var collisions []string
+	renames := make(map[string]string)
+	for n, group := range groups {
+		if len(group) > 1 {
+			sort.Strings(group)
+			collisions = append(collisions, strings.Join(group, ", "))
+
+			continue
+		}
+		if group[0] != n {
+			renames[group[0]] = n
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+
+		return nil, fmt.Errorf("%w: %s", ErrAliasCollision, strings.Join(collisions, "; "))
+	}
+
+	return renames, nil
+}